@@ -0,0 +1,656 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/service"
+	"github.com/michaelcosj/hng-task-two/internal/validate"
+)
+
+var errUsage = errors.New("usage")
+
+// the audit log records actions taken with the command without an actor
+var cliActor = uuid.Nil
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error
+}
+
+// commands are named by their group and action, like "users create"
+var commands = map[string]command{
+	"users create": {
+		usage: "-email <email> -first-name <name> -last-name <name> [-phone <e.164>] [-password-stdin]",
+		run:   createUser,
+	},
+	"users reset-password": {
+		usage: "-user <id or email> [-password-stdin]",
+		run:   resetPassword,
+	},
+	"users list": {
+		usage: "[-search <email or name>] [-limit 50] [-offset 0]",
+		run:   listUsers,
+	},
+	"users suspend": {
+		usage: "-user <id or email> -reason <reason>",
+		run:   suspendUser,
+	},
+	"users reactivate": {
+		usage: "-user <id or email>",
+		run:   reactivateUser,
+	},
+	"users export": {
+		usage: "-user <id or email>",
+		run:   exportUser,
+	},
+	"users grant-superadmin": {
+		usage: "-user <id or email>",
+		run: func(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+			return setSuperadmin(ctx, c, fs, args, true)
+		},
+	},
+	"users revoke-superadmin": {
+		usage: "-user <id or email>",
+		run: func(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+			return setSuperadmin(ctx, c, fs, args, false)
+		},
+	},
+	"users logout": {
+		usage: "-user <id or email>",
+		run:   logoutUser,
+	},
+	"orgs list": {
+		usage: "[-search <name>] [-limit 50] [-offset 0]",
+		run:   listOrgs,
+	},
+	"orgs members": {
+		usage: "-org <id>",
+		run:   listMembers,
+	},
+	"orgs create": {
+		usage: "-name <name> [-description <description>] -owner <id or email>",
+		run:   createOrg,
+	},
+	"orgs add-member": {
+		usage: "-org <id> -user <id or email> [-role member|admin]",
+		run:   addMember,
+	},
+	"orgs remove-member": {
+		usage: "-org <id> -user <id or email>",
+		run:   removeMember,
+	},
+	"orgs set-role": {
+		usage: "-org <id> -user <id or email> -role member|admin",
+		run:   setRole,
+	},
+	"audit list": {
+		usage: "[-target <id or email>] [-before <entry id>] [-limit 50]",
+		run:   listAudit,
+	},
+}
+
+type cli struct {
+	svc    service.Service
+	in     io.Reader
+	out    io.Writer
+	output string
+}
+
+func newCli(svc service.Service, in io.Reader, out io.Writer) *cli {
+	return &cli{svc: svc, in: in, out: out}
+}
+
+// run runs the command named by the first two arguments
+func (c *cli) run(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		c.usage()
+		return errUsage
+	}
+
+	name := args[0] + " " + args[1]
+	cmd, ok := commands[name]
+	if !ok {
+		c.usage()
+		return fmt.Errorf("%w: unknown command %q", errUsage, name)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.out)
+	fs.StringVar(&c.output, "output", "table", "output format, table or json")
+	fs.Usage = func() {
+		fmt.Fprintf(c.out, "usage: admin %s %s [-output table|json]\n", name, cmd.usage)
+		fs.PrintDefaults()
+	}
+
+	if err := cmd.run(ctx, c, fs, args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *cli) usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(c.out, "usage: admin <group> <action> [flags]")
+	for _, name := range names {
+		fmt.Fprintf(c.out, "  %s %s\n", name, commands[name].usage)
+	}
+}
+
+// parse parses the flags, checking the ones in required were set
+func (c *cli) parse(fs *flag.FlagSet, args []string, required ...string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, fs.Arg(0))
+	}
+	if c.output != "table" && c.output != "json" {
+		return fmt.Errorf("%w: output must be table or json", errUsage)
+	}
+
+	for _, name := range required {
+		if fs.Lookup(name).Value.String() == "" {
+			return fmt.Errorf("%w: -%s is required", errUsage, name)
+		}
+	}
+	return nil
+}
+
+// user finds the user ref names, either their id or email
+func (c *cli) user(ctx context.Context, ref string) (*service.AccountData, uuid.UUID, error) {
+	var account *service.AccountData
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		account, err = c.svc.GetAccount(ctx, id)
+	} else {
+		account, err = c.svc.GetAccountByEmail(ctx, ref)
+	}
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	return account, uuid.MustParse(account.Id), nil
+}
+
+func parseOrgId(value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: -org must be an organisation id", errUsage)
+	}
+	return id, nil
+}
+
+// print writes value as json, or as a table with the rows given
+func (c *cli) print(value any, header []string, rows [][]string) error {
+	if c.output == "json" {
+		encoder := json.NewEncoder(c.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+var accountHeader = []string{"ID", "EMAIL", "NAME", "PHONE", "STATUS", "SINCE", "SUPERADMIN"}
+
+func accountRow(account service.AccountData) []string {
+	since := ""
+	if account.StatusChangedAt != nil {
+		since = account.StatusChangedAt.Format(time.RFC3339)
+	}
+	superadmin := ""
+	if account.Superadmin {
+		superadmin = "yes"
+	}
+	return []string{account.Id, account.Email, account.FirstName + " " + account.LastName, account.Phone, account.Status, since, superadmin}
+}
+
+// passwordFlag adds -password-stdin. passwords aren't taken as flag
+// values, ps and shell history would show them to anyone
+func passwordFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("password-stdin", false, "read the password from the first line of stdin, one is generated and printed when not given")
+}
+
+// password reads the password from the first line of stdin
+// when fromStdin is set, otherwise it generates one
+func (c *cli) password(fromStdin bool) (password string, generated bool, err error) {
+	if !fromStdin {
+		password, err = generatePassword()
+		return password, true, err
+	}
+
+	line, err := bufio.NewReader(c.in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", false, fmt.Errorf("error reading password: %w", err)
+	}
+	password = strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", false, fmt.Errorf("%w: -password-stdin was given but stdin has no password", errUsage)
+	}
+	return password, false, nil
+}
+
+// generatePassword makes a password for users created or reset without one
+func generatePassword() (string, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func checkPassword(password string) error {
+	v := validate.New()
+	v.String("password", password).Password()
+	return v.Err()
+}
+
+// describeError explains err to the person running the command
+func describeError(err error) string {
+	var apiErr app.ApiError
+	if !errors.As(err, &apiErr) {
+		return err.Error()
+	}
+
+	message := apiErr.Message
+	if apiErr.Detail != "" {
+		message = apiErr.Detail
+	}
+	for _, problem := range apiErr.Errors {
+		message += fmt.Sprintf("\n  %s %s", problem.Field, problem.Message)
+	}
+	return message
+}
+
+// ------ Users ------ //
+
+// accountWithPassword is a user and the password generated for them
+type accountWithPassword struct {
+	*service.AccountData
+	Password string `json:"password,omitempty"`
+}
+
+func createUser(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	var params service.RegisterParams
+	fs.StringVar(&params.Email, "email", "", "email address")
+	fs.StringVar(&params.FirstName, "first-name", "", "first name")
+	fs.StringVar(&params.LastName, "last-name", "", "last name")
+	fs.StringVar(&params.Phone, "phone", "", "E.164 phone number")
+	fromStdin := passwordFlag(fs)
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+
+	password, generated, err := c.password(*fromStdin)
+	if err != nil {
+		return err
+	}
+	params.Password = password
+
+	// the rules the api checks, reported by flag
+	v := validate.New()
+	v.String("email", params.Email).Required().AccountEmail()
+	v.String("first-name", params.FirstName).Required().Name()
+	v.String("last-name", params.LastName).Required().Name()
+	v.String("phone", params.Phone).Phone()
+	v.String("password", params.Password).Password()
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	data, err := c.svc.CreateAccount(ctx, cliActor, params)
+	if err != nil {
+		return err
+	}
+
+	created := accountWithPassword{AccountData: &service.AccountData{UserData: data.User}}
+	row := accountRow(*created.AccountData)
+	header := accountHeader
+	if generated {
+		created.Password = params.Password
+		header = append(header[:len(header):len(header)], "PASSWORD")
+		row = append(row, params.Password)
+	}
+	return c.print(created, header, [][]string{row})
+}
+
+func resetPassword(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	ref := fs.String("user", "", "id or email of the user")
+	fromStdin := passwordFlag(fs)
+	if err := c.parse(fs, args, "user"); err != nil {
+		return err
+	}
+
+	account, userId, err := c.user(ctx, *ref)
+	if err != nil {
+		return err
+	}
+
+	password, generated, err := c.password(*fromStdin)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(password); err != nil {
+		return err
+	}
+
+	if err := c.svc.ResetPassword(ctx, cliActor, userId, password); err != nil {
+		return err
+	}
+
+	if !generated {
+		return nil
+	}
+	reset := accountWithPassword{AccountData: account, Password: password}
+	return c.print(reset, []string{"ID", "EMAIL", "PASSWORD"}, [][]string{{account.Id, account.Email, password}})
+}
+
+// searchFlags adds the flags paging through a search
+func searchFlags(fs *flag.FlagSet, what string) *service.SearchParam {
+	var param service.SearchParam
+	fs.StringVar(&param.Query, "search", "", "only "+what+" containing this")
+	fs.IntVar(&param.Limit, "limit", 50, "maximum number listed")
+	fs.IntVar(&param.Offset, "offset", 0, "number skipped")
+	return &param
+}
+
+func checkPage(limit int, offset int) error {
+	if limit < 1 || offset < 0 {
+		return fmt.Errorf("%w: -limit must be positive and -offset can't be negative", errUsage)
+	}
+	return nil
+}
+
+func listUsers(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	param := searchFlags(fs, "users whose email or name is")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if err := checkPage(param.Limit, param.Offset); err != nil {
+		return err
+	}
+
+	data, err := c.svc.SearchAccounts(ctx, cliActor, *param)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{}
+	for _, account := range data.Accounts {
+		rows = append(rows, accountRow(account))
+	}
+	return c.print(data, accountHeader, rows)
+}
+
+func suspendUser(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	reason := fs.String("reason", "", "why the user is suspended")
+	return updateAccount(ctx, c, fs, args, func(userId uuid.UUID) error {
+		return c.svc.SuspendAccount(ctx, cliActor, userId, *reason)
+	})
+}
+
+func reactivateUser(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	return updateAccount(ctx, c, fs, args, func(userId uuid.UUID) error {
+		return c.svc.ReactivateAccount(ctx, cliActor, userId)
+	})
+}
+
+func setSuperadmin(ctx context.Context, c *cli, fs *flag.FlagSet, args []string, superadmin bool) error {
+	return updateAccount(ctx, c, fs, args, func(userId uuid.UUID) error {
+		return c.svc.SetSuperadmin(ctx, cliActor, userId, superadmin)
+	})
+}
+
+func logoutUser(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	return updateAccount(ctx, c, fs, args, func(userId uuid.UUID) error {
+		return c.svc.LogoutUser(ctx, cliActor, userId)
+	})
+}
+
+// updateAccount runs update on the user named by -user and prints
+// the account as it is afterwards
+func updateAccount(ctx context.Context, c *cli, fs *flag.FlagSet, args []string, update func(userId uuid.UUID) error) error {
+	ref := fs.String("user", "", "id or email of the user")
+	if err := c.parse(fs, args, "user"); err != nil {
+		return err
+	}
+
+	_, userId, err := c.user(ctx, *ref)
+	if err != nil {
+		return err
+	}
+
+	if err := update(userId); err != nil {
+		return err
+	}
+
+	account, err := c.svc.GetAccount(ctx, userId)
+	if err != nil {
+		return err
+	}
+	return c.print(account, accountHeader, [][]string{accountRow(*account)})
+}
+
+// exportUser always writes json, the export is meant to be handed over as is
+func exportUser(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	ref := fs.String("user", "", "id or email of the user")
+	if err := c.parse(fs, args, "user"); err != nil {
+		return err
+	}
+
+	_, userId, err := c.user(ctx, *ref)
+	if err != nil {
+		return err
+	}
+
+	data, err := c.svc.ExportUser(ctx, cliActor, userId)
+	if err != nil {
+		return err
+	}
+
+	c.output = "json"
+	return c.print(data, nil, nil)
+}
+
+// ------ Organisations ------ //
+
+func listOrgs(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	param := searchFlags(fs, "organisations whose name is")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if err := checkPage(param.Limit, param.Offset); err != nil {
+		return err
+	}
+
+	data, err := c.svc.SearchOrganisations(ctx, cliActor, *param)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{}
+	for _, org := range data.Orgs {
+		rows = append(rows, []string{org.Id, org.Name, org.Description})
+	}
+	return c.print(data, []string{"ID", "NAME", "DESCRIPTION"}, rows)
+}
+
+func listMembers(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	org := fs.String("org", "", "id of the organisation")
+	if err := c.parse(fs, args, "org"); err != nil {
+		return err
+	}
+
+	orgId, err := parseOrgId(*org)
+	if err != nil {
+		return err
+	}
+
+	data, err := c.svc.GetOrganisationMembers(ctx, cliActor, orgId)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{}
+	for _, member := range data.Members {
+		rows = append(rows, append(accountRow(member.AccountData), member.Role))
+	}
+	return c.print(data, append(accountHeader[:len(accountHeader):len(accountHeader)], "ROLE"), rows)
+}
+
+func createOrg(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	var param service.CreateOrgParam
+	fs.StringVar(&param.Name, "name", "", "name of the organisation")
+	fs.StringVar(&param.Description, "description", "", "description of the organisation")
+	owner := fs.String("owner", "", "id or email of the user made its admin")
+	if err := c.parse(fs, args, "owner"); err != nil {
+		return err
+	}
+
+	v := validate.New()
+	v.String("name", param.Name).Required().Name()
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	_, ownerId, err := c.user(ctx, *owner)
+	if err != nil {
+		return err
+	}
+
+	org, err := c.svc.CreateOrganisationFor(ctx, cliActor, ownerId, param)
+	if err != nil {
+		return err
+	}
+	return c.print(org, []string{"ID", "NAME", "DESCRIPTION"}, [][]string{{org.Id, org.Name, org.Description}})
+}
+
+// memberFlags adds the flags naming a member of an organisation
+func memberFlags(fs *flag.FlagSet) (org *string, user *string) {
+	return fs.String("org", "", "id of the organisation"), fs.String("user", "", "id or email of the user")
+}
+
+func addMember(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	org, ref := memberFlags(fs)
+	role := fs.String("role", service.RoleMember, "role of the user, member or admin")
+	if err := c.parse(fs, args, "org", "user"); err != nil {
+		return err
+	}
+
+	orgId, err := parseOrgId(*org)
+	if err != nil {
+		return err
+	}
+	_, userId, err := c.user(ctx, *ref)
+	if err != nil {
+		return err
+	}
+
+	return c.svc.AddMember(ctx, cliActor, orgId, userId, *role)
+}
+
+func removeMember(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	org, ref := memberFlags(fs)
+	if err := c.parse(fs, args, "org", "user"); err != nil {
+		return err
+	}
+
+	orgId, err := parseOrgId(*org)
+	if err != nil {
+		return err
+	}
+	_, userId, err := c.user(ctx, *ref)
+	if err != nil {
+		return err
+	}
+
+	return c.svc.RemoveMember(ctx, cliActor, orgId, userId)
+}
+
+func setRole(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	org, ref := memberFlags(fs)
+	role := fs.String("role", "", "role of the user, member or admin")
+	if err := c.parse(fs, args, "org", "user", "role"); err != nil {
+		return err
+	}
+
+	orgId, err := parseOrgId(*org)
+	if err != nil {
+		return err
+	}
+	_, userId, err := c.user(ctx, *ref)
+	if err != nil {
+		return err
+	}
+
+	return c.svc.SetMemberRole(ctx, cliActor, orgId, userId, *role)
+}
+
+// ------ Audit Log ------ //
+
+func listAudit(ctx context.Context, c *cli, fs *flag.FlagSet, args []string) error {
+	var param service.AuditLogParam
+	target := fs.String("target", "", "only entries about this user, by id or email, or organisation, by id")
+	fs.Int64Var(&param.Before, "before", 0, "only entries before this one, to page back through the log")
+	fs.IntVar(&param.Limit, "limit", 50, "maximum number listed")
+	if err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if param.Limit < 1 || param.Before < 0 {
+		return fmt.Errorf("%w: -limit must be positive and -before can't be negative", errUsage)
+	}
+
+	if *target != "" {
+		// organisations only have ids, anything else is a user
+		if id, err := uuid.Parse(*target); err == nil {
+			param.TargetId = id
+		} else if _, param.TargetId, err = c.user(ctx, *target); err != nil {
+			return err
+		}
+	}
+
+	data, err := c.svc.GetAuditLog(ctx, param)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{}
+	for _, entry := range data.Entries {
+		actor := entry.ActorId
+		if actor == "" {
+			actor = "admin command"
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(entry.Id, 10), entry.CreatedAt.Format(time.RFC3339), actor, entry.Action, entry.TargetId, string(entry.Data),
+		})
+	}
+	return c.print(data, []string{"ID", "TIME", "ACTOR", "ACTION", "TARGET", "DATA"}, rows)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/mock"
+	"github.com/michaelcosj/hng-task-two/internal/service"
+)
+
+func newTestCli(t *testing.T) (*cli, *bytes.Buffer, service.Service) {
+	t.Helper()
+
+	svc := service.New(mock.NewMockRepo(), app.NewTokenIssuer("secret", time.Hour))
+	out := &bytes.Buffer{}
+	return newCli(svc, strings.NewReader(""), out), out, svc
+}
+
+// runJson runs args with json output and decodes what it prints into value
+func runJson(t *testing.T, c *cli, out *bytes.Buffer, value any, args ...string) {
+	t.Helper()
+
+	out.Reset()
+	if err := c.run(context.Background(), append(args, "-output", "json")); err != nil {
+		t.Fatalf("error running %v: %s", args, describeError(err))
+	}
+	if err := json.Unmarshal(out.Bytes(), value); err != nil {
+		t.Fatalf("error decoding output of %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	c, out, svc := newTestCli(t)
+	ctx := context.Background()
+
+	var created struct {
+		UserId   string `json:"userId"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	runJson(t, c, out, &created, "users", "create", "-email", "ada@example.com", "-first-name", "Ada", "-last-name", "Lovelace")
+	if created.Email != "ada@example.com" || created.Password == "" {
+		t.Fatalf("expected the user and a generated password, got %+v", created)
+	}
+
+	if _, err := svc.Login(ctx, service.LoginParams{Email: "ada@example.com", Password: created.Password}); err != nil {
+		t.Fatalf("expected to log in with the generated password, got %v", err)
+	}
+
+	var log service.AuditLogData
+	runJson(t, c, out, &log, "audit", "list", "-target", created.UserId)
+	if len(log.Entries) != 1 || log.Entries[0].Action != "user.create" || log.Entries[0].ActorId != "" {
+		t.Fatalf("expected the user to be recorded as created, got %+v", log.Entries)
+	}
+
+	t.Run("invalid user", func(t *testing.T) {
+		err := c.run(ctx, []string{"users", "create", "-email", "not an email", "-first-name", "Ada", "-last-name", "Lovelace"})
+		if !errors.Is(err, app.ErrValidation) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+	})
+
+	t.Run("multibyte password", func(t *testing.T) {
+		// 40 characters but 80 bytes, more than bcrypt takes
+		c.in = strings.NewReader(strings.Repeat("é", 40) + "\n")
+		err := c.run(ctx, []string{"users", "create", "-email", "multibyte@example.com", "-first-name", "Ada", "-last-name", "Lovelace", "-password-stdin"})
+		if !errors.Is(err, app.ErrValidation) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		c.in = strings.NewReader("password\n")
+		runJson(t, c, out, &struct{}{}, "users", "create", "-email", "grace@example.com", "-first-name", "Grace", "-last-name", "Hopper", "-password-stdin")
+
+		var list service.AccountsData
+		runJson(t, c, out, &list, "users", "list", "-search", "hopper")
+		if len(list.Accounts) != 1 || list.Accounts[0].Email != "grace@example.com" {
+			t.Fatalf("expected only grace, got %+v", list.Accounts)
+		}
+
+		out.Reset()
+		if err := c.run(ctx, []string{"users", "list"}); err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") {
+			t.Fatalf("expected a header and two users, got\n%s", out)
+		}
+	})
+
+	t.Run("reset password", func(t *testing.T) {
+		c.in = strings.NewReader("")
+		if err := c.run(ctx, []string{"users", "reset-password", "-user", "ada@example.com", "-password-stdin"}); !errors.Is(err, errUsage) {
+			t.Fatalf("expected a usage error without a password on stdin, got %v", err)
+		}
+
+		c.in = strings.NewReader("changed\n")
+		out.Reset()
+		if err := c.run(ctx, []string{"users", "reset-password", "-user", "ada@example.com", "-password-stdin"}); err != nil {
+			t.Fatal(err)
+		}
+		if out.Len() != 0 {
+			t.Fatalf("expected a password from stdin not to be printed, got %s", out)
+		}
+		if _, err := svc.Login(ctx, service.LoginParams{Email: "ada@example.com", Password: "changed"}); err != nil {
+			t.Fatalf("expected to log in with the new password, got %v", err)
+		}
+	})
+
+	t.Run("suspend", func(t *testing.T) {
+		if err := c.run(ctx, []string{"users", "suspend", "-user", created.UserId}); !errors.Is(err, app.ErrValidation) {
+			t.Fatalf("expected a reason to be required, got %v", err)
+		}
+
+		var account service.AccountData
+		runJson(t, c, out, &account, "users", "suspend", "-user", created.UserId, "-reason", "spam")
+		if account.Status != service.StatusSuspended || account.StatusReason != "spam" || account.StatusChangedAt == nil {
+			t.Fatalf("expected the account to be suspended, got %+v", account)
+		}
+
+		_, err := svc.Login(ctx, service.LoginParams{Email: "ada@example.com", Password: "changed"})
+		if !errors.Is(err, app.ErrAccountSuspended) {
+			t.Fatalf("expected a suspended account error, got %v", err)
+		}
+
+		var reactivated service.AccountData
+		runJson(t, c, out, &reactivated, "users", "reactivate", "-user", "ada@example.com")
+		if reactivated.Status != service.StatusActive || reactivated.StatusReason != "" {
+			t.Fatalf("expected the account to be active, got %+v", reactivated)
+		}
+	})
+
+	t.Run("superadmin", func(t *testing.T) {
+		var account service.AccountData
+		runJson(t, c, out, &account, "users", "grant-superadmin", "-user", "ada@example.com")
+		if !account.Superadmin {
+			t.Fatal("expected the account to be a superadmin")
+		}
+
+		var log service.AuditLogData
+		runJson(t, c, out, &log, "audit", "list", "-target", created.UserId, "-limit", "1")
+		if len(log.Entries) != 1 || log.Entries[0].Action != "user.grant_superadmin" || log.Entries[0].ActorId != "" {
+			t.Fatalf("expected the grant to be recorded without an actor, got %+v", log.Entries)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		err := c.run(ctx, []string{"users", "reactivate", "-user", "nobody@example.com"})
+		if !errors.Is(err, app.ErrUserNotFound) {
+			t.Fatalf("expected a user not found error, got %v", err)
+		}
+	})
+}
+
+func TestOrgs(t *testing.T) {
+	c, out, _ := newTestCli(t)
+
+	runJson(t, c, out, &struct{}{}, "users", "create", "-email", "owner@example.com", "-first-name", "Owner", "-last-name", "One")
+	var member struct {
+		UserId string `json:"userId"`
+	}
+	runJson(t, c, out, &member, "users", "create", "-email", "member@example.com", "-first-name", "Member", "-last-name", "Two")
+
+	var org struct {
+		OrgId string `json:"orgId"`
+	}
+	runJson(t, c, out, &org, "orgs", "create", "-name", "Analytical", "-owner", "owner@example.com")
+
+	var log service.AuditLogData
+	runJson(t, c, out, &log, "audit", "list", "-target", org.OrgId)
+	if len(log.Entries) != 1 || log.Entries[0].Action != "org.create" {
+		t.Fatalf("expected the organisation to be recorded as created, got %+v", log.Entries)
+	}
+
+	ctx := context.Background()
+	if err := c.run(ctx, []string{"orgs", "add-member", "-org", org.OrgId, "-user", "member@example.com", "-role", "admin"}); err != nil {
+		t.Fatal(describeError(err))
+	}
+
+	// the last entry in the audit log about the member
+	lastAction := func() string {
+		var log service.AuditLogData
+		runJson(t, c, out, &log, "audit", "list", "-target", member.UserId, "-limit", "1")
+		if len(log.Entries) != 1 || log.Entries[0].ActorId != "" {
+			t.Fatalf("expected an entry without an actor, got %+v", log.Entries)
+		}
+		return log.Entries[0].Action
+	}
+
+	if got := lastAction(); got != "member.add" {
+		t.Fatalf("expected the member to be recorded as added, got %s", got)
+	}
+
+	// the role the member has in org, empty when they aren't one
+	role := func() string {
+		var export service.UserExportData
+		runJson(t, c, out, &export, "users", "export", "-user", "member@example.com")
+		for _, membership := range export.Organisations {
+			if membership.Id == org.OrgId {
+				return membership.Role
+			}
+		}
+		return ""
+	}
+
+	if got := role(); got != service.RoleAdmin {
+		t.Fatalf("expected the member to be an admin of the organisation, got %q", got)
+	}
+
+	if err := c.run(ctx, []string{"orgs", "add-member", "-org", org.OrgId, "-user", "owner@example.com", "-role", "owner"}); !errors.Is(err, app.ErrValidation) {
+		t.Fatalf("expected a validation error for an unknown role, got %v", err)
+	}
+
+	if err := c.run(ctx, []string{"orgs", "set-role", "-org", org.OrgId, "-user", "member@example.com", "-role", "owner"}); !errors.Is(err, app.ErrValidation) {
+		t.Fatalf("expected a validation error for an unknown role, got %v", err)
+	}
+
+	if err := c.run(ctx, []string{"orgs", "remove-member", "-org", org.OrgId, "-user", "member@example.com"}); err != nil {
+		t.Fatal(describeError(err))
+	}
+
+	if got := lastAction(); got != "member.remove" {
+		t.Fatalf("expected the member to be recorded as removed, got %s", got)
+	}
+
+	if got := role(); got != "" {
+		t.Fatalf("expected the member to have left, got role %q", got)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	c, _, _ := newTestCli(t)
+
+	for _, args := range [][]string{
+		{},
+		{"users"},
+		{"users", "rename"},
+		{"users", "suspend"},
+		{"users", "list", "-output", "xml"},
+	} {
+		if err := c.run(context.Background(), args); !errors.Is(err, errUsage) {
+			t.Errorf("expected a usage error for %v, got %v", args, err)
+		}
+	}
+}
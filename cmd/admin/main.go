@@ -0,0 +1,81 @@
+// Command admin manages users and organisations from the command
+// line, through the same service the api uses. it is configured
+// like the api, from CONFIG_FILE and the environment
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/joho/godotenv/autoload"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/config"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/logging"
+	"github.com/michaelcosj/hng-task-two/internal/postgres"
+	"github.com/michaelcosj/hng-task-two/internal/service"
+	"github.com/michaelcosj/hng-task-two/internal/sqlite"
+)
+
+func main() {
+	cfg, err := config.Load(nil, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
+		os.Exit(2)
+	}
+
+	// only warnings and errors, the output is for people and scripts
+	logger, err := logging.New(os.Stderr, slog.LevelWarn, "text")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating logger: %v\n", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	if err := run(cfg, os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", describeError(err))
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(cfg config.Config, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	repo, closeRepo, err := openRepo(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	svc := service.New(repo, app.NewTokenIssuer(cfg.JWT.Secret, cfg.JWT.TTL))
+	return newCli(svc, os.Stdin, os.Stdout).run(ctx, args)
+}
+
+// openRepo opens the database the api is configured with
+func openRepo(ctx context.Context, cfg config.Config) (db.RepoQuerier, func(), error) {
+	switch cfg.Storage {
+	case config.StorageSQLite:
+		conn, err := sqlite.Open(ctx, cfg.SQLite.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening sqlite database: %w", err)
+		}
+		return sqlite.NewRepo(conn), func() { conn.Close() }, nil
+	case config.StorageMemory:
+		return nil, nil, errors.New("storage is memory, there is no database to manage")
+	default:
+		conn, err := postgres.Open(ctx, cfg.Database)
+		if err != nil {
+			return nil, nil, err
+		}
+		return postgres.NewRepo(conn, cfg.Database), conn.Close, nil
+	}
+}
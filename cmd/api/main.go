@@ -2,27 +2,290 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/michaelcosj/hng-task-two/database"
+	"github.com/michaelcosj/hng-task-two/internal/activity"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/buildinfo"
+	"github.com/michaelcosj/hng-task-two/internal/config"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/health"
+	"github.com/michaelcosj/hng-task-two/internal/job"
+	"github.com/michaelcosj/hng-task-two/internal/logging"
+	"github.com/michaelcosj/hng-task-two/internal/metrics"
+	"github.com/michaelcosj/hng-task-two/internal/migrate"
+	"github.com/michaelcosj/hng-task-two/internal/mock"
+	"github.com/michaelcosj/hng-task-two/internal/postgres"
+	"github.com/michaelcosj/hng-task-two/internal/ratelimit"
 	"github.com/michaelcosj/hng-task-two/internal/server"
+	"github.com/michaelcosj/hng-task-two/internal/sqlite"
+	"github.com/michaelcosj/hng-task-two/internal/tracing"
+	"github.com/michaelcosj/hng-task-two/internal/webhook"
 )
 
+// how long to wait for buffered spans to be exported on shutdown
+const tracingFlushTimeout = 2 * time.Second
+
 func main() {
-	log.Printf("Initialising database connection\n")
+	// commands other than serving are named first, with
+	// their arguments, and followed by the config flags
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	var commandArgs []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		commandArgs, args = append(commandArgs, args[0]), args[1:]
+	}
+
+	cfg, err := config.Load(args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		slog.Error("error loading config", "error", err)
+		os.Exit(2)
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		slog.Error("error creating logger", "error", err)
+		os.Exit(2)
+	}
+	// also sends anything written with the log package through the logger
+	slog.SetDefault(logger)
+
+	switch command {
+	case "serve":
+		err = run(cfg)
+	case "migrate":
+		err = runMigrate(cfg, commandArgs)
+	case "seed":
+		err = runSeed(cfg, commandArgs)
+	default:
+		err = fmt.Errorf("unknown command %q, expected serve, migrate or seed", command)
+	}
+
+	if err != nil {
+		slog.Error("exiting with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the server and background workers and blocks until the
+// process is signalled, then shuts everything down in order. errors are
+// returned rather than exiting so deferred cleanup always runs
+func run(cfg config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// TODO: find out why pgxpool seems to be slower than pgx
-	ctx := context.Background()
-	conn, err := pgxpool.New(ctx, os.Getenv("POSTGRES_URI"))
+	info := buildinfo.Get()
+	slog.Info("starting", "version", info.Version, "commit", info.Commit)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
 	if err != nil {
-		log.Fatalf("Error initialising database: %v", err)
+		return fmt.Errorf("error setting up tracing: %w", err)
+	}
+	// flushed after the database is closed so its last spans are sent
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("error flushing traces", "error", err)
+		}
+	}()
+
+	var repo db.RepoQuerier
+	// the hub listens on its own postgres connection, or
+	// to the repository for events committed in this process
+	var hub *activity.Hub
+	checks := map[string]health.CheckFunc{}
+
+	switch cfg.Storage {
+	case config.StorageSQLite:
+		slog.Info("opening sqlite database", "path", cfg.SQLite.Path)
+
+		conn, err := sqlite.Open(context.Background(), cfg.SQLite.Path)
+		if err != nil {
+			return fmt.Errorf("error opening sqlite database: %w", err)
+		}
+		defer func() {
+			slog.Info("closing sqlite database")
+			conn.Close()
+		}()
+
+		repo = sqlite.NewRepo(conn)
+		hub = activity.NewLocalHub(conn, repo)
+		checks["database"] = health.DatabaseCheck(conn)
+	case config.StorageMemory:
+		slog.Warn("keeping data in memory, it is lost when the server stops")
+
+		memory := mock.NewMockRepo()
+		repo = memory
+		hub = activity.NewLocalHub(memory.DB(), repo)
+	default:
+		slog.Info("initialising database connection")
+
+		conn, err := postgres.Open(ctx, cfg.Database)
+		if err != nil {
+			return err
+		}
+		// closed last, after everything using it has stopped
+		defer func() {
+			slog.Info("closing database connection")
+			conn.Close()
+		}()
+
+		if cfg.Database.MigrateOnStart {
+			migrator, err := migrate.New(conn)
+			if err != nil {
+				return err
+			}
+			// instances starting together wait for the first to finish
+			if err := migrator.Up(ctx); err != nil {
+				return fmt.Errorf("error migrating database: %w", err)
+			}
+		}
+
+		if err := metrics.RegisterPool(conn); err != nil {
+			return fmt.Errorf("error registering database pool metrics: %w", err)
+		}
+
+		repo = postgres.NewRepo(conn, cfg.Database)
+		hub = activity.NewHub(conn, repo)
+		checks["database"] = health.DatabaseCheck(conn)
+		checks["migrations"] = health.MigrationsCheck(conn, database.LatestVersion())
+	}
+
+	// workers get their own context so they keep running
+	// while the server drains instead of stopping on the signal
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+
+	slog.Info("starting job runner", "workers", cfg.Jobs.Workers)
+	runner := job.NewRunner(repo, cfg.Jobs.Workers)
+	runner.Register(webhook.JobPublish, webhook.PublishHandler(repo))
+	goWorker(&workers, func() { runner.Run(workersCtx) })
+
+	slog.Info("starting webhook dispatcher")
+	dispatcher := webhook.NewDispatcher(repo, nil)
+	goWorker(&workers, func() { dispatcher.Run(workersCtx) })
+
+	var limitStore ratelimit.Store
+	if cfg.RateLimit.Enabled {
+		switch cfg.RateLimit.Store {
+		case config.RateLimitStorePostgres:
+			store := ratelimit.NewPostgresStore(repo)
+			goWorker(&workers, func() { store.Run(workersCtx) })
+			limitStore = store
+		default:
+			limitStore = ratelimit.NewMemoryStore()
+		}
+		slog.Info("rate limiting requests", "store", cfg.RateLimit.Store, "auth", cfg.RateLimit.Auth.String(), "api", cfg.RateLimit.API.String())
+	}
+
+	// the hub is stopped on its own since stopping it
+	// is what ends event streams before the server drains
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	var hubDone sync.WaitGroup
+
+	slog.Info("starting organisation event hub")
+	goWorker(&hubDone, func() { hub.Run(hubCtx) })
+
+	readiness := health.NewReadiness(checks)
+	tokens := app.NewTokenIssuer(cfg.JWT.Secret, cfg.JWT.TTL)
+	httpServer := server.New(cfg, repo, tokens, hub, limitStore, readiness)
+	serverErr := make(chan error, 1)
+
+	go func() {
+		slog.Info("starting server", "addr", httpServer.Addr)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	readiness.SetReady(true)
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	case err := <-serverErr:
+		runErr = fmt.Errorf("error running server: %w", err)
 	}
-	defer conn.Close()
 
-	log.Printf("Starting server...\n")
-	if err := server.New(conn).ListenAndServe(); err != nil {
-		log.Fatalf("Error running server: %v", err)
+	// a second signal kills the process straight away
+	stop()
+
+	// 1. stop being sent new traffic
+	readiness.SetReady(false)
+	if runErr == nil {
+		time.Sleep(cfg.Shutdown.ReadinessDelay)
+	}
+
+	// 2. end event streams, they never finish on their own
+	stopHub()
+	hubDone.Wait()
+
+	// 3. let in-flight requests finish
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Shutdown.DrainTimeout)
+	defer cancelDrain()
+
+	if err := httpServer.Shutdown(drainCtx); err != nil {
+		slog.Warn("error draining server, closing remaining connections", "error", err)
+		httpServer.Close()
+	}
+
+	// 4. stop workers, letting them finish the jobs they are running
+	stopWorkers()
+	if !waitTimeout(&workers, cfg.Shutdown.WorkersTimeout) {
+		slog.Warn("background workers did not stop in time", "timeout", cfg.Shutdown.WorkersTimeout)
+	}
+
+	slog.Info("shutdown complete")
+	return runErr
+}
+
+func goWorker(wg *sync.WaitGroup, fn func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fn()
+	}()
+}
+
+// waitTimeout waits for the group, reporting false if it took longer than timeout
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
 	}
 }
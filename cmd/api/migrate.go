@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/michaelcosj/hng-task-two/internal/config"
+	"github.com/michaelcosj/hng-task-two/internal/migrate"
+	"github.com/michaelcosj/hng-task-two/internal/postgres"
+)
+
+const migrateUsage = "usage: api migrate up|down|status|to <version> [flags]"
+
+// runMigrate runs the migrate command, args are what follows it
+// before the flags
+func runMigrate(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if cfg.Database.URI == "" {
+		return errors.New("migrate needs a postgres database, set POSTGRES_URI")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	conn, err := postgres.Open(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	migrator, err := migrate.New(conn)
+	if err != nil {
+		return err
+	}
+
+	switch action := args[0]; {
+	case action == "up" && len(args) == 1:
+		return migrator.Up(ctx)
+	case action == "down" && len(args) == 1:
+		return migrator.Down(ctx)
+	case action == "to" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("%q is not a version", args[1])
+		}
+		return migrator.To(ctx, version)
+	case action == "status" && len(args) == 1:
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(os.Stdout, status)
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func printStatus(w io.Writer, status migrate.Status) {
+	fmt.Fprintf(w, "database is at version %d, the latest is %d\n", status.Version, status.Latest)
+
+	for _, migration := range status.Migrations {
+		state := "pending"
+		if migration.Applied {
+			state = "applied"
+		}
+		fmt.Fprintf(w, "  %-8s %s\n", state, migration.Name)
+	}
+}
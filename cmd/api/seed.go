@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/michaelcosj/hng-task-two/internal/config"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/fixture"
+	"github.com/michaelcosj/hng-task-two/internal/postgres"
+	"github.com/michaelcosj/hng-task-two/internal/sqlite"
+)
+
+const seedUsage = "usage: api seed <dataset.yaml> [flags]"
+
+// runSeed loads the dataset file named in args into the
+// configured storage, which must already be migrated
+func runSeed(cfg config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(seedUsage)
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("error opening dataset: %w", err)
+	}
+	defer file.Close()
+
+	data, err := fixture.ReadDataset(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var repo db.RepoQuerier
+	switch cfg.Storage {
+	case config.StorageSQLite:
+		conn, err := sqlite.Open(ctx, cfg.SQLite.Path)
+		if err != nil {
+			return fmt.Errorf("error opening sqlite database: %w", err)
+		}
+		defer conn.Close()
+
+		repo = sqlite.NewRepo(conn)
+	case config.StorageMemory:
+		return errors.New("seeding memory storage would be lost on exit, use postgres or sqlite")
+	default:
+		conn, err := postgres.Open(ctx, cfg.Database)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		repo = postgres.NewRepo(conn, cfg.Database)
+	}
+
+	stats, err := fixture.Seed(ctx, repo, data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("seeded %d users, %d organisations and %d memberships, every password is %q\n",
+		stats.Users, stats.Organisations, stats.Memberships, fixture.Password)
+	return nil
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migrations holds the tern migrations so the binary knows
+// which schema version it expects the database to be at
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+// SQLiteMigrations mirror Migrations for the sqlite backend, with
+// the same versions so both schemas can be compared
+//
+//go:embed sqlite/*.sql
+var SQLiteMigrations embed.FS
+
+// the tern separator between the up and down migration
+const downSeparator = "---- create above / drop below ----"
+
+// Migration is one tern migration file
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// false when the file has no down part, the migration can't be reverted
+	Reversible bool
+}
+
+// ReadMigrations reads the migrations in dir, sorted by version.
+// migration files are named <version>_<description>.sql
+func ReadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Migration
+	for _, entry := range entries {
+		version, ok := migrationVersion(entry.Name())
+		if !ok {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		up, down, reversible := strings.Cut(string(content), downSeparator)
+		found = append(found, Migration{
+			Version:    version,
+			Name:       entry.Name(),
+			Up:         up,
+			Down:       down,
+			Reversible: reversible,
+		})
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Version < found[j].Version })
+
+	for i, m := range found {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %s is out of sequence, expected version %d", m.Name, i+1)
+		}
+	}
+
+	return found, nil
+}
+
+// LatestVersion returns the version of the newest migration
+func LatestVersion() int {
+	entries, err := fs.ReadDir(Migrations, "migrations")
+	if err != nil {
+		return 0
+	}
+
+	latest := 0
+	for _, entry := range entries {
+		if version, ok := migrationVersion(entry.Name()); ok && version > latest {
+			latest = version
+		}
+	}
+
+	return latest
+}
+
+func migrationVersion(name string) (int, bool) {
+	if !strings.HasSuffix(name, ".sql") {
+		return 0, false
+	}
+
+	prefix, _, ok := strings.Cut(name, "_")
+	if !ok {
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(prefix)
+	return version, err == nil
+}
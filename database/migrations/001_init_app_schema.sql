@@ -24,9 +24,9 @@ CREATE TABLE user_organisations (
 );
 
 ---- create above / drop below ----
-DROP TABLE users;
-DROP TABLE organisations;
 DROP TABLE user_organisations;
+DROP TABLE organisations;
+DROP TABLE users;
 
 -- Write your migrate down statements here. If this migration is irreversible
 -- Then delete the separator line above.
@@ -0,0 +1,34 @@
+-- Write your migrate up statements here
+-- recent organisation activity, kept for a short time so
+-- event streams can resume from the last event they saw
+CREATE TABLE org_events (
+    id BIGSERIAL PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES organisations (id) ON DELETE CASCADE,
+    type VARCHAR(64) NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX org_events_org_id_idx ON org_events (org_id, id);
+CREATE INDEX org_events_created_at_idx ON org_events (created_at);
+
+-- listeners are only told which organisation has new events,
+-- they read the events themselves so nothing is lost if a
+-- notification is missed
+CREATE FUNCTION notify_org_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('org_events', NEW.org_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER org_events_notify AFTER INSERT ON org_events
+FOR EACH ROW EXECUTE FUNCTION notify_org_event();
+
+---- create above / drop below ----
+DROP TRIGGER org_events_notify ON org_events;
+DROP FUNCTION notify_org_event();
+DROP TABLE org_events;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
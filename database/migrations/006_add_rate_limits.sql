@@ -0,0 +1,18 @@
+-- Write your migrate up statements here
+-- token buckets shared by every instance. rows are small and
+-- rewritten on each request so the table is unlogged, losing
+-- the buckets in a crash only resets the limits
+CREATE UNLOGGED TABLE rate_limits (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limits_updated_at_idx ON rate_limits (updated_at);
+
+---- create above / drop below ----
+DROP TABLE rate_limits;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,9 @@
+-- Write your migrate up statements here
+-- disabled users can't log in, set by administrators
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMPTZ;
+
+---- create above / drop below ----
+ALTER TABLE users DROP COLUMN disabled_at;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
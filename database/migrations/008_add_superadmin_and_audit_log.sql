@@ -0,0 +1,28 @@
+-- Write your migrate up statements here
+-- superadmins operate the platform through the admin api, the
+-- flag is only set with the admin command
+ALTER TABLE users ADD COLUMN is_superadmin BOOLEAN NOT NULL DEFAULT FALSE;
+-- tokens carry the version they were issued at, bumping it
+-- logs the user out everywhere
+ALTER TABLE users ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
+
+-- what superadmins and the admin command did
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    -- null when the action was taken with the admin command
+    actor_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    action VARCHAR(64) NOT NULL,
+    target_id UUID,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_log_target_id_idx ON audit_log (target_id);
+
+---- create above / drop below ----
+DROP TABLE audit_log;
+ALTER TABLE users DROP COLUMN token_version;
+ALTER TABLE users DROP COLUMN is_superadmin;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
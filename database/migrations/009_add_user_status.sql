@@ -0,0 +1,25 @@
+-- Write your migrate up statements here
+-- active users can log in. suspended users were blocked by an
+-- administrator, deactivated users closed their account and can
+-- reopen it by logging in within the grace period
+ALTER TABLE users ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'suspended', 'deactivated'));
+ALTER TABLE users ADD COLUMN status_reason VARCHAR(255);
+-- when the status last changed, null if it never has
+ALTER TABLE users ADD COLUMN status_changed_at TIMESTAMPTZ;
+
+UPDATE users SET status = 'suspended', status_changed_at = disabled_at
+WHERE disabled_at IS NOT NULL;
+ALTER TABLE users DROP COLUMN disabled_at;
+
+---- create above / drop below ----
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMPTZ;
+UPDATE users SET disabled_at = coalesce(status_changed_at, now())
+WHERE status <> 'active';
+
+ALTER TABLE users DROP COLUMN status_changed_at;
+ALTER TABLE users DROP COLUMN status_reason;
+ALTER TABLE users DROP COLUMN status;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
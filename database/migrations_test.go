@@ -0,0 +1,29 @@
+package database
+
+import "testing"
+
+func TestMigrations(t *testing.T) {
+	postgres, err := ReadMigrations(Migrations, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlite, err := ReadMigrations(SQLiteMigrations, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("expected a sqlite migration for each of the %d postgres migrations, got %d", len(postgres), len(sqlite))
+	}
+
+	if latest := LatestVersion(); latest != postgres[len(postgres)-1].Version {
+		t.Fatalf("expected latest version %d, got %d", postgres[len(postgres)-1].Version, latest)
+	}
+
+	for _, m := range postgres {
+		if !m.Reversible {
+			t.Errorf("expected %s to have a down migration", m.Name)
+		}
+	}
+}
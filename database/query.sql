@@ -14,6 +14,33 @@ WHERE email = $1 LIMIT 1;
 SELECT * FROM users
 WHERE id = $1 LIMIT 1;
 
+-- name: UserSearch :many
+SELECT * FROM users
+WHERE @query::text = ''
+    OR email ILIKE '%' || @query || '%'
+    OR first_name || ' ' || last_name ILIKE '%' || @query || '%'
+ORDER BY email
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: UserUpdatePassword :execrows
+-- tokens issued with the old password stop working
+UPDATE users SET password = $2, token_version = token_version + 1
+WHERE id = $1;
+
+-- name: UserSetStatus :execrows
+-- tokens are revoked when the user leaves the active status
+UPDATE users SET status = $2, status_reason = $3, status_changed_at = now(),
+    token_version = CASE WHEN $2 = 'active' THEN token_version ELSE token_version + 1 END
+WHERE id = $1;
+
+-- name: UserSetSuperadmin :execrows
+UPDATE users SET is_superadmin = $2
+WHERE id = $1;
+
+-- name: UserRevokeTokens :execrows
+UPDATE users SET token_version = token_version + 1
+WHERE id = $1;
+
 -- name: OrgInsert :one
 INSERT INTO organisations (
     name, description
@@ -22,8 +49,26 @@ RETURNING *;
 
 -- name: UserAddOrg :exec
 INSERT INTO user_organisations (
-    user_id, org_id
-) VALUES ( $1, $2 );
+    user_id, org_id, role
+) VALUES ( $1, $2, $3 );
+
+-- name: UserRemoveOrg :execrows
+DELETE FROM user_organisations
+WHERE user_id = $1 AND org_id = $2;
+
+-- name: UserSetOrgRole :execrows
+UPDATE user_organisations SET role = $3
+WHERE user_id = $1 AND org_id = $2;
+
+-- name: OrgMemberRole :one
+SELECT role FROM user_organisations
+WHERE user_id = $1 AND org_id = $2 LIMIT 1;
+
+-- name: OrgUpdate :one
+UPDATE organisations
+SET name = $2, description = $3
+WHERE id = $1
+RETURNING *;
 
 -- name: OrganisationWhereId :one
 SELECT * FROM organisations
@@ -39,6 +84,24 @@ SELECT org.* FROM user_organisations uo
 JOIN organisations org ON uo.org_id = org.id
 WHERE uo.user_id = $1;
 
+-- name: OrgSearch :many
+SELECT * FROM organisations
+WHERE @query::text = '' OR name ILIKE '%' || @query || '%'
+ORDER BY name, id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: OrgMembersWhereOrg :many
+SELECT u.*, uo.role FROM user_organisations uo
+JOIN users u ON uo.user_id = u.id
+WHERE uo.org_id = $1
+ORDER BY u.email;
+
+-- name: OrgMembershipsWhereUser :many
+SELECT org.*, uo.role FROM user_organisations uo
+JOIN organisations org ON uo.org_id = org.id
+WHERE uo.user_id = $1
+ORDER BY org.name;
+
 -- pretty complicated query but should work
 -- gets a user if it belongs to one of another user's
 -- organisation
@@ -49,3 +112,170 @@ JOIN organisations org ON u_org.org_id = org.id
 JOIN user_organisations org_users ON org_users.org_id = org.id
 JOIN users u ON u.id = @find_user AND u.id = org_users.user_id
 WHERE auth_user.id = @auth_user;
+
+-- name: WebhookInsert :one
+INSERT INTO webhook_endpoints (
+    org_id, url, secret, events
+) VALUES ( $1, $2, $3, $4 )
+RETURNING *;
+
+-- name: WebhookAllWhereOrg :many
+SELECT * FROM webhook_endpoints
+WHERE org_id = $1
+ORDER BY created_at;
+
+-- name: WebhookWhereOrg :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1 AND org_id = $2 LIMIT 1;
+
+-- name: WebhookDelete :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND org_id = $2;
+
+-- name: WebhookAllForEvent :many
+SELECT * FROM webhook_endpoints
+WHERE org_id = @org_id AND active AND @event_type::text = ANY(events);
+
+-- name: DeliveryInsert :one
+INSERT INTO webhook_deliveries (
+    endpoint_id, event_id, event_type, payload
+) VALUES ( $1, $2, $3, $4 )
+RETURNING *;
+
+-- claims due deliveries by pushing their next attempt past the lease
+-- so other dispatchers skip them. if the claiming process dies the
+-- delivery is picked up again once the lease runs out
+-- name: DeliveryClaimDue :many
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1, next_attempt_at = @lease_until
+FROM webhook_endpoints e
+WHERE e.id = d.endpoint_id AND d.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= now()
+    ORDER BY next_attempt_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING d.*, e.url, e.secret;
+
+-- name: DeliveryUpdate :exec
+UPDATE webhook_deliveries
+SET status = $2, next_attempt_at = $3, last_status_code = $4,
+    last_error = $5, delivered_at = $6
+WHERE id = $1;
+
+-- name: DeliveryAllWhereEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: DeliveryRedeliver :one
+INSERT INTO webhook_deliveries (
+    endpoint_id, event_id, event_type, payload
+)
+SELECT endpoint_id, event_id, event_type, payload FROM webhook_deliveries
+WHERE webhook_deliveries.id = $1 AND webhook_deliveries.endpoint_id = $2
+RETURNING *;
+
+-- name: JobInsert :one
+INSERT INTO jobs (
+    kind, payload, max_attempts
+) VALUES ( $1, $2, $3 )
+RETURNING *;
+
+-- claims due jobs and jobs whose lease ran out because the
+-- worker running them died
+-- name: JobClaim :many
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_until = @locked_until
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE (status = 'pending' AND run_at <= now())
+        OR (status = 'running' AND locked_until < now())
+    ORDER BY run_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: JobComplete :exec
+UPDATE jobs
+SET status = 'completed', locked_until = NULL, last_error = NULL, finished_at = now()
+WHERE id = $1;
+
+-- name: JobRetry :exec
+UPDATE jobs
+SET status = 'pending', locked_until = NULL, run_at = $2, last_error = $3
+WHERE id = $1;
+
+-- name: JobKill :exec
+UPDATE jobs
+SET status = 'dead', locked_until = NULL, last_error = $2, finished_at = now()
+WHERE id = $1;
+
+-- name: JobPurgeCompleted :execrows
+DELETE FROM jobs
+WHERE status = 'completed' AND finished_at < $1;
+
+-- name: OrgEventInsert :one
+-- ids are taken from the sequence when an event is inserted, not when
+-- it is committed, so inserts for an organisation wait for each other's
+-- transactions to end. otherwise a stream could read an event before
+-- one with a lower id commits and skip that one
+WITH turn AS (
+    SELECT pg_advisory_xact_lock(hashtextextended($1::uuid::text, 0))
+)
+INSERT INTO org_events (
+    org_id, type, data
+) SELECT $1, $2, $3 FROM turn
+RETURNING *;
+
+-- name: OrgEventsAfter :many
+SELECT * FROM org_events
+WHERE org_id = $1 AND id > $2
+ORDER BY id
+LIMIT $3;
+
+-- name: OrgEventLatestId :one
+SELECT COALESCE(MAX(id), 0)::bigint FROM org_events
+WHERE org_id = $1;
+
+-- name: OrgEventPurge :execrows
+DELETE FROM org_events
+WHERE created_at < $1;
+
+-- name: AuditInsert :one
+INSERT INTO audit_log (
+    actor_id, action, target_id, data
+) VALUES ( $1, $2, $3, $4 )
+RETURNING *;
+
+-- name: AuditList :many
+-- newest first, before pages back through older entries
+SELECT * FROM audit_log
+WHERE (@before::bigint = 0 OR id < @before)
+    AND (sqlc.narg('target_id')::uuid IS NULL OR target_id = sqlc.narg('target_id'))
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: RateLimitTake :one
+-- refills the bucket for the time since it was last used and takes a
+-- token if there is one, in a single statement so concurrent requests
+-- from different instances can't both take the last token. the time
+-- is the database's so instances with skewed clocks agree on it
+INSERT INTO rate_limits (key, tokens, allowed, updated_at)
+VALUES (@key, @capacity::float8 - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+    tokens = CASE
+        WHEN LEAST(@capacity::float8, rate_limits.tokens + EXTRACT(EPOCH FROM (now() - rate_limits.updated_at))::float8 * @refill_rate::float8) >= 1
+        THEN LEAST(@capacity::float8, rate_limits.tokens + EXTRACT(EPOCH FROM (now() - rate_limits.updated_at))::float8 * @refill_rate::float8) - 1
+        ELSE LEAST(@capacity::float8, rate_limits.tokens + EXTRACT(EPOCH FROM (now() - rate_limits.updated_at))::float8 * @refill_rate::float8)
+    END,
+    allowed = LEAST(@capacity::float8, rate_limits.tokens + EXTRACT(EPOCH FROM (now() - rate_limits.updated_at))::float8 * @refill_rate::float8) >= 1,
+    updated_at = now()
+RETURNING tokens, allowed;
+
+-- name: RateLimitPurge :execrows
+DELETE FROM rate_limits
+WHERE updated_at < $1;
@@ -0,0 +1,42 @@
+-- Write your migrate up statements here
+-- sqlite keeps uuids as text and times as unix microseconds, and
+-- doesn't enforce varchar lengths so they are checked instead
+CREATE TABLE users (
+    id TEXT PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL,
+    first_name TEXT NOT NULL,
+    last_name TEXT NOT NULL,
+    password TEXT NOT NULL,
+    phone TEXT,
+
+    CONSTRAINT users_email_length CHECK (length(email) <= 255),
+    CONSTRAINT users_first_name_length CHECK (length(first_name) <= 255),
+    CONSTRAINT users_last_name_length CHECK (length(last_name) <= 255),
+    CONSTRAINT users_password_length CHECK (length(password) <= 255),
+    CONSTRAINT users_phone_length CHECK (length(phone) <= 16)
+);
+
+-- description is nullable from the start, sqlite can't alter
+-- columns so 002 has nothing to do
+CREATE TABLE organisations (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT,
+
+    CONSTRAINT organisations_name_length CHECK (length(name) <= 255)
+);
+
+CREATE TABLE user_organisations (
+    user_id TEXT REFERENCES users (id),
+    org_id TEXT REFERENCES organisations (id),
+
+    PRIMARY KEY (user_id, org_id)
+);
+
+---- create above / drop below ----
+DROP TABLE user_organisations;
+DROP TABLE organisations;
+DROP TABLE users;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
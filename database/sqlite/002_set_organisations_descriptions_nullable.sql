@@ -0,0 +1,7 @@
+-- Write your migrate up statements here
+-- organisations.description is created nullable in 001
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
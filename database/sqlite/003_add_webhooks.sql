@@ -0,0 +1,48 @@
+-- Write your migrate up statements here
+-- members that existed before roles were introduced could manage their
+-- organisations, so they keep that ability as admins
+ALTER TABLE user_organisations ADD COLUMN role TEXT NOT NULL DEFAULT 'member'
+    CONSTRAINT user_organisations_role_length CHECK (length(role) <= 16);
+UPDATE user_organisations SET role = 'admin';
+
+-- events is a json array of event types
+CREATE TABLE webhook_endpoints (
+    id TEXT PRIMARY KEY,
+    org_id TEXT NOT NULL REFERENCES organisations (id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL,
+    active INTEGER NOT NULL DEFAULT 1,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX webhook_endpoints_org_id_idx ON webhook_endpoints (org_id);
+
+CREATE TABLE webhook_deliveries (
+    id TEXT PRIMARY KEY,
+    endpoint_id TEXT NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload BLOB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at INTEGER NOT NULL,
+    last_status_code INTEGER,
+    last_error TEXT,
+    created_at INTEGER NOT NULL,
+    delivered_at INTEGER,
+
+    CONSTRAINT webhook_deliveries_event_type_length CHECK (length(event_type) <= 64),
+    CONSTRAINT webhook_deliveries_status_length CHECK (length(status) <= 16)
+);
+
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id, created_at DESC);
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+---- create above / drop below ----
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
+ALTER TABLE user_organisations DROP COLUMN role;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
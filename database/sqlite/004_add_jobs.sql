@@ -0,0 +1,26 @@
+-- Write your migrate up statements here
+CREATE TABLE jobs (
+    id TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload BLOB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at INTEGER NOT NULL,
+    locked_until INTEGER,
+    last_error TEXT,
+    created_at INTEGER NOT NULL,
+    finished_at INTEGER,
+
+    CONSTRAINT jobs_kind_length CHECK (length(kind) <= 64),
+    CONSTRAINT jobs_status_length CHECK (length(status) <= 16)
+);
+
+CREATE INDEX jobs_pending_idx ON jobs (run_at) WHERE status = 'pending';
+CREATE INDEX jobs_running_idx ON jobs (locked_until) WHERE status = 'running';
+
+---- create above / drop below ----
+DROP TABLE jobs;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
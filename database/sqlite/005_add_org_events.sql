@@ -0,0 +1,23 @@
+-- Write your migrate up statements here
+-- recent organisation activity, kept for a short time so
+-- event streams can resume from the last event they saw.
+-- there is no trigger, the repository tells listeners in
+-- the same process about committed events
+CREATE TABLE org_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    org_id TEXT NOT NULL REFERENCES organisations (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    data BLOB NOT NULL,
+    created_at INTEGER NOT NULL,
+
+    CONSTRAINT org_events_type_length CHECK (length(type) <= 64)
+);
+
+CREATE INDEX org_events_org_id_idx ON org_events (org_id, id);
+CREATE INDEX org_events_created_at_idx ON org_events (created_at);
+
+---- create above / drop below ----
+DROP TABLE org_events;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
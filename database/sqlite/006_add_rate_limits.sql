@@ -0,0 +1,15 @@
+-- Write your migrate up statements here
+CREATE TABLE rate_limits (
+    key TEXT PRIMARY KEY,
+    tokens REAL NOT NULL,
+    allowed INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE INDEX rate_limits_updated_at_idx ON rate_limits (updated_at);
+
+---- create above / drop below ----
+DROP TABLE rate_limits;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
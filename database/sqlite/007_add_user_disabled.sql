@@ -0,0 +1,8 @@
+-- Write your migrate up statements here
+ALTER TABLE users ADD COLUMN disabled_at INTEGER;
+
+---- create above / drop below ----
+ALTER TABLE users DROP COLUMN disabled_at;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
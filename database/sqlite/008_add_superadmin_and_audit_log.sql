@@ -0,0 +1,24 @@
+-- Write your migrate up statements here
+ALTER TABLE users ADD COLUMN is_superadmin INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id TEXT REFERENCES users (id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    target_id TEXT,
+    data BLOB NOT NULL,
+    created_at INTEGER NOT NULL,
+
+    CONSTRAINT audit_log_action_length CHECK (length(action) <= 64)
+);
+
+CREATE INDEX audit_log_target_id_idx ON audit_log (target_id);
+
+---- create above / drop below ----
+DROP TABLE audit_log;
+ALTER TABLE users DROP COLUMN token_version;
+ALTER TABLE users DROP COLUMN is_superadmin;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,22 @@
+-- Write your migrate up statements here
+ALTER TABLE users ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+    CONSTRAINT users_status_check CHECK (status IN ('active', 'suspended', 'deactivated'));
+ALTER TABLE users ADD COLUMN status_reason TEXT
+    CONSTRAINT users_status_reason_length CHECK (length(status_reason) <= 255);
+ALTER TABLE users ADD COLUMN status_changed_at INTEGER;
+
+UPDATE users SET status = 'suspended', status_changed_at = disabled_at
+WHERE disabled_at IS NOT NULL;
+ALTER TABLE users DROP COLUMN disabled_at;
+
+---- create above / drop below ----
+ALTER TABLE users ADD COLUMN disabled_at INTEGER;
+UPDATE users SET disabled_at = coalesce(status_changed_at, CAST(strftime('%s', 'now') AS INTEGER) * 1000000)
+WHERE status <> 'active';
+
+ALTER TABLE users DROP COLUMN status_changed_at;
+ALTER TABLE users DROP COLUMN status_reason;
+ALTER TABLE users DROP COLUMN status;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
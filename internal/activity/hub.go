@@ -0,0 +1,223 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// Channel is the postgres notification channel the org_events
+// trigger notifies with the id of the organisation
+const Channel = "org_events"
+
+const (
+	reconnectDelay = 5 * time.Second
+
+	purgeInterval = 10 * time.Minute
+	// events are only kept long enough for
+	// disconnected streams to catch up
+	Retention = time.Hour
+)
+
+// Hub listens for organisation event notifications and wakes the
+// streams subscribed to that organisation. notifications only say
+// that an organisation has new events, streams read the events from
+// the org_events table so the hub works across many instances and
+// a missed notification never loses an event
+type Hub struct {
+	pool *pgxpool.Pool
+	// used instead of the pool when events aren't kept in postgres
+	notifier Notifier
+	repo     db.Querier
+
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan struct{}]struct{}
+	closed      bool
+}
+
+func NewHub(pool *pgxpool.Pool, repo db.Querier) *Hub {
+	return &Hub{
+		pool:        pool,
+		repo:        repo,
+		subscribers: make(map[uuid.UUID]map[chan struct{}]struct{}),
+	}
+}
+
+// Notifier calls fn with the organisation of every
+// committed event, for storage without LISTEN/NOTIFY
+type Notifier interface {
+	Listen(fn func(orgId uuid.UUID)) (stop func())
+}
+
+// NewLocalHub creates a hub woken by notifier instead of postgres,
+// it only sees events recorded by the same process
+func NewLocalHub(notifier Notifier, repo db.Querier) *Hub {
+	return &Hub{
+		notifier:    notifier,
+		repo:        repo,
+		subscribers: make(map[uuid.UUID]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives a value whenever the
+// organisation may have new events, and a function to unsubscribe.
+// the channel is closed when the hub stops so streams can end
+// and let the server shut down
+func (h *Hub) Subscribe(orgId uuid.UUID) (<-chan struct{}, func()) {
+	// buffered so a wake up is never lost while the
+	// subscriber is busy and never blocks the hub
+	wake := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(wake)
+		return wake, func() {}
+	}
+	if h.subscribers[orgId] == nil {
+		h.subscribers[orgId] = make(map[chan struct{}]struct{})
+	}
+	h.subscribers[orgId][wake] = struct{}{}
+	h.mu.Unlock()
+
+	return wake, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.subscribers[orgId], wake)
+		if len(h.subscribers[orgId]) == 0 {
+			delete(h.subscribers, orgId)
+		}
+	}
+}
+
+func (h *Hub) wake(orgId uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for wake := range h.subscribers[orgId] {
+		notify(wake)
+	}
+}
+
+func (h *Hub) wakeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subscribers := range h.subscribers {
+		for wake := range subscribers {
+			notify(wake)
+		}
+	}
+}
+
+func (h *Hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for orgId, subscribers := range h.subscribers {
+		for wake := range subscribers {
+			close(wake)
+		}
+		delete(h.subscribers, orgId)
+	}
+}
+
+func notify(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run listens for notifications until the context is cancelled,
+// reconnecting whenever the listening connection is lost
+func (h *Hub) Run(ctx context.Context) {
+	defer h.close()
+	go h.purge(ctx)
+
+	for {
+		err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.Error("error listening for organisation events, reconnecting", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (h *Hub) listen(ctx context.Context) error {
+	if h.notifier != nil {
+		stop := h.notifier.Listen(h.wake)
+		defer stop()
+
+		h.wakeAll()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	conn, err := h.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+
+	// the connection is kept for as long as the hub listens so take
+	// it out of the pool instead of returning it in a LISTEN state
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.WithoutCancel(ctx))
+
+	if _, err := pgConn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return fmt.Errorf("error listening on %s: %w", Channel, err)
+	}
+
+	// anything recorded while the hub was not listening would
+	// otherwise only be seen on the subscribers' next heartbeat
+	h.wakeAll()
+
+	for {
+		notification, err := pgConn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		orgId, err := uuid.Parse(notification.Payload)
+		if err != nil {
+			slog.Warn("invalid organisation event notification", "payload", notification.Payload, "error", err)
+			continue
+		}
+
+		h.wake(orgId)
+	}
+}
+
+func (h *Hub) purge(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		before := pgtype.Timestamptz{Time: time.Now().Add(-Retention), Valid: true}
+		if _, err := h.repo.OrgEventPurge(ctx, before); err != nil && ctx.Err() == nil {
+			slog.Error("error purging organisation events", "error", err)
+		}
+	}
+}
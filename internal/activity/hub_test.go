@@ -0,0 +1,94 @@
+package activity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/mock"
+)
+
+func TestHubWakesSubscribersOfOrganisation(t *testing.T) {
+	hub := NewHub(nil, nil)
+	orgId := uuid.New()
+
+	wake, unsubscribe := hub.Subscribe(orgId)
+	defer unsubscribe()
+
+	other, unsubscribeOther := hub.Subscribe(uuid.New())
+	defer unsubscribeOther()
+
+	// repeated wake ups collapse into one while the subscriber is busy
+	hub.wake(orgId)
+	hub.wake(orgId)
+
+	select {
+	case <-wake:
+	default:
+		t.Fatal("subscriber was not woken")
+	}
+
+	select {
+	case <-wake:
+		t.Fatal("subscriber should only be woken once")
+	default:
+	}
+
+	select {
+	case <-other:
+		t.Fatal("subscriber of another organisation should not be woken")
+	default:
+	}
+}
+
+func TestHubCloseEndsSubscriptions(t *testing.T) {
+	hub := NewHub(nil, nil)
+
+	wake, unsubscribe := hub.Subscribe(uuid.New())
+	hub.close()
+	unsubscribe()
+
+	if _, ok := <-wake; ok {
+		t.Fatal("subscription should be closed when the hub stops")
+	}
+
+	late, _ := hub.Subscribe(uuid.New())
+	if _, ok := <-late; ok {
+		t.Fatal("subscribing to a stopped hub should return a closed channel")
+	}
+}
+
+func TestLocalHubWakesOnCommittedEvents(t *testing.T) {
+	repo := mock.NewMockRepo()
+	org, err := repo.OrgInsert(context.Background(), db.OrgInsertParams{Name: "Acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub := NewLocalHub(repo.DB(), repo)
+	wake, unsubscribe := hub.Subscribe(org.ID)
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	// every subscriber is woken once the hub starts listening
+	select {
+	case <-wake:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber was not woken when the hub started")
+	}
+
+	if _, err := repo.OrgEventInsert(context.Background(), db.OrgEventInsertParams{OrgID: org.ID, Type: "org.updated", Data: []byte("{}")}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-wake:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber was not woken by the event")
+	}
+}
@@ -1,126 +1,208 @@
 package app
 
 import (
-	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"strings"
+
+	"github.com/michaelcosj/hng-task-two/internal/tracing"
 )
 
 var (
 	ErrUserAlreadyExists    = errors.New("User already exists")
 	ErrAuthenticationFailed = errors.New("Authentication Failed")
+	ErrAccountSuspended     = errors.New("Account suspended")
+	ErrAccountDeactivated   = errors.New("Account deactivated")
+	ErrUnauthenticated      = errors.New("Not authenticated")
+	ErrInvalidToken         = errors.New("Invalid token")
 	ErrUserNotFound         = errors.New("User does not exist")
 	ErrOrgNotFound          = errors.New("Organisation does not exist")
+	ErrUserAlreadyMember    = errors.New("User is already a member")
 	ErrClientError          = errors.New("Client error")
+	ErrInvalidJson          = errors.New("Invalid json")
+	ErrValidation           = errors.New("Validation failed")
+	ErrForbidden            = errors.New("Forbidden")
+	ErrWebhookNotFound      = errors.New("Webhook does not exist")
+	ErrDeliveryNotFound     = errors.New("Webhook delivery does not exist")
+	ErrRateLimited          = errors.New("Rate limited")
+	ErrInternal             = errors.New("Internal error")
+	ErrUnsupportedMediaType = errors.New("Unsupported media type")
+	ErrBodyTooLarge         = errors.New("Request body too large")
 )
 
-type validationErrorItem struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
+const ProblemContentType = "application/problem+json"
+
+// ErrorCode is the stable, machine readable identity of an error.
+// clients match on Code, the message is only meant for people
+type ErrorCode struct {
+	Code       string
+	StatusCode int
+	Message    string
 }
 
-type ApiValidationError struct {
-	Errors []validationErrorItem `json:"errors"`
+// Catalogue maps every error the api reports to its code. codes are part
+// of the api, once published they must not change meaning
+var Catalogue = []struct {
+	Err  error
+	Code ErrorCode
+}{
+	{ErrUserAlreadyExists, ErrorCode{"user.already_exists", http.StatusUnprocessableEntity, "A user with this email already exists"}},
+	{ErrAuthenticationFailed, ErrorCode{"auth.invalid_credentials", http.StatusUnauthorized, "Authentication failed"}},
+	// suspension was called disabling before accounts had a status
+	{ErrAccountSuspended, ErrorCode{"auth.account_suspended", http.StatusForbidden, "This account has been suspended"}},
+	{ErrAccountDeactivated, ErrorCode{"auth.account_deactivated", http.StatusForbidden, "This account has been deactivated"}},
+	{ErrUnauthenticated, ErrorCode{"auth.unauthenticated", http.StatusUnauthorized, "Not authorised to access this resource"}},
+	{ErrInvalidToken, ErrorCode{"auth.invalid_token", http.StatusUnauthorized, "JWT token is invalid or expired"}},
+	{ErrForbidden, ErrorCode{"auth.forbidden", http.StatusForbidden, "Not allowed to perform this action"}},
+	{ErrUserNotFound, ErrorCode{"user.not_found", http.StatusNotFound, "User not found"}},
+	{ErrOrgNotFound, ErrorCode{"org.not_found", http.StatusNotFound, "Organisation not found"}},
+	{ErrUserAlreadyMember, ErrorCode{"org.member_exists", http.StatusConflict, "User is already a member of this organisation"}},
+	{ErrWebhookNotFound, ErrorCode{"webhook.not_found", http.StatusNotFound, "Webhook not found"}},
+	{ErrDeliveryNotFound, ErrorCode{"webhook.delivery_not_found", http.StatusNotFound, "Webhook delivery not found"}},
+	{ErrClientError, ErrorCode{"request.invalid", http.StatusBadRequest, "Client error"}},
+	{ErrInvalidJson, ErrorCode{"request.invalid_json", http.StatusBadRequest, "Invalid json data"}},
+	{ErrUnsupportedMediaType, ErrorCode{"request.unsupported_media_type", http.StatusUnsupportedMediaType, "Request body must be application/json"}},
+	{ErrBodyTooLarge, ErrorCode{"request.body_too_large", http.StatusRequestEntityTooLarge, "Request body is too large"}},
+	{ErrValidation, ErrorCode{"request.validation_failed", http.StatusUnprocessableEntity, "Validation failed"}},
+	{ErrRateLimited, ErrorCode{"request.rate_limited", http.StatusTooManyRequests, "Rate limit exceeded, try again later"}},
+	{ErrInternal, ErrorCode{"internal", http.StatusInternalServerError, "Something went wrong"}},
 }
 
-func NewValidationError(problems map[string]string) ApiValidationError {
-	err := ApiValidationError{}
-	for field, message := range problems {
-		err.Errors = append(err.Errors, validationErrorItem{field, message})
+func codeFor(err error) (ErrorCode, bool) {
+	for _, entry := range Catalogue {
+		if errors.Is(err, entry.Err) {
+			return entry.Code, true
+		}
 	}
-	return err
+	return ErrorCode{}, false
 }
 
-func (e ApiValidationError) Error() string {
-	buf := new(bytes.Buffer)
-	for _, item := range e.Errors {
-		fmt.Fprintf(buf, "{%s:%s}\t", item.Field, item.Message)
-	}
-	fmt.Fprintf(buf, "\n")
-	return buf.String()
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
+// ApiError is an error that is reported to the client. it is
+// written as an RFC 9457 problem details response
 type ApiError struct {
-	Status     string `json:"status"`
-	Message    string `json:"message"`
-	StatusCode int    `json:"statusCode"`
+	ErrorCode
+	// overrides the message of the code when set
+	Detail string
+	// problems with each field, written in the errors
+	// array validation failures are expected to have
+	Errors     []FieldError
 	wrappedErr error
 }
 
 func (e ApiError) Error() string {
-	return fmt.Sprintf("{status: %s, message: %s, code: %d, error: %s}\n", e.Status, e.Message, e.StatusCode, e.wrappedErr)
+	return fmt.Sprintf("{code: %s, status: %d, message: %s, error: %v}", e.Code, e.StatusCode, e.message(), e.wrappedErr)
 }
 
-func (e ApiError) Is(target error) bool {
-	_, ok := target.(ApiError)
-	return ok
+func (e ApiError) Unwrap() error {
+	return e.wrappedErr
 }
 
-func NewApiError(status, message string, statusCode int) ApiError {
-	return ApiError{status, message, statusCode, nil}
+func (e ApiError) message() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	return e.Message
 }
 
-func InvalidJson() ApiError {
-	return ApiError{
-		Status:     "Bad Request",
-		Message:    "Invalid json data",
-		StatusCode: http.StatusBadRequest,
+func newApiError(sentinel error, wrapped error) ApiError {
+	code, ok := codeFor(sentinel)
+	if !ok {
+		panic(fmt.Sprintf("error %q is missing from the catalogue", sentinel))
 	}
+	return ApiError{ErrorCode: code, wrappedErr: wrapped}
+}
+
+// NewValidationError reports problems with the request data, in the
+// order given so responses are stable
+func NewValidationError(problems []FieldError) ApiError {
+	err := newApiError(ErrValidation, ErrValidation)
+	err.Errors = problems
+	return err
+}
+
+// InvalidJson reports a body that could not be decoded, detail says
+// why when set and problems point at the fields that were wrong
+func InvalidJson(detail string, problems ...FieldError) ApiError {
+	err := newApiError(ErrInvalidJson, ErrInvalidJson)
+	err.Detail = detail
+	err.Errors = problems
+	return err
 }
 
 func InvalidRequestData(err error) ApiError {
-	return ApiError{
-		Status:     "Bad Request",
-		Message:    "Client Error",
-		StatusCode: http.StatusBadRequest,
-		wrappedErr: err,
-	}
+	return newApiError(ErrClientError, errors.Join(err, ErrClientError))
 }
 
+// ApiErrorFrom turns errors wrapping a catalogued error into an ApiError,
+// anything else is returned as is and reported as an internal error
 func ApiErrorFrom(err error) error {
+	var apiError ApiError
+	if errors.As(err, &apiError) {
+		return apiError
+	}
 
-	switch {
-	case errors.Is(ApiError{}, err):
-		return err
-	case errors.Is(err, ErrUserAlreadyExists):
-		return ApiError{
-			Status:     "User already exists",
-			Message:    "A user with this email already exists",
-			StatusCode: http.StatusUnprocessableEntity,
-			wrappedErr: err,
-		}
-	case errors.Is(err, ErrAuthenticationFailed):
-		return ApiError{
-			Status:     "Bad request",
-			Message:    "Authentication failed",
-			StatusCode: http.StatusUnauthorized,
-			wrappedErr: err,
-		}
-	case errors.Is(err, ErrUserNotFound):
-		return ApiError{
-			Status:     "Not Found",
-			Message:    "User not found",
-			StatusCode: http.StatusNotFound,
-			wrappedErr: err,
-		}
-	case errors.Is(err, ErrOrgNotFound):
-		return ApiError{
-			Status:     "Not found",
-			Message:    "Organisation not found",
-			StatusCode: http.StatusNotFound,
-			wrappedErr: err,
-		}
-	case errors.Is(err, ErrClientError):
-		return ApiError{
-			Status:     "Bad request",
-			Message:    "Client error",
-			StatusCode: http.StatusBadRequest,
-			wrappedErr: err,
-		}
-	default:
-		log.Printf("unknown error encountered: %v", err)
+	code, ok := codeFor(err)
+	if !ok {
+		// unknown errors are logged by the caller with the request context
 		return err
 	}
+
+	return ApiError{ErrorCode: code, wrappedErr: err}
+}
+
+// AsApiError is ApiErrorFrom for errors about to be written,
+// unknown errors become internal errors that reveal nothing
+func AsApiError(err error) ApiError {
+	if apiError, ok := ApiErrorFrom(err).(ApiError); ok {
+		return apiError
+	}
+	return newApiError(ErrInternal, err)
+}
+
+// Problem is the RFC 9457 body errors are written as. code, traceId
+// and errors are extension members
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	TraceId  string       `json:"traceId,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+func (e ApiError) Problem(r *http.Request) Problem {
+	// the original uri since nested routes strip their prefix from the
+	// path, without the query which could hold something sensitive
+	instance, _, _ := strings.Cut(r.RequestURI, "?")
+
+	return Problem{
+		// problems are identified by code so the type is about:blank
+		// and the title is the status text, as the rfc asks
+		Type:     "about:blank",
+		Title:    http.StatusText(e.StatusCode),
+		Status:   e.StatusCode,
+		Detail:   e.message(),
+		Instance: instance,
+		Code:     e.Code,
+		TraceId:  tracing.TraceId(r.Context()),
+		Errors:   e.Errors,
+	}
+}
+
+// WriteError writes err as a problem details response
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := AsApiError(err).Problem(r)
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
 }
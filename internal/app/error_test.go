@@ -0,0 +1,162 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// sentinelNames finds the Err variables declared in the package so
+// a new sentinel can't be added without a code
+func sentinelNames(t *testing.T) []string {
+	t.Helper()
+
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+
+			for _, spec := range gen.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					if strings.HasPrefix(name.Name, "Err") {
+						names = append(names, name.Name)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+func TestErrorCatalogue(t *testing.T) {
+	if names := sentinelNames(t); len(names) != len(Catalogue) {
+		t.Errorf("%d sentinel errors %v but %d catalogue entries, every sentinel needs exactly one code", len(names), names, len(Catalogue))
+	}
+
+	codePattern := regexp.MustCompile(`^[a-z]+(\.[a-z_]+)?$`)
+	codes := make(map[string]bool)
+	sentinels := make(map[error]bool)
+
+	for _, entry := range Catalogue {
+		if sentinels[entry.Err] {
+			t.Errorf("%q is in the catalogue more than once", entry.Err)
+		}
+		sentinels[entry.Err] = true
+
+		if codes[entry.Code.Code] {
+			t.Errorf("code %s is used by more than one error", entry.Code.Code)
+		}
+		codes[entry.Code.Code] = true
+
+		if !codePattern.MatchString(entry.Code.Code) {
+			t.Errorf("code %s should look like area.reason", entry.Code.Code)
+		}
+
+		if http.StatusText(entry.Code.StatusCode) == "" || entry.Code.StatusCode < 400 {
+			t.Errorf("code %s has invalid status %d", entry.Code.Code, entry.Code.StatusCode)
+		}
+
+		// wrapped the way the service wraps them
+		wrapped := fmt.Errorf("error doing something: %w", entry.Err)
+		apiError, ok := ApiErrorFrom(wrapped).(ApiError)
+		if !ok || apiError.Code != entry.Code.Code {
+			t.Errorf("%q wrapped maps to %v, want %s", entry.Err, ApiErrorFrom(wrapped), entry.Code.Code)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+		wantErrors int
+	}{
+		{
+			name:       "Test catalogued error",
+			err:        ApiErrorFrom(fmt.Errorf("error retrieving organisation: %w", ErrOrgNotFound)),
+			wantStatus: http.StatusNotFound,
+			wantCode:   "org.not_found",
+			wantDetail: "Organisation not found",
+		},
+		{
+			name:       "Test validation errors keep the errors array",
+			err:        NewValidationError([]FieldError{{"firstName", "is required"}, {"email", "must be a valid email address"}}),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "request.validation_failed",
+			wantDetail: "Validation failed",
+			wantErrors: 2,
+		},
+		{
+			name:       "Test unknown errors reveal nothing",
+			err:        errors.New("pq: connection refused to 10.0.0.3"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "internal",
+			wantDetail: "Something went wrong",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			WriteError(resp, httptest.NewRequest("GET", "/api/organisations/1?token=secret", nil), test.err)
+
+			if resp.Code != test.wantStatus {
+				t.Fatalf("expected %d got %d", test.wantStatus, resp.Code)
+			}
+
+			if ct := resp.Header().Get("Content-Type"); ct != ProblemContentType {
+				t.Errorf("expected %s got %s", ProblemContentType, ct)
+			}
+
+			var problem Problem
+			if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+				t.Fatal(err)
+			}
+
+			if problem.Code != test.wantCode || problem.Detail != test.wantDetail || problem.Status != test.wantStatus {
+				t.Errorf("unexpected problem %+v", problem)
+			}
+
+			if problem.Title != http.StatusText(test.wantStatus) || problem.Type != "about:blank" {
+				t.Errorf("unexpected type or title %+v", problem)
+			}
+
+			if problem.Instance != "/api/organisations/1" {
+				t.Errorf("expected instance without the query, got %q", problem.Instance)
+			}
+
+			if len(problem.Errors) != test.wantErrors {
+				t.Errorf("expected %d field errors got %v", test.wantErrors, problem.Errors)
+			}
+		})
+	}
+}
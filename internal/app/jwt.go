@@ -2,22 +2,32 @@ package app
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var secretKey = os.Getenv("JWT_SECRET")
+// TokenIssuer creates and verifies the access tokens handed out on login
+type TokenIssuer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewTokenIssuer(secret string, ttl time.Duration) *TokenIssuer {
+	return &TokenIssuer{secret: []byte(secret), ttl: ttl}
+}
 
-func CreateToken(userId string) (string, error) {
+// CreateToken issues a token for the user. version is the user's token
+// version, tokens stop being accepted once the user's version moves on
+func (t *TokenIssuer) CreateToken(userId string, version int32) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
 		jwt.MapClaims{
 			"id":  userId,
-			"exp": time.Now().Add(time.Hour * 24).Unix(),
+			"ver": version,
+			"exp": time.Now().Add(t.ttl).Unix(),
 		})
 
-	tokenString, err := token.SignedString([]byte(secretKey))
+	tokenString, err := token.SignedString(t.secret)
 	if err != nil {
 		return "", err
 	}
@@ -25,10 +35,12 @@ func CreateToken(userId string) (string, error) {
 	return tokenString, nil
 }
 
-func VerifyToken(tokenString string) (*jwt.Token, error) {
+func (t *TokenIssuer) VerifyToken(tokenString string) (*jwt.Token, error) {
+	// only accept the algorithm tokens are signed with so a token
+	// can't pick a weaker one or none at all
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	})
+		return t.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,25 @@
+// Package backoff spaces out retries of work that failed
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Exponential returns how long to wait before retrying work that has
+// failed the given number of attempts. the delay starts at base and
+// doubles with each attempt up to max, then a random amount of up to
+// half of it is taken off so retries that failed together spread out
+func Exponential(attempts int32, base, max time.Duration) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+
+	delay := base
+	for i := int32(1); i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, max)
+
+	return delay - rand.N(delay/2+1)
+}
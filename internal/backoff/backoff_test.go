@@ -0,0 +1,29 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponential(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{20, 6 * time.Hour},
+	}
+
+	for _, test := range tests {
+		for range 20 {
+			got := Exponential(test.attempts, 30*time.Second, 6*time.Hour)
+			if got > test.want || got < test.want/2 {
+				t.Fatalf("backoff after %d attempts: want between %v and %v, got %v", test.attempts, test.want/2, test.want, got)
+			}
+		}
+	}
+}
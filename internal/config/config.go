@@ -0,0 +1,209 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"time"
+
+	"github.com/michaelcosj/hng-task-two/internal/logging"
+	"github.com/michaelcosj/hng-task-two/internal/ratelimit"
+	"github.com/michaelcosj/hng-task-two/internal/tracing"
+)
+
+// minimum length of the jwt signing secret, HS256
+// secrets shorter than the hash output are guessable
+const minJWTSecretLength = 32
+
+type Config struct {
+	Port int
+	// where the data is kept, see the Storage constants
+	Storage string
+
+	Log       LogConfig
+	Tracing   TracingConfig
+	RateLimit RateLimitConfig
+	Database  DatabaseConfig
+	SQLite    SQLiteConfig
+	JWT       JWTConfig
+	Jobs      JobsConfig
+	Shutdown  ShutdownConfig
+}
+
+// where the data is kept. sqlite keeps it in a file and memory keeps
+// it in the process, losing it on exit. both are for development,
+// demos and tests that don't need postgres
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
+type LogConfig struct {
+	Level  slog.Level
+	Format string
+}
+
+type TracingConfig struct {
+	Exporter    string
+	ServiceName string
+	SampleRatio float64
+}
+
+// where rate limit buckets are kept
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+)
+
+type RateLimitConfig struct {
+	Enabled bool
+	// memory limits each instance on its own, postgres
+	// shares the limits between every instance
+	Store string
+	// proxies whose forwarding headers are trusted for the client address
+	TrustedProxies []netip.Prefix
+	Auth           ratelimit.Limit
+	API            ratelimit.Limit
+}
+
+type DatabaseConfig struct {
+	URI string
+	// apply migrations when the server starts instead
+	// of running the migrate command before deploying
+	MigrateOnStart bool
+
+	MaxConns int
+	// connections kept open when idle, so requests after a quiet
+	// spell don't wait for new ones to be established
+	MinConns          int
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	// longest a statement may run on the server, 0 for no limit
+	StatementTimeout time.Duration
+	// longest a query may take including the wait for a
+	// connection, on top of the caller's deadline, 0 for no limit
+	QueryTimeout time.Duration
+	// how long to keep retrying when postgres isn't up at startup
+	ConnectTimeout time.Duration
+}
+
+type SQLiteConfig struct {
+	Path string
+}
+
+type JWTConfig struct {
+	Secret string
+	TTL    time.Duration
+}
+
+type JobsConfig struct {
+	Workers int
+}
+
+// ShutdownConfig times the shutdown steps, the defaults together
+// stay within the kill_timeout in fly.toml
+type ShutdownConfig struct {
+	// how long to report unready before draining so
+	// the load balancer stops sending requests
+	ReadinessDelay time.Duration
+	DrainTimeout   time.Duration
+	WorkersTimeout time.Duration
+}
+
+// Validate reports every problem with the config at once
+func (c Config) Validate() error {
+	var problems []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, fmt.Errorf("port must be between 1 and 65535, got %d", c.Port))
+	}
+
+	switch c.Storage {
+	case StoragePostgres, StorageMemory:
+	case StorageSQLite:
+		if c.SQLite.Path == "" {
+			problems = append(problems, errors.New("sqlite path must be set (SQLITE_PATH)"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("storage must be %s, %s or %s, got %q", StoragePostgres, StorageSQLite, StorageMemory, c.Storage))
+	}
+
+	if c.Log.Format != logging.FormatJSON && c.Log.Format != logging.FormatText {
+		problems = append(problems, fmt.Errorf("log format must be %s or %s, got %q", logging.FormatJSON, logging.FormatText, c.Log.Format))
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		problems = append(problems, fmt.Errorf("tracing exporter must be %s, %s or %s, got %q", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP, c.Tracing.Exporter))
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, fmt.Errorf("tracing sample ratio must be between 0 and 1, got %v", c.Tracing.SampleRatio))
+	}
+
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Store != RateLimitStoreMemory && c.RateLimit.Store != RateLimitStorePostgres {
+			problems = append(problems, fmt.Errorf("rate limit store must be %s or %s, got %q", RateLimitStoreMemory, RateLimitStorePostgres, c.RateLimit.Store))
+		}
+
+		if err := c.RateLimit.Auth.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("auth rate limit: %w", err))
+		}
+
+		if err := c.RateLimit.API.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("api rate limit: %w", err))
+		}
+	}
+
+	if c.Storage == StoragePostgres && c.Database.URI == "" {
+		problems = append(problems, errors.New("database uri must be set (POSTGRES_URI or POSTGRES_URI_FILE)"))
+	}
+
+	if c.Database.MaxConns < 1 {
+		problems = append(problems, fmt.Errorf("database max conns must be at least 1, got %d", c.Database.MaxConns))
+	}
+
+	if c.Database.MinConns < 0 || c.Database.MinConns > c.Database.MaxConns {
+		problems = append(problems, fmt.Errorf("database min conns must be between 0 and max conns (%d), got %d", c.Database.MaxConns, c.Database.MinConns))
+	}
+
+	if c.Database.MaxConnLifetime <= 0 || c.Database.MaxConnIdleTime <= 0 || c.Database.HealthCheckPeriod <= 0 {
+		problems = append(problems, errors.New("database max conn lifetime, max conn idle time and health check period must be positive"))
+	}
+
+	if c.Database.StatementTimeout < 0 || c.Database.QueryTimeout < 0 || c.Database.ConnectTimeout < 0 {
+		problems = append(problems, errors.New("database statement, query and connect timeouts cannot be negative"))
+	}
+
+	if len(c.JWT.Secret) == 0 {
+		problems = append(problems, errors.New("jwt secret must be set (JWT_SECRET or JWT_SECRET_FILE)"))
+	} else if len(c.JWT.Secret) < minJWTSecretLength {
+		problems = append(problems, fmt.Errorf("jwt secret must be at least %d characters, got %d", minJWTSecretLength, len(c.JWT.Secret)))
+	}
+
+	if c.JWT.TTL <= 0 {
+		problems = append(problems, fmt.Errorf("jwt ttl must be positive, got %v", c.JWT.TTL))
+	}
+
+	if c.Jobs.Workers < 1 {
+		problems = append(problems, fmt.Errorf("job workers must be at least 1, got %d", c.Jobs.Workers))
+	}
+
+	if c.Shutdown.ReadinessDelay < 0 {
+		problems = append(problems, fmt.Errorf("shutdown readiness delay cannot be negative, got %v", c.Shutdown.ReadinessDelay))
+	}
+
+	if c.Shutdown.DrainTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("shutdown drain timeout must be positive, got %v", c.Shutdown.DrainTimeout))
+	}
+
+	if c.Shutdown.WorkersTimeout <= 0 {
+		problems = append(problems, fmt.Errorf("shutdown workers timeout must be positive, got %v", c.Shutdown.WorkersTimeout))
+	}
+
+	return errors.Join(problems...)
+}
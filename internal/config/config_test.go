@@ -0,0 +1,199 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "a-test-secret-that-is-long-enough"
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+port: 7000
+database:
+  uri: postgres://file
+jwt:
+  secret: `+testSecret+`
+  ttl: 1h
+jobs:
+  workers: 2
+`)
+
+	tomlFile := writeFile(t, "config.toml", `
+port = 7000
+
+[database]
+uri = "postgres://file"
+
+[jwt]
+secret = "`+testSecret+`"
+ttl = "1h"
+
+[jobs]
+workers = 2
+`)
+
+	for _, file := range []string{yamlFile, tomlFile} {
+		t.Run("Test defaults < file < env < flags with "+filepath.Ext(file), func(t *testing.T) {
+			cfg, err := Load([]string{"-config", file, "-port", "9000"}, env(map[string]string{
+				"PORT":        "8000",
+				"JOB_WORKERS": "8",
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.Port != 9000 {
+				t.Errorf("port: flag should win, got %d", cfg.Port)
+			}
+
+			if cfg.Jobs.Workers != 8 {
+				t.Errorf("workers: env should override file, got %d", cfg.Jobs.Workers)
+			}
+
+			if cfg.JWT.TTL != time.Hour || cfg.Database.URI != "postgres://file" {
+				t.Errorf("file values not applied: %+v", cfg)
+			}
+
+			if cfg.Shutdown.DrainTimeout != 20*time.Second {
+				t.Errorf("drain timeout: expected default, got %v", cfg.Shutdown.DrainTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadMemoryStorage(t *testing.T) {
+	cfg, err := Load([]string{"-storage", "memory"}, env(map[string]string{"JWT_SECRET": testSecret}))
+	if err != nil {
+		t.Fatalf("memory storage should not need a database uri: %v", err)
+	}
+
+	if cfg.Storage != StorageMemory {
+		t.Errorf("expected memory storage, got %q", cfg.Storage)
+	}
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	secretFile := writeFile(t, "jwt_secret", testSecret+"\n")
+
+	t.Run("Test secret read from env _FILE", func(t *testing.T) {
+		cfg, err := Load(nil, env(map[string]string{
+			"POSTGRES_URI":    "postgres://env",
+			"JWT_SECRET_FILE": secretFile,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.JWT.Secret != testSecret {
+			t.Errorf("expected secret from file without trailing newline, got %q", cfg.JWT.Secret)
+		}
+	})
+
+	t.Run("Test secret read from config file _file option", func(t *testing.T) {
+		file := writeFile(t, "config.yaml", "database:\n  uri: postgres://file\njwt:\n  secret_file: "+secretFile+"\n")
+
+		cfg, err := Load([]string{"-config", file}, env(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.JWT.Secret != testSecret {
+			t.Errorf("expected secret from file, got %q", cfg.JWT.Secret)
+		}
+	})
+
+	t.Run("Test secret and secret file both set", func(t *testing.T) {
+		_, err := Load(nil, env(map[string]string{
+			"POSTGRES_URI":    "postgres://env",
+			"JWT_SECRET":      testSecret,
+			"JWT_SECRET_FILE": secretFile,
+		}))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		file    string
+		wantErr []string
+	}{
+		{
+			name:    "Test missing required values are all reported",
+			wantErr: []string{"database uri must be set", "jwt secret must be set"},
+		},
+		{
+			name:    "Test short jwt secret",
+			env:     map[string]string{"POSTGRES_URI": "postgres://env", "JWT_SECRET": "short"},
+			wantErr: []string{"jwt secret must be at least 32 characters"},
+		},
+		{
+			name:    "Test unparseable values",
+			args:    []string{"-port", "http", "-jwt-ttl", "forever"},
+			env:     map[string]string{"POSTGRES_URI": "postgres://env", "JWT_SECRET": testSecret},
+			wantErr: []string{"invalid port (PORT)", "invalid jwt.ttl (JWT_TTL)"},
+		},
+		{
+			name:    "Test out of range values",
+			env:     map[string]string{"POSTGRES_URI": "postgres://env", "JWT_SECRET": testSecret, "PORT": "70000", "JOB_WORKERS": "0"},
+			wantErr: []string{"port must be between 1 and 65535", "job workers must be at least 1"},
+		},
+		{
+			name:    "Test invalid pool settings",
+			env:     map[string]string{"POSTGRES_URI": "postgres://env", "JWT_SECRET": testSecret, "DB_MAX_CONNS": "4", "DB_MIN_CONNS": "8", "DB_QUERY_TIMEOUT": "-1s"},
+			wantErr: []string{"database min conns must be between 0 and max conns (4), got 8", "timeouts cannot be negative"},
+		},
+		{
+			name:    "Test unknown storage",
+			env:     map[string]string{"POSTGRES_URI": "postgres://env", "JWT_SECRET": testSecret, "STORAGE": "disk"},
+			wantErr: []string{"storage must be postgres, sqlite or memory"},
+		},
+		{
+			name:    "Test unknown config file option",
+			file:    "jwt:\n  secrte: oops\n",
+			wantErr: []string{"unknown options in config file", "jwt.secrte"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := test.args
+			if test.file != "" {
+				args = append([]string{"-config", writeFile(t, "config.yml", test.file)}, args...)
+			}
+
+			_, err := Load(args, env(test.env))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			for _, want := range test.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error to contain %q, got: %v", want, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/michaelcosj/hng-task-two/internal/logging"
+	"github.com/michaelcosj/hng-task-two/internal/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// option is a single setting. it can be set in the config file, the
+// environment or with a flag, each overriding the one before it
+type option struct {
+	// dotted path of the option in the config file
+	key string
+	env string
+	// options without a flag can't be set on the command line,
+	// secrets don't have one since flags are visible in ps
+	flag string
+	// secrets can also be read from a file named by the
+	// <env>_FILE variable or the <key>_file config file option
+	secret bool
+	def    string
+	usage  string
+	set    func(c *Config, value string) error
+}
+
+var options = []option{
+	{
+		key: "port", env: "PORT", flag: "port", def: "6969",
+		usage: "port to listen on",
+		set:   intOption(func(c *Config) *int { return &c.Port }),
+	},
+	{
+		key: "storage", env: "STORAGE", flag: "storage", def: "postgres",
+		usage: "where data is kept, postgres, sqlite, or memory to lose the data on exit",
+		set:   stringOption(func(c *Config) *string { return &c.Storage }),
+	},
+	{
+		key: "log.level", env: "LOG_LEVEL", flag: "log-level", def: "info",
+		usage: "minimum level to log, one of debug, info, warn or error",
+		set: func(c *Config, value string) error {
+			level, err := logging.ParseLevel(value)
+			c.Log.Level = level
+			return err
+		},
+	},
+	{
+		key: "log.format", env: "LOG_FORMAT", flag: "log-format", def: "json",
+		usage: "log output format, json or text",
+		set:   stringOption(func(c *Config) *string { return &c.Log.Format }),
+	},
+	{
+		key: "tracing.exporter", env: "TRACING_EXPORTER", flag: "tracing-exporter", def: "none",
+		usage: "where to send traces, none, stdout or otlp (configured with OTEL_EXPORTER_OTLP_*)",
+		set:   stringOption(func(c *Config) *string { return &c.Tracing.Exporter }),
+	},
+	{
+		key: "tracing.service_name", env: "TRACING_SERVICE_NAME", def: "hng-task-two",
+		usage: "service name traces are reported under",
+		set:   stringOption(func(c *Config) *string { return &c.Tracing.ServiceName }),
+	},
+	{
+		key: "tracing.sample_ratio", env: "TRACING_SAMPLE_RATIO", flag: "tracing-sample-ratio", def: "1",
+		usage: "fraction of requests to trace, between 0 and 1",
+		set: func(c *Config, value string) error {
+			ratio, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", value)
+			}
+			c.Tracing.SampleRatio = ratio
+			return nil
+		},
+	},
+	{
+		key: "rate_limit.enabled", env: "RATE_LIMIT_ENABLED", flag: "rate-limit-enabled", def: "true",
+		usage: "whether requests are rate limited",
+		set:   boolOption(func(c *Config) *bool { return &c.RateLimit.Enabled }),
+	},
+	{
+		key: "rate_limit.store", env: "RATE_LIMIT_STORE", flag: "rate-limit-store", def: "memory",
+		usage: "where rate limits are kept, memory for each instance or postgres to share them",
+		set:   stringOption(func(c *Config) *string { return &c.RateLimit.Store }),
+	},
+	{
+		key: "rate_limit.trusted_proxies", env: "RATE_LIMIT_TRUSTED_PROXIES", flag: "rate-limit-trusted-proxies",
+		usage: "comma separated addresses or cidr ranges of proxies trusted to set Fly-Client-IP and X-Forwarded-For",
+		set: func(c *Config, value string) error {
+			prefixes, err := ratelimit.ParsePrefixes(value)
+			c.RateLimit.TrustedProxies = prefixes
+			return err
+		},
+	},
+	{
+		key: "rate_limit.auth", env: "RATE_LIMIT_AUTH", flag: "rate-limit-auth", def: "10/1m",
+		usage: "limit for each client on the auth routes, requests/period with an optional ,burst",
+		set:   limitOption(func(c *Config) *ratelimit.Limit { return &c.RateLimit.Auth }),
+	},
+	{
+		key: "rate_limit.api", env: "RATE_LIMIT_API", flag: "rate-limit-api", def: "120/1m",
+		usage: "limit for each user on the api routes, requests/period with an optional ,burst",
+		set:   limitOption(func(c *Config) *ratelimit.Limit { return &c.RateLimit.API }),
+	},
+	{
+		key: "database.uri", env: "POSTGRES_URI", secret: true,
+		usage: "postgres connection uri",
+		set:   stringOption(func(c *Config) *string { return &c.Database.URI }),
+	},
+	{
+		key: "database.migrate_on_start", env: "MIGRATE_ON_START", flag: "migrate-on-start", def: "false",
+		usage: "whether to apply postgres migrations when the server starts",
+		set:   boolOption(func(c *Config) *bool { return &c.Database.MigrateOnStart }),
+	},
+	{
+		key: "database.max_conns", env: "DB_MAX_CONNS", flag: "db-max-conns", def: "10",
+		usage: "most postgres connections to open",
+		set:   intOption(func(c *Config) *int { return &c.Database.MaxConns }),
+	},
+	{
+		key: "database.min_conns", env: "DB_MIN_CONNS", flag: "db-min-conns", def: "2",
+		usage: "postgres connections to keep open when idle",
+		set:   intOption(func(c *Config) *int { return &c.Database.MinConns }),
+	},
+	{
+		key: "database.max_conn_lifetime", env: "DB_MAX_CONN_LIFETIME", flag: "db-max-conn-lifetime", def: "1h",
+		usage: "how long a postgres connection is used before it is replaced",
+		set:   durationOption(func(c *Config) *time.Duration { return &c.Database.MaxConnLifetime }),
+	},
+	{
+		key: "database.max_conn_idle_time", env: "DB_MAX_CONN_IDLE_TIME", flag: "db-max-conn-idle-time", def: "30m",
+		usage: "how long an idle postgres connection is kept above the minimum",
+		set:   durationOption(func(c *Config) *time.Duration { return &c.Database.MaxConnIdleTime }),
+	},
+	{
+		key: "database.health_check_period", env: "DB_HEALTH_CHECK_PERIOD", flag: "db-health-check-period", def: "1m",
+		usage: "how often idle postgres connections are checked",
+		set:   durationOption(func(c *Config) *time.Duration { return &c.Database.HealthCheckPeriod }),
+	},
+	{
+		key: "database.statement_timeout", env: "DB_STATEMENT_TIMEOUT", flag: "db-statement-timeout", def: "30s",
+		usage: "longest a statement may run in postgres, 0 for no limit",
+		set:   durationOption(func(c *Config) *time.Duration { return &c.Database.StatementTimeout }),
+	},
+	{
+		key: "database.query_timeout", env: "DB_QUERY_TIMEOUT", flag: "db-query-timeout", def: "10s",
+		usage: "longest a query may take including the wait for a connection, 0 for no limit",
+		set:   durationOption(func(c *Config) *time.Duration { return &c.Database.QueryTimeout }),
+	},
+	{
+		key: "database.connect_timeout", env: "DB_CONNECT_TIMEOUT", flag: "db-connect-timeout", def: "30s",
+		usage: "how long to keep retrying when postgres isn't up at startup",
+		set:   durationOption(func(c *Config) *time.Duration { return &c.Database.ConnectTimeout }),
+	},
+	{
+		key: "sqlite.path", env: "SQLITE_PATH", flag: "sqlite-path", def: "hng.db",
+		usage: "sqlite database file, created if missing, when storage is sqlite",
+		set:   stringOption(func(c *Config) *string { return &c.SQLite.Path }),
+	},
+	{
+		key: "jwt.secret", env: "JWT_SECRET", secret: true,
+		usage: "secret used to sign access tokens",
+		set:   stringOption(func(c *Config) *string { return &c.JWT.Secret }),
+	},
+	{
+		key: "jwt.ttl", env: "JWT_TTL", flag: "jwt-ttl", def: "24h",
+		usage: "how long access tokens are valid for",
+		set:   durationOption(func(c *Config) *time.Duration { return &c.JWT.TTL }),
+	},
+	{
+		key: "jobs.workers", env: "JOB_WORKERS", flag: "job-workers", def: "4",
+		usage: "number of background job workers",
+		set:   intOption(func(c *Config) *int { return &c.Jobs.Workers }),
+	},
+	{
+		key: "shutdown.readiness_delay", env: "SHUTDOWN_READINESS_DELAY", flag: "shutdown-readiness-delay", def: "3s",
+		usage: "how long to report unready before draining requests",
+		set:   durationOption(func(c *Config) *time.Duration { return &c.Shutdown.ReadinessDelay }),
+	},
+	{
+		key: "shutdown.drain_timeout", env: "SHUTDOWN_DRAIN_TIMEOUT", flag: "shutdown-drain-timeout", def: "20s",
+		usage: "how long to wait for in-flight requests on shutdown",
+		set:   durationOption(func(c *Config) *time.Duration { return &c.Shutdown.DrainTimeout }),
+	},
+	{
+		key: "shutdown.workers_timeout", env: "SHUTDOWN_WORKERS_TIMEOUT", flag: "shutdown-workers-timeout", def: "5s",
+		usage: "how long to wait for background workers on shutdown",
+		set:   durationOption(func(c *Config) *time.Duration { return &c.Shutdown.WorkersTimeout }),
+	},
+}
+
+// Load builds the config from the defaults, an optional yaml or toml
+// config file (-config or CONFIG_FILE), the environment and flags,
+// and validates it. getenv is os.Getenv outside of tests
+func Load(args []string, getenv func(string) string) (Config, error) {
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "path to a yaml or toml config file")
+
+	flagValues := make(map[string]*string)
+	for _, opt := range options {
+		if opt.flag != "" {
+			flagValues[opt.flag] = fs.String(opt.flag, "", fmt.Sprintf("%s (%s, default %q)", opt.usage, opt.env, opt.def))
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	values := make(map[string]string)
+	for _, opt := range options {
+		if opt.def != "" {
+			values[opt.key] = opt.def
+		}
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, values); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := loadEnv(getenv, values); err != nil {
+		return Config{}, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		for _, opt := range options {
+			if opt.flag == f.Name {
+				values[opt.key] = *flagValues[f.Name]
+			}
+		}
+	})
+
+	var cfg Config
+	var problems []error
+	for _, opt := range options {
+		value, ok := values[opt.key]
+		if !ok {
+			continue
+		}
+
+		if err := opt.set(&cfg, value); err != nil {
+			problems = append(problems, fmt.Errorf("invalid %s (%s): %w", opt.key, opt.env, err))
+		}
+	}
+
+	if err := errors.Join(problems...); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+func loadFile(path string, values map[string]string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		return fmt.Errorf("unsupported config file type %q, use .yaml or .toml", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	flat := make(map[string]string)
+	flatten("", raw, flat)
+
+	var unknown []string
+	for key, value := range flat {
+		opt, ok := findOption(key)
+		switch {
+		case ok:
+			values[opt.key] = value
+		case strings.HasSuffix(key, "_file"):
+			opt, ok = findOption(strings.TrimSuffix(key, "_file"))
+			if !ok || !opt.secret {
+				unknown = append(unknown, key)
+				continue
+			}
+
+			if _, ok := flat[opt.key]; ok {
+				return fmt.Errorf("config file sets both %s and %s, set only one", opt.key, key)
+			}
+
+			secret, err := readSecret(value)
+			if err != nil {
+				return fmt.Errorf("error reading %s: %w", key, err)
+			}
+			values[opt.key] = secret
+		default:
+			unknown = append(unknown, key)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown options in config file %s: %s", path, strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+func loadEnv(getenv func(string) string, values map[string]string) error {
+	for _, opt := range options {
+		value := getenv(opt.env)
+		if value != "" {
+			values[opt.key] = value
+		}
+
+		if !opt.secret {
+			continue
+		}
+
+		file := getenv(opt.env + "_FILE")
+		if file == "" {
+			continue
+		}
+
+		if value != "" {
+			return fmt.Errorf("both %s and %s_FILE are set, set only one", opt.env, opt.env)
+		}
+
+		secret, err := readSecret(file)
+		if err != nil {
+			return fmt.Errorf("error reading %s_FILE: %w", opt.env, err)
+		}
+		values[opt.key] = secret
+	}
+
+	return nil
+}
+
+// readSecret reads a secret from a file such as a mounted docker
+// or kubernetes secret, ignoring the trailing newline editors add
+func readSecret(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func findOption(key string) (option, bool) {
+	for _, opt := range options {
+		if opt.key == key {
+			return opt, true
+		}
+	}
+	return option{}, false
+}
+
+// flatten turns nested config file tables into dotted keys
+func flatten(prefix string, raw map[string]any, out map[string]string) {
+	for key, value := range raw {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch value := value.(type) {
+		case map[string]any:
+			flatten(key, value, out)
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			out[key] = strings.Join(items, ",")
+		default:
+			out[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+func stringOption(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func intOption(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func durationOption(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 5m", value)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func boolOption(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func limitOption(field func(c *Config) *ratelimit.Limit) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = limit
+		return nil
+	}
+}
@@ -0,0 +1,24 @@
+package db_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/pgtest"
+	"github.com/michaelcosj/hng-task-two/internal/repotest"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m))
+}
+
+// runs against a migrated schema of its own, the suite checks the
+// errors constraints raise so it doesn't run in a test transaction
+func TestConformance(t *testing.T) {
+	pool := pgtest.Pool(t)
+
+	repotest.Run(t, func(t *testing.T) db.RepoQuerier {
+		return db.NewRepoQuerier(db.New(pool), pool)
+	})
+}
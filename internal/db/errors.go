@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// errors every repository reports, whatever database is behind it.
+// the driver error is wrapped so it can still be inspected
+var (
+	ErrNotFound   = errors.New("record not found")
+	ErrConflict   = errors.New("record already exists")
+	ErrForeignKey = errors.New("referenced record does not exist")
+	// the transaction conflicted with a concurrent one and was
+	// rolled back, running it again is expected to succeed
+	ErrSerialization = errors.New("transaction could not be serialized")
+)
+
+// ConvertError wraps err in the domain error it stands for.
+// errors that have none are returned unchanged
+func ConvertError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case pgerrcode.UniqueViolation:
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case pgerrcode.ForeignKeyViolation:
+		return fmt.Errorf("%w: %w", ErrForeignKey, err)
+	case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected:
+		return fmt.Errorf("%w: %w", ErrSerialization, err)
+	}
+
+	return err
+}
+
+// convertingDBTX converts the errors of the queries run on it
+type convertingDBTX struct {
+	db DBTX
+}
+
+func (c convertingDBTX) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := c.db.Exec(ctx, sql, args...)
+	return tag, ConvertError(err)
+}
+
+func (c convertingDBTX) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := c.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, ConvertError(err)
+	}
+	return convertingRows{rows}, nil
+}
+
+func (c convertingDBTX) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return convertingRow{c.db.QueryRow(ctx, sql, args...)}
+}
+
+type convertingRow struct {
+	row pgx.Row
+}
+
+func (r convertingRow) Scan(dest ...any) error {
+	return ConvertError(r.row.Scan(dest...))
+}
+
+type convertingRows struct {
+	pgx.Rows
+}
+
+func (r convertingRows) Scan(dest ...any) error {
+	return ConvertError(r.Rows.Scan(dest...))
+}
+
+func (r convertingRows) Err() error {
+	return ConvertError(r.Rows.Err())
+}
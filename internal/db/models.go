@@ -9,22 +9,91 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AuditLog struct {
+	ID        int64
+	ActorID   uuid.NullUUID
+	Action    string
+	TargetID  uuid.NullUUID
+	Data      []byte
+	CreatedAt pgtype.Timestamptz
+}
+
+type Job struct {
+	ID          uuid.UUID
+	Kind        string
+	Payload     []byte
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       pgtype.Timestamptz
+	LockedUntil pgtype.Timestamptz
+	LastError   pgtype.Text
+	CreatedAt   pgtype.Timestamptz
+	FinishedAt  pgtype.Timestamptz
+}
+
 type Organisation struct {
 	ID          uuid.UUID
 	Name        string
 	Description pgtype.Text
 }
 
+type OrgEvent struct {
+	ID        int64
+	OrgID     uuid.UUID
+	Type      string
+	Data      []byte
+	CreatedAt pgtype.Timestamptz
+}
+
+type RateLimit struct {
+	Key       string
+	Tokens    float64
+	Allowed   bool
+	UpdatedAt pgtype.Timestamptz
+}
+
 type User struct {
-	ID        uuid.UUID
-	Email     string
-	FirstName string
-	LastName  string
-	Password  string
-	Phone     pgtype.Text
+	ID              uuid.UUID
+	Email           string
+	FirstName       string
+	LastName        string
+	Password        string
+	Phone           pgtype.Text
+	IsSuperadmin    bool
+	TokenVersion    int32
+	Status          string
+	StatusReason    pgtype.Text
+	StatusChangedAt pgtype.Timestamptz
 }
 
 type UserOrganisation struct {
 	UserID uuid.UUID
 	OrgID  uuid.UUID
+	Role   string
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int32
+	NextAttemptAt  pgtype.Timestamptz
+	LastStatusCode pgtype.Int4
+	LastError      pgtype.Text
+	CreatedAt      pgtype.Timestamptz
+	DeliveredAt    pgtype.Timestamptz
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID
+	OrgID     uuid.UUID
+	Url       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt pgtype.Timestamptz
 }
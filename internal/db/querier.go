@@ -8,21 +8,74 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	AuditInsert(ctx context.Context, arg AuditInsertParams) (AuditLog, error)
+	// newest first, before pages back through older entries
+	AuditList(ctx context.Context, arg AuditListParams) ([]AuditLog, error)
+	DeliveryAllWhereEndpoint(ctx context.Context, arg DeliveryAllWhereEndpointParams) ([]WebhookDelivery, error)
+	// claims due deliveries by pushing their next attempt past the lease
+	// so other dispatchers skip them. if the claiming process dies the
+	// delivery is picked up again once the lease runs out
+	DeliveryClaimDue(ctx context.Context, arg DeliveryClaimDueParams) ([]DeliveryClaimDueRow, error)
+	DeliveryInsert(ctx context.Context, arg DeliveryInsertParams) (WebhookDelivery, error)
+	DeliveryRedeliver(ctx context.Context, arg DeliveryRedeliverParams) (WebhookDelivery, error)
+	DeliveryUpdate(ctx context.Context, arg DeliveryUpdateParams) error
 	// pretty complicated query but should work
 	// gets a user if it belongs to one of another user's
 	// organisation
 	FindUserInOrgs(ctx context.Context, arg FindUserInOrgsParams) (User, error)
+	// claims due jobs and jobs whose lease ran out because the
+	// worker running them died
+	JobClaim(ctx context.Context, arg JobClaimParams) ([]Job, error)
+	JobComplete(ctx context.Context, id uuid.UUID) error
+	JobInsert(ctx context.Context, arg JobInsertParams) (Job, error)
+	JobKill(ctx context.Context, arg JobKillParams) error
+	JobPurgeCompleted(ctx context.Context, finishedAt pgtype.Timestamptz) (int64, error)
+	JobRetry(ctx context.Context, arg JobRetryParams) error
 	OrgAllWhereUser(ctx context.Context, userID uuid.UUID) ([]Organisation, error)
+	// ids are taken from the sequence when an event is inserted, not when
+	// it is committed, so inserts for an organisation wait for each other's
+	// transactions to end. otherwise a stream could read an event before
+	// one with a lower id commits and skip that one
+	OrgEventInsert(ctx context.Context, arg OrgEventInsertParams) (OrgEvent, error)
+	OrgEventLatestId(ctx context.Context, orgID uuid.UUID) (int64, error)
+	OrgEventPurge(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error)
+	OrgEventsAfter(ctx context.Context, arg OrgEventsAfterParams) ([]OrgEvent, error)
 	OrgInsert(ctx context.Context, arg OrgInsertParams) (Organisation, error)
+	OrgMemberRole(ctx context.Context, arg OrgMemberRoleParams) (string, error)
+	OrgMembersWhereOrg(ctx context.Context, orgID uuid.UUID) ([]OrgMembersWhereOrgRow, error)
+	OrgMembershipsWhereUser(ctx context.Context, userID uuid.UUID) ([]OrgMembershipsWhereUserRow, error)
+	OrgSearch(ctx context.Context, arg OrgSearchParams) ([]Organisation, error)
+	OrgUpdate(ctx context.Context, arg OrgUpdateParams) (Organisation, error)
 	OrgWhereUser(ctx context.Context, arg OrgWhereUserParams) (Organisation, error)
 	OrganisationWhereId(ctx context.Context, id uuid.UUID) (Organisation, error)
+	RateLimitPurge(ctx context.Context, updatedAt pgtype.Timestamptz) (int64, error)
+	// refills the bucket for the time since it was last used and takes a
+	// token if there is one, in a single statement so concurrent requests
+	// from different instances can't both take the last token. the time
+	// is the database's so instances with skewed clocks agree on it
+	RateLimitTake(ctx context.Context, arg RateLimitTakeParams) (RateLimitTakeRow, error)
 	UserAddOrg(ctx context.Context, arg UserAddOrgParams) error
 	UserInsert(ctx context.Context, arg UserInsertParams) (User, error)
+	UserRemoveOrg(ctx context.Context, arg UserRemoveOrgParams) (int64, error)
+	UserRevokeTokens(ctx context.Context, id uuid.UUID) (int64, error)
+	UserSearch(ctx context.Context, arg UserSearchParams) ([]User, error)
+	UserSetOrgRole(ctx context.Context, arg UserSetOrgRoleParams) (int64, error)
+	// tokens are revoked when the user leaves the active status
+	UserSetStatus(ctx context.Context, arg UserSetStatusParams) (int64, error)
+	UserSetSuperadmin(ctx context.Context, arg UserSetSuperadminParams) (int64, error)
+	// tokens issued with the old password stop working
+	UserUpdatePassword(ctx context.Context, arg UserUpdatePasswordParams) (int64, error)
 	UserWhereEmail(ctx context.Context, email string) (User, error)
 	UserWhereId(ctx context.Context, id uuid.UUID) (User, error)
+	WebhookAllForEvent(ctx context.Context, arg WebhookAllForEventParams) ([]WebhookEndpoint, error)
+	WebhookAllWhereOrg(ctx context.Context, orgID uuid.UUID) ([]WebhookEndpoint, error)
+	WebhookDelete(ctx context.Context, arg WebhookDeleteParams) (int64, error)
+	WebhookInsert(ctx context.Context, arg WebhookInsertParams) (WebhookEndpoint, error)
+	WebhookWhereOrg(ctx context.Context, arg WebhookWhereOrgParams) (WebhookEndpoint, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -12,8 +12,303 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const auditInsert = `-- name: AuditInsert :one
+INSERT INTO audit_log (
+    actor_id, action, target_id, data
+) VALUES ( $1, $2, $3, $4 )
+RETURNING id, actor_id, action, target_id, data, created_at
+`
+
+type AuditInsertParams struct {
+	ActorID  uuid.NullUUID
+	Action   string
+	TargetID uuid.NullUUID
+	Data     []byte
+}
+
+func (q *Queries) AuditInsert(ctx context.Context, arg AuditInsertParams) (AuditLog, error) {
+	row := q.db.QueryRow(ctx, auditInsert,
+		arg.ActorID,
+		arg.Action,
+		arg.TargetID,
+		arg.Data,
+	)
+	var i AuditLog
+	err := row.Scan(
+		&i.ID,
+		&i.ActorID,
+		&i.Action,
+		&i.TargetID,
+		&i.Data,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const auditList = `-- name: AuditList :many
+SELECT id, actor_id, action, target_id, data, created_at FROM audit_log
+WHERE ($1::bigint = 0 OR id < $1)
+    AND ($2::uuid IS NULL OR target_id = $2)
+ORDER BY id DESC
+LIMIT $3
+`
+
+type AuditListParams struct {
+	Before   int64
+	TargetID uuid.NullUUID
+	Limit    int32
+}
+
+// newest first, before pages back through older entries
+func (q *Queries) AuditList(ctx context.Context, arg AuditListParams) ([]AuditLog, error) {
+	rows, err := q.db.Query(ctx, auditList, arg.Before, arg.TargetID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorID,
+			&i.Action,
+			&i.TargetID,
+			&i.Data,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deliveryAllWhereEndpoint = `-- name: DeliveryAllWhereEndpoint :many
+SELECT id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type DeliveryAllWhereEndpointParams struct {
+	EndpointID uuid.UUID
+	Limit      int32
+}
+
+func (q *Queries) DeliveryAllWhereEndpoint(ctx context.Context, arg DeliveryAllWhereEndpointParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, deliveryAllWhereEndpoint, arg.EndpointID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.EndpointID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deliveryClaimDue = `-- name: DeliveryClaimDue :many
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1, next_attempt_at = $1
+FROM webhook_endpoints e
+WHERE e.id = d.endpoint_id AND d.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= now()
+    ORDER BY next_attempt_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at, e.url, e.secret
+`
+
+type DeliveryClaimDueParams struct {
+	LeaseUntil pgtype.Timestamptz
+	BatchSize  int32
+}
+
+type DeliveryClaimDueRow struct {
+	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int32
+	NextAttemptAt  pgtype.Timestamptz
+	LastStatusCode pgtype.Int4
+	LastError      pgtype.Text
+	CreatedAt      pgtype.Timestamptz
+	DeliveredAt    pgtype.Timestamptz
+	Url            string
+	Secret         string
+}
+
+// claims due deliveries by pushing their next attempt past the lease
+// so other dispatchers skip them. if the claiming process dies the
+// delivery is picked up again once the lease runs out
+func (q *Queries) DeliveryClaimDue(ctx context.Context, arg DeliveryClaimDueParams) ([]DeliveryClaimDueRow, error) {
+	rows, err := q.db.Query(ctx, deliveryClaimDue, arg.LeaseUntil, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeliveryClaimDueRow
+	for rows.Next() {
+		var i DeliveryClaimDueRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.EndpointID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deliveryInsert = `-- name: DeliveryInsert :one
+INSERT INTO webhook_deliveries (
+    endpoint_id, event_id, event_type, payload
+) VALUES ( $1, $2, $3, $4 )
+RETURNING id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at
+`
+
+type DeliveryInsertParams struct {
+	EndpointID uuid.UUID
+	EventID    uuid.UUID
+	EventType  string
+	Payload    []byte
+}
+
+func (q *Queries) DeliveryInsert(ctx context.Context, arg DeliveryInsertParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, deliveryInsert,
+		arg.EndpointID,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EndpointID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const deliveryRedeliver = `-- name: DeliveryRedeliver :one
+INSERT INTO webhook_deliveries (
+    endpoint_id, event_id, event_type, payload
+)
+SELECT endpoint_id, event_id, event_type, payload FROM webhook_deliveries
+WHERE webhook_deliveries.id = $1 AND webhook_deliveries.endpoint_id = $2
+RETURNING id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at
+`
+
+type DeliveryRedeliverParams struct {
+	ID         uuid.UUID
+	EndpointID uuid.UUID
+}
+
+func (q *Queries) DeliveryRedeliver(ctx context.Context, arg DeliveryRedeliverParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, deliveryRedeliver, arg.ID, arg.EndpointID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EndpointID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const deliveryUpdate = `-- name: DeliveryUpdate :exec
+UPDATE webhook_deliveries
+SET status = $2, next_attempt_at = $3, last_status_code = $4,
+    last_error = $5, delivered_at = $6
+WHERE id = $1
+`
+
+type DeliveryUpdateParams struct {
+	ID             uuid.UUID
+	Status         string
+	NextAttemptAt  pgtype.Timestamptz
+	LastStatusCode pgtype.Int4
+	LastError      pgtype.Text
+	DeliveredAt    pgtype.Timestamptz
+}
+
+func (q *Queries) DeliveryUpdate(ctx context.Context, arg DeliveryUpdateParams) error {
+	_, err := q.db.Exec(ctx, deliveryUpdate,
+		arg.ID,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastStatusCode,
+		arg.LastError,
+		arg.DeliveredAt,
+	)
+	return err
+}
+
 const findUserInOrgs = `-- name: FindUserInOrgs :one
-SELECT u.id, u.email, u.first_name, u.last_name, u.password, u.phone FROM users auth_user
+SELECT u.id, u.email, u.first_name, u.last_name, u.password, u.phone, u.is_superadmin, u.token_version, u.status, u.status_reason, u.status_changed_at FROM users auth_user
 JOIN user_organisations u_org ON u_org.user_id = auth_user.id
 JOIN organisations org ON u_org.org_id = org.id
 JOIN user_organisations org_users ON org_users.org_id = org.id
@@ -39,10 +334,157 @@ func (q *Queries) FindUserInOrgs(ctx context.Context, arg FindUserInOrgsParams)
 		&i.LastName,
 		&i.Password,
 		&i.Phone,
+		&i.IsSuperadmin,
+		&i.TokenVersion,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusChangedAt,
+	)
+	return i, err
+}
+
+const jobClaim = `-- name: JobClaim :many
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_until = $1
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE (status = 'pending' AND run_at <= now())
+        OR (status = 'running' AND locked_until < now())
+    ORDER BY run_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, payload, status, attempts, max_attempts, run_at, locked_until, last_error, created_at, finished_at
+`
+
+type JobClaimParams struct {
+	LockedUntil pgtype.Timestamptz
+	BatchSize   int32
+}
+
+// claims due jobs and jobs whose lease ran out because the
+// worker running them died
+func (q *Queries) JobClaim(ctx context.Context, arg JobClaimParams) ([]Job, error) {
+	rows, err := q.db.Query(ctx, jobClaim, arg.LockedUntil, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.RunAt,
+			&i.LockedUntil,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const jobComplete = `-- name: JobComplete :exec
+UPDATE jobs
+SET status = 'completed', locked_until = NULL, last_error = NULL, finished_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) JobComplete(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, jobComplete, id)
+	return err
+}
+
+const jobInsert = `-- name: JobInsert :one
+INSERT INTO jobs (
+    kind, payload, max_attempts
+) VALUES ( $1, $2, $3 )
+RETURNING id, kind, payload, status, attempts, max_attempts, run_at, locked_until, last_error, created_at, finished_at
+`
+
+type JobInsertParams struct {
+	Kind        string
+	Payload     []byte
+	MaxAttempts int32
+}
+
+func (q *Queries) JobInsert(ctx context.Context, arg JobInsertParams) (Job, error) {
+	row := q.db.QueryRow(ctx, jobInsert, arg.Kind, arg.Payload, arg.MaxAttempts)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedUntil,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.FinishedAt,
 	)
 	return i, err
 }
 
+const jobKill = `-- name: JobKill :exec
+UPDATE jobs
+SET status = 'dead', locked_until = NULL, last_error = $2, finished_at = now()
+WHERE id = $1
+`
+
+type JobKillParams struct {
+	ID        uuid.UUID
+	LastError pgtype.Text
+}
+
+func (q *Queries) JobKill(ctx context.Context, arg JobKillParams) error {
+	_, err := q.db.Exec(ctx, jobKill, arg.ID, arg.LastError)
+	return err
+}
+
+const jobPurgeCompleted = `-- name: JobPurgeCompleted :execrows
+DELETE FROM jobs
+WHERE status = 'completed' AND finished_at < $1
+`
+
+func (q *Queries) JobPurgeCompleted(ctx context.Context, finishedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, jobPurgeCompleted, finishedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const jobRetry = `-- name: JobRetry :exec
+UPDATE jobs
+SET status = 'pending', locked_until = NULL, run_at = $2, last_error = $3
+WHERE id = $1
+`
+
+type JobRetryParams struct {
+	ID        uuid.UUID
+	RunAt     pgtype.Timestamptz
+	LastError pgtype.Text
+}
+
+func (q *Queries) JobRetry(ctx context.Context, arg JobRetryParams) error {
+	_, err := q.db.Exec(ctx, jobRetry, arg.ID, arg.RunAt, arg.LastError)
+	return err
+}
+
 const orgAllWhereUser = `-- name: OrgAllWhereUser :many
 SELECT org.id, org.name, org.description FROM user_organisations uo
 JOIN organisations org ON uo.org_id = org.id
@@ -69,6 +511,103 @@ func (q *Queries) OrgAllWhereUser(ctx context.Context, userID uuid.UUID) ([]Orga
 	return items, nil
 }
 
+const orgEventInsert = `-- name: OrgEventInsert :one
+WITH turn AS (
+    SELECT pg_advisory_xact_lock(hashtextextended($1::uuid::text, 0))
+)
+INSERT INTO org_events (
+    org_id, type, data
+) SELECT $1, $2, $3 FROM turn
+RETURNING id, org_id, type, data, created_at
+`
+
+type OrgEventInsertParams struct {
+	OrgID uuid.UUID
+	Type  string
+	Data  []byte
+}
+
+// ids are taken from the sequence when an event is inserted, not when
+// it is committed, so inserts for an organisation wait for each other's
+// transactions to end. otherwise a stream could read an event before
+// one with a lower id commits and skip that one
+func (q *Queries) OrgEventInsert(ctx context.Context, arg OrgEventInsertParams) (OrgEvent, error) {
+	row := q.db.QueryRow(ctx, orgEventInsert, arg.OrgID, arg.Type, arg.Data)
+	var i OrgEvent
+	err := row.Scan(
+		&i.ID,
+		&i.OrgID,
+		&i.Type,
+		&i.Data,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const orgEventLatestId = `-- name: OrgEventLatestId :one
+SELECT COALESCE(MAX(id), 0)::bigint FROM org_events
+WHERE org_id = $1
+`
+
+func (q *Queries) OrgEventLatestId(ctx context.Context, orgID uuid.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, orgEventLatestId, orgID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const orgEventPurge = `-- name: OrgEventPurge :execrows
+DELETE FROM org_events
+WHERE created_at < $1
+`
+
+func (q *Queries) OrgEventPurge(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, orgEventPurge, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const orgEventsAfter = `-- name: OrgEventsAfter :many
+SELECT id, org_id, type, data, created_at FROM org_events
+WHERE org_id = $1 AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type OrgEventsAfterParams struct {
+	OrgID uuid.UUID
+	ID    int64
+	Limit int32
+}
+
+func (q *Queries) OrgEventsAfter(ctx context.Context, arg OrgEventsAfterParams) ([]OrgEvent, error) {
+	rows, err := q.db.Query(ctx, orgEventsAfter, arg.OrgID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OrgEvent
+	for rows.Next() {
+		var i OrgEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrgID,
+			&i.Type,
+			&i.Data,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const orgInsert = `-- name: OrgInsert :one
 INSERT INTO organisations (
     name, description
@@ -88,6 +627,170 @@ func (q *Queries) OrgInsert(ctx context.Context, arg OrgInsertParams) (Organisat
 	return i, err
 }
 
+const orgMemberRole = `-- name: OrgMemberRole :one
+SELECT role FROM user_organisations
+WHERE user_id = $1 AND org_id = $2 LIMIT 1
+`
+
+type OrgMemberRoleParams struct {
+	UserID uuid.UUID
+	OrgID  uuid.UUID
+}
+
+func (q *Queries) OrgMemberRole(ctx context.Context, arg OrgMemberRoleParams) (string, error) {
+	row := q.db.QueryRow(ctx, orgMemberRole, arg.UserID, arg.OrgID)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
+const orgMembersWhereOrg = `-- name: OrgMembersWhereOrg :many
+SELECT u.id, u.email, u.first_name, u.last_name, u.password, u.phone, u.is_superadmin, u.token_version, u.status, u.status_reason, u.status_changed_at, uo.role FROM user_organisations uo
+JOIN users u ON uo.user_id = u.id
+WHERE uo.org_id = $1
+ORDER BY u.email
+`
+
+type OrgMembersWhereOrgRow struct {
+	ID              uuid.UUID
+	Email           string
+	FirstName       string
+	LastName        string
+	Password        string
+	Phone           pgtype.Text
+	IsSuperadmin    bool
+	TokenVersion    int32
+	Status          string
+	StatusReason    pgtype.Text
+	StatusChangedAt pgtype.Timestamptz
+	Role            string
+}
+
+func (q *Queries) OrgMembersWhereOrg(ctx context.Context, orgID uuid.UUID) ([]OrgMembersWhereOrgRow, error) {
+	rows, err := q.db.Query(ctx, orgMembersWhereOrg, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OrgMembersWhereOrgRow
+	for rows.Next() {
+		var i OrgMembersWhereOrgRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.FirstName,
+			&i.LastName,
+			&i.Password,
+			&i.Phone,
+			&i.IsSuperadmin,
+			&i.TokenVersion,
+			&i.Status,
+			&i.StatusReason,
+			&i.StatusChangedAt,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const orgMembershipsWhereUser = `-- name: OrgMembershipsWhereUser :many
+SELECT org.id, org.name, org.description, uo.role FROM user_organisations uo
+JOIN organisations org ON uo.org_id = org.id
+WHERE uo.user_id = $1
+ORDER BY org.name
+`
+
+type OrgMembershipsWhereUserRow struct {
+	ID          uuid.UUID
+	Name        string
+	Description pgtype.Text
+	Role        string
+}
+
+func (q *Queries) OrgMembershipsWhereUser(ctx context.Context, userID uuid.UUID) ([]OrgMembershipsWhereUserRow, error) {
+	rows, err := q.db.Query(ctx, orgMembershipsWhereUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OrgMembershipsWhereUserRow
+	for rows.Next() {
+		var i OrgMembershipsWhereUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const orgSearch = `-- name: OrgSearch :many
+SELECT id, name, description FROM organisations
+WHERE $1::text = '' OR name ILIKE '%' || $1 || '%'
+ORDER BY name, id
+LIMIT $2 OFFSET $3
+`
+
+type OrgSearchParams struct {
+	Query  string
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) OrgSearch(ctx context.Context, arg OrgSearchParams) ([]Organisation, error) {
+	rows, err := q.db.Query(ctx, orgSearch, arg.Query, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Organisation
+	for rows.Next() {
+		var i Organisation
+		if err := rows.Scan(&i.ID, &i.Name, &i.Description); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const orgUpdate = `-- name: OrgUpdate :one
+UPDATE organisations
+SET name = $2, description = $3
+WHERE id = $1
+RETURNING id, name, description
+`
+
+type OrgUpdateParams struct {
+	ID          uuid.UUID
+	Name        string
+	Description pgtype.Text
+}
+
+func (q *Queries) OrgUpdate(ctx context.Context, arg OrgUpdateParams) (Organisation, error) {
+	row := q.db.QueryRow(ctx, orgUpdate, arg.ID, arg.Name, arg.Description)
+	var i Organisation
+	err := row.Scan(&i.ID, &i.Name, &i.Description)
+	return i, err
+}
+
 const orgWhereUser = `-- name: OrgWhereUser :one
 SELECT org.id, org.name, org.description FROM user_organisations uo
 JOIN organisations org ON uo.org_id = org.id
@@ -118,19 +821,69 @@ func (q *Queries) OrganisationWhereId(ctx context.Context, id uuid.UUID) (Organi
 	return i, err
 }
 
+const rateLimitPurge = `-- name: RateLimitPurge :execrows
+DELETE FROM rate_limits
+WHERE updated_at < $1
+`
+
+func (q *Queries) RateLimitPurge(ctx context.Context, updatedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, rateLimitPurge, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const rateLimitTake = `-- name: RateLimitTake :one
+INSERT INTO rate_limits (key, tokens, allowed, updated_at)
+VALUES ($1, $2::float8 - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+    tokens = CASE
+        WHEN LEAST($2::float8, rate_limits.tokens + EXTRACT(EPOCH FROM (now() - rate_limits.updated_at))::float8 * $3::float8) >= 1
+        THEN LEAST($2::float8, rate_limits.tokens + EXTRACT(EPOCH FROM (now() - rate_limits.updated_at))::float8 * $3::float8) - 1
+        ELSE LEAST($2::float8, rate_limits.tokens + EXTRACT(EPOCH FROM (now() - rate_limits.updated_at))::float8 * $3::float8)
+    END,
+    allowed = LEAST($2::float8, rate_limits.tokens + EXTRACT(EPOCH FROM (now() - rate_limits.updated_at))::float8 * $3::float8) >= 1,
+    updated_at = now()
+RETURNING tokens, allowed
+`
+
+type RateLimitTakeParams struct {
+	Key        string
+	Capacity   float64
+	RefillRate float64
+}
+
+type RateLimitTakeRow struct {
+	Tokens  float64
+	Allowed bool
+}
+
+// refills the bucket for the time since it was last used and takes a
+// token if there is one, in a single statement so concurrent requests
+// from different instances can't both take the last token. the time
+// is the database's so instances with skewed clocks agree on it
+func (q *Queries) RateLimitTake(ctx context.Context, arg RateLimitTakeParams) (RateLimitTakeRow, error) {
+	row := q.db.QueryRow(ctx, rateLimitTake, arg.Key, arg.Capacity, arg.RefillRate)
+	var i RateLimitTakeRow
+	err := row.Scan(&i.Tokens, &i.Allowed)
+	return i, err
+}
+
 const userAddOrg = `-- name: UserAddOrg :exec
 INSERT INTO user_organisations (
-    user_id, org_id
-) VALUES ( $1, $2 )
+    user_id, org_id, role
+) VALUES ( $1, $2, $3 )
 `
 
 type UserAddOrgParams struct {
 	UserID uuid.UUID
 	OrgID  uuid.UUID
+	Role   string
 }
 
 func (q *Queries) UserAddOrg(ctx context.Context, arg UserAddOrgParams) error {
-	_, err := q.db.Exec(ctx, userAddOrg, arg.UserID, arg.OrgID)
+	_, err := q.db.Exec(ctx, userAddOrg, arg.UserID, arg.OrgID, arg.Role)
 	return err
 }
 
@@ -140,7 +893,7 @@ INSERT INTO users (
 ) VALUES (
     $1, $2, $3, $4, $5
 )
-RETURNING id, email, first_name, last_name, password, phone
+RETURNING id, email, first_name, last_name, password, phone, is_superadmin, token_version, status, status_reason, status_changed_at
 `
 
 type UserInsertParams struct {
@@ -167,12 +920,172 @@ func (q *Queries) UserInsert(ctx context.Context, arg UserInsertParams) (User, e
 		&i.LastName,
 		&i.Password,
 		&i.Phone,
+		&i.IsSuperadmin,
+		&i.TokenVersion,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusChangedAt,
 	)
 	return i, err
 }
 
+const userRemoveOrg = `-- name: UserRemoveOrg :execrows
+DELETE FROM user_organisations
+WHERE user_id = $1 AND org_id = $2
+`
+
+type UserRemoveOrgParams struct {
+	UserID uuid.UUID
+	OrgID  uuid.UUID
+}
+
+func (q *Queries) UserRemoveOrg(ctx context.Context, arg UserRemoveOrgParams) (int64, error) {
+	result, err := q.db.Exec(ctx, userRemoveOrg, arg.UserID, arg.OrgID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const userRevokeTokens = `-- name: UserRevokeTokens :execrows
+UPDATE users SET token_version = token_version + 1
+WHERE id = $1
+`
+
+func (q *Queries) UserRevokeTokens(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, userRevokeTokens, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const userSearch = `-- name: UserSearch :many
+SELECT id, email, first_name, last_name, password, phone, is_superadmin, token_version, status, status_reason, status_changed_at FROM users
+WHERE $1::text = ''
+    OR email ILIKE '%' || $1 || '%'
+    OR first_name || ' ' || last_name ILIKE '%' || $1 || '%'
+ORDER BY email
+LIMIT $2 OFFSET $3
+`
+
+type UserSearchParams struct {
+	Query  string
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) UserSearch(ctx context.Context, arg UserSearchParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, userSearch, arg.Query, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.FirstName,
+			&i.LastName,
+			&i.Password,
+			&i.Phone,
+			&i.IsSuperadmin,
+			&i.TokenVersion,
+			&i.Status,
+			&i.StatusReason,
+			&i.StatusChangedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const userSetOrgRole = `-- name: UserSetOrgRole :execrows
+UPDATE user_organisations SET role = $3
+WHERE user_id = $1 AND org_id = $2
+`
+
+type UserSetOrgRoleParams struct {
+	UserID uuid.UUID
+	OrgID  uuid.UUID
+	Role   string
+}
+
+func (q *Queries) UserSetOrgRole(ctx context.Context, arg UserSetOrgRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, userSetOrgRole, arg.UserID, arg.OrgID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const userSetStatus = `-- name: UserSetStatus :execrows
+UPDATE users SET status = $2, status_reason = $3, status_changed_at = now(),
+    token_version = CASE WHEN $2 = 'active' THEN token_version ELSE token_version + 1 END
+WHERE id = $1
+`
+
+type UserSetStatusParams struct {
+	ID           uuid.UUID
+	Status       string
+	StatusReason pgtype.Text
+}
+
+// tokens are revoked when the user leaves the active status
+func (q *Queries) UserSetStatus(ctx context.Context, arg UserSetStatusParams) (int64, error) {
+	result, err := q.db.Exec(ctx, userSetStatus, arg.ID, arg.Status, arg.StatusReason)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const userSetSuperadmin = `-- name: UserSetSuperadmin :execrows
+UPDATE users SET is_superadmin = $2
+WHERE id = $1
+`
+
+type UserSetSuperadminParams struct {
+	ID           uuid.UUID
+	IsSuperadmin bool
+}
+
+func (q *Queries) UserSetSuperadmin(ctx context.Context, arg UserSetSuperadminParams) (int64, error) {
+	result, err := q.db.Exec(ctx, userSetSuperadmin, arg.ID, arg.IsSuperadmin)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const userUpdatePassword = `-- name: UserUpdatePassword :execrows
+UPDATE users SET password = $2, token_version = token_version + 1
+WHERE id = $1
+`
+
+type UserUpdatePasswordParams struct {
+	ID       uuid.UUID
+	Password string
+}
+
+// tokens issued with the old password stop working
+func (q *Queries) UserUpdatePassword(ctx context.Context, arg UserUpdatePasswordParams) (int64, error) {
+	result, err := q.db.Exec(ctx, userUpdatePassword, arg.ID, arg.Password)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const userWhereEmail = `-- name: UserWhereEmail :one
-SELECT id, email, first_name, last_name, password, phone FROM users
+SELECT id, email, first_name, last_name, password, phone, is_superadmin, token_version, status, status_reason, status_changed_at FROM users
 WHERE email = $1 LIMIT 1
 `
 
@@ -186,12 +1099,17 @@ func (q *Queries) UserWhereEmail(ctx context.Context, email string) (User, error
 		&i.LastName,
 		&i.Password,
 		&i.Phone,
+		&i.IsSuperadmin,
+		&i.TokenVersion,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusChangedAt,
 	)
 	return i, err
 }
 
 const userWhereId = `-- name: UserWhereId :one
-SELECT id, email, first_name, last_name, password, phone FROM users
+SELECT id, email, first_name, last_name, password, phone, is_superadmin, token_version, status, status_reason, status_changed_at FROM users
 WHERE id = $1 LIMIT 1
 `
 
@@ -205,6 +1123,160 @@ func (q *Queries) UserWhereId(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.LastName,
 		&i.Password,
 		&i.Phone,
+		&i.IsSuperadmin,
+		&i.TokenVersion,
+		&i.Status,
+		&i.StatusReason,
+		&i.StatusChangedAt,
+	)
+	return i, err
+}
+
+const webhookAllForEvent = `-- name: WebhookAllForEvent :many
+SELECT id, org_id, url, secret, events, active, created_at FROM webhook_endpoints
+WHERE org_id = $1 AND active AND $2::text = ANY(events)
+`
+
+type WebhookAllForEventParams struct {
+	OrgID     uuid.UUID
+	EventType string
+}
+
+func (q *Queries) WebhookAllForEvent(ctx context.Context, arg WebhookAllForEventParams) ([]WebhookEndpoint, error) {
+	rows, err := q.db.Query(ctx, webhookAllForEvent, arg.OrgID, arg.EventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrgID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.Active,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const webhookAllWhereOrg = `-- name: WebhookAllWhereOrg :many
+SELECT id, org_id, url, secret, events, active, created_at FROM webhook_endpoints
+WHERE org_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) WebhookAllWhereOrg(ctx context.Context, orgID uuid.UUID) ([]WebhookEndpoint, error) {
+	rows, err := q.db.Query(ctx, webhookAllWhereOrg, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrgID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.Active,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const webhookDelete = `-- name: WebhookDelete :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND org_id = $2
+`
+
+type WebhookDeleteParams struct {
+	ID    uuid.UUID
+	OrgID uuid.UUID
+}
+
+func (q *Queries) WebhookDelete(ctx context.Context, arg WebhookDeleteParams) (int64, error) {
+	result, err := q.db.Exec(ctx, webhookDelete, arg.ID, arg.OrgID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const webhookInsert = `-- name: WebhookInsert :one
+INSERT INTO webhook_endpoints (
+    org_id, url, secret, events
+) VALUES ( $1, $2, $3, $4 )
+RETURNING id, org_id, url, secret, events, active, created_at
+`
+
+type WebhookInsertParams struct {
+	OrgID  uuid.UUID
+	Url    string
+	Secret string
+	Events []string
+}
+
+func (q *Queries) WebhookInsert(ctx context.Context, arg WebhookInsertParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRow(ctx, webhookInsert,
+		arg.OrgID,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+	)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.OrgID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const webhookWhereOrg = `-- name: WebhookWhereOrg :one
+SELECT id, org_id, url, secret, events, active, created_at FROM webhook_endpoints
+WHERE id = $1 AND org_id = $2 LIMIT 1
+`
+
+type WebhookWhereOrgParams struct {
+	ID    uuid.UUID
+	OrgID uuid.UUID
+}
+
+func (q *Queries) WebhookWhereOrg(ctx context.Context, arg WebhookWhereOrgParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRow(ctx, webhookWhereOrg, arg.ID, arg.OrgID)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.OrgID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Active,
+		&i.CreatedAt,
 	)
 	return i, err
 }
@@ -9,6 +9,21 @@ import (
 type Db interface {
 	DBTX
 	Begin(ctx context.Context) (pgx.Tx, error)
+	BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
+}
+
+// TxDb is the Db of transactions begun inside tx. they are savepoints,
+// which keep the options tx was begun with
+func TxDb(tx pgx.Tx) Db {
+	return txDb{tx}
+}
+
+type txDb struct {
+	pgx.Tx
+}
+
+func (d txDb) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	return d.Begin(ctx)
 }
 
 type RepoQuerier interface {
@@ -17,6 +32,8 @@ type RepoQuerier interface {
 	GetDB() Db
 }
 
+// RepoQueries runs the sqlc queries, converting driver
+// errors into the domain errors in errors.go
 type RepoQueries struct {
 	*Queries
 	db Db
@@ -24,7 +41,7 @@ type RepoQueries struct {
 
 func (r *RepoQueries) WithTx(tx pgx.Tx) RepoQuerier {
 	return &RepoQueries{
-		Queries: r.Queries.WithTx(tx),
+		Queries: New(convertingDBTX{tx}),
 		db:      r.db,
 	}
 }
@@ -35,7 +52,7 @@ func (r *RepoQueries) GetDB() Db {
 
 func NewRepoQuerier(q *Queries, db Db) RepoQuerier {
 	return &RepoQueries{
-		Queries: q,
+		Queries: New(convertingDBTX{q.db}),
 		db:      db,
 	}
 }
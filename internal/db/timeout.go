@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// WithQueryTimeout limits every query run on db, including those in
+// its transactions, to timeout on top of the deadline the caller's
+// context already has. the query is cancelled with the request it
+// runs for, the timeout bounds it when the request has no deadline.
+// a timeout of 0 returns db unchanged
+func WithQueryTimeout(db Db, timeout time.Duration) Db {
+	if timeout <= 0 {
+		return db
+	}
+	return timeoutDB{db: db, timeout: timeout}
+}
+
+type timeoutDB struct {
+	db      Db
+	timeout time.Duration
+}
+
+func (d timeoutDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	return d.db.Exec(ctx, sql, args...)
+}
+
+// Query's rows are read after it returns, the timeout
+// is cancelled when they are closed
+func (d timeoutDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	rows, err := d.db.Query(ctx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return timeoutRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (d timeoutDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	return timeoutRow{row: d.db.QueryRow(ctx, sql, args...), cancel: cancel}
+}
+
+// Begin's context only bounds beginning the transaction, the
+// queries run in it are limited as they are run
+func (d timeoutDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	tx, err := d.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return timeoutTx{Tx: tx, timeoutDB: timeoutDB{db: TxDb(tx), timeout: d.timeout}}, nil
+}
+
+func (d timeoutDB) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return timeoutTx{Tx: tx, timeoutDB: timeoutDB{db: TxDb(tx), timeout: d.timeout}}, nil
+}
+
+// timeoutTx limits the queries of a transaction, committing and
+// rolling back are left to the caller's context so a transaction
+// whose queries timed out can still be rolled back
+type timeoutTx struct {
+	pgx.Tx
+	timeoutDB
+}
+
+func (t timeoutTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return t.timeoutDB.Exec(ctx, sql, args...)
+}
+
+func (t timeoutTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return t.timeoutDB.Query(ctx, sql, args...)
+}
+
+func (t timeoutTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return t.timeoutDB.QueryRow(ctx, sql, args...)
+}
+
+func (t timeoutTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return t.timeoutDB.Begin(ctx)
+}
+
+type timeoutRow struct {
+	row    pgx.Row
+	cancel context.CancelFunc
+}
+
+func (r timeoutRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.row.Scan(dest...)
+}
+
+type timeoutRows struct {
+	pgx.Rows
+	cancel context.CancelFunc
+}
+
+func (r timeoutRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
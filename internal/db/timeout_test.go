@@ -0,0 +1,116 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// deadlineDB records the contexts of the queries run on it
+type deadlineDB struct {
+	contexts *[]context.Context
+}
+
+func (d deadlineDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	*d.contexts = append(*d.contexts, ctx)
+	return pgconn.CommandTag{}, nil
+}
+
+func (d deadlineDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	*d.contexts = append(*d.contexts, ctx)
+	return emptyRows{}, nil
+}
+
+func (d deadlineDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	*d.contexts = append(*d.contexts, ctx)
+	return emptyRows{}
+}
+
+func (d deadlineDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return deadlineTx{deadlineDB: d}, nil
+}
+
+func (d deadlineDB) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	return d.Begin(ctx)
+}
+
+type deadlineTx struct {
+	pgx.Tx
+	deadlineDB
+}
+
+func (t deadlineTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return t.deadlineDB.Exec(ctx, sql, args...)
+}
+
+func (t deadlineTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return t.deadlineDB.Query(ctx, sql, args...)
+}
+
+func (t deadlineTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return t.deadlineDB.QueryRow(ctx, sql, args...)
+}
+
+func (t deadlineTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return t.deadlineDB.Begin(ctx)
+}
+
+type emptyRows struct {
+	pgx.Rows
+}
+
+func (emptyRows) Close()                 {}
+func (emptyRows) Scan(dest ...any) error { return nil }
+
+func TestQueryTimeout(t *testing.T) {
+	var contexts []context.Context
+	timeoutDB := db.WithQueryTimeout(deadlineDB{&contexts}, time.Minute)
+
+	expectDeadline := func(name string, within time.Duration) {
+		t.Helper()
+
+		ctx := contexts[len(contexts)-1]
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			t.Fatalf("%s: expected a deadline", name)
+		}
+		if left := time.Until(deadline); left > within {
+			t.Errorf("%s: expected a deadline within %v, got %v", name, within, left)
+		}
+	}
+
+	ctx := context.Background()
+	timeoutDB.Exec(ctx, "")
+	expectDeadline("exec", time.Minute)
+
+	rows, _ := timeoutDB.Query(ctx, "")
+	expectDeadline("query", time.Minute)
+	if err := contexts[len(contexts)-1].Err(); err != nil {
+		t.Errorf("expected the query to run until its rows are closed, got %v", err)
+	}
+	rows.Close()
+	if contexts[len(contexts)-1].Err() == nil {
+		t.Error("expected closing the rows to cancel the query")
+	}
+
+	timeoutDB.QueryRow(ctx, "").Scan()
+	expectDeadline("query row", time.Minute)
+
+	tx, _ := timeoutDB.Begin(ctx)
+	tx.Exec(ctx, "")
+	expectDeadline("query in a transaction", time.Minute)
+
+	// a request with less time left keeps its own deadline
+	short, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	timeoutDB.Exec(short, "")
+	expectDeadline("exec with a deadline", time.Second)
+
+	if db.WithQueryTimeout(deadlineDB{&contexts}, 0) != (deadlineDB{&contexts}) {
+		t.Error("expected no timeout to leave the db unchanged")
+	}
+}
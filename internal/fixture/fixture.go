@@ -0,0 +1,156 @@
+// Package fixture makes the rows tests need. values are unique to the
+// test that makes them so tests sharing a database don't collide, and
+// Seed loads a large dataset for trying the api out
+package fixture
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password is the password of every user fixtures make
+const Password = "password"
+
+// hashed once at the lowest cost, hashing for every user would
+// make large fixtures and datasets slow to build
+var passwordHash = sync.OnceValues(func() (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.MinCost)
+	return string(hash), err
+})
+
+// Fixtures makes rows for one test
+type Fixtures struct {
+	t   testing.TB
+	ctx context.Context
+	q   db.Querier
+	// the test's name and a random tag, unique across tests and runs
+	prefix string
+	n      int
+}
+
+func New(t testing.TB, q db.Querier) *Fixtures {
+	return &Fixtures{
+		t:      t,
+		ctx:    context.Background(),
+		q:      q,
+		prefix: slug(t.Name()) + "-" + uuid.NewString()[:8],
+	}
+}
+
+// next returns a number not used by f before
+func (f *Fixtures) next() int {
+	f.n++
+	return f.n
+}
+
+// Email returns an address no other user has, for tests that
+// register users themselves
+func (f *Fixtures) Email() string {
+	return fmt.Sprintf("%s-%d@example.com", f.prefix, f.next())
+}
+
+// User inserts a user with the password Password, update can
+// change the values before they are inserted
+func (f *Fixtures) User(update ...func(p *db.UserInsertParams)) db.User {
+	f.t.Helper()
+
+	hash, err := passwordHash()
+	if err != nil {
+		f.t.Fatalf("error hashing fixture password: %v", err)
+	}
+
+	n := f.next()
+	params := db.UserInsertParams{
+		Email:     fmt.Sprintf("%s-%d@example.com", f.prefix, n),
+		FirstName: "User",
+		LastName:  fmt.Sprintf("Number %d", n),
+		Password:  hash,
+		Phone:     pgtype.Text{String: fmt.Sprintf("+23480%08d", n), Valid: true},
+	}
+	for _, fn := range update {
+		fn(&params)
+	}
+
+	user, err := f.q.UserInsert(f.ctx, params)
+	if err != nil {
+		f.t.Fatalf("error inserting fixture user %s: %v", params.Email, err)
+	}
+	return user
+}
+
+// Org inserts an organisation, update can change the values
+// before they are inserted
+func (f *Fixtures) Org(update ...func(p *db.OrgInsertParams)) db.Organisation {
+	f.t.Helper()
+
+	params := db.OrgInsertParams{
+		Name:        fmt.Sprintf("%s org %d", f.prefix, f.next()),
+		Description: pgtype.Text{String: "Made by " + f.t.Name(), Valid: true},
+	}
+	for _, fn := range update {
+		fn(&params)
+	}
+
+	org, err := f.q.OrgInsert(f.ctx, params)
+	if err != nil {
+		f.t.Fatalf("error inserting fixture organisation %s: %v", params.Name, err)
+	}
+	return org
+}
+
+// Member adds user to org with role
+func (f *Fixtures) Member(user db.User, org db.Organisation, role string) {
+	f.t.Helper()
+
+	if err := f.q.UserAddOrg(f.ctx, db.UserAddOrgParams{UserID: user.ID, OrgID: org.ID, Role: role}); err != nil {
+		f.t.Fatalf("error adding fixture user %s to %s: %v", user.Email, org.Name, err)
+	}
+}
+
+// OrgWithMembers inserts an organisation with an admin and
+// the given number of members
+func (f *Fixtures) OrgWithMembers(members int) (db.Organisation, db.User, []db.User) {
+	f.t.Helper()
+
+	org := f.Org()
+	admin := f.User()
+	f.Member(admin, org, "admin")
+
+	users := make([]db.User, members)
+	for i := range users {
+		users[i] = f.User()
+		f.Member(users[i], org, "member")
+	}
+	return org, admin, users
+}
+
+// slug keeps the letters and digits of a test name, short
+// enough to leave room in the columns it is used in
+func slug(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+
+	s := strings.Trim(b.String(), "-")
+	if len(s) > 40 {
+		s = strings.TrimSuffix(s[:40], "-")
+	}
+	if s == "" {
+		s = "test"
+	}
+	return s
+}
@@ -0,0 +1,191 @@
+package fixture
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestFixtures(t *testing.T) {
+	repo := mock.NewMockRepo()
+	ctx := context.Background()
+
+	// two tests with the same name, like one test run twice
+	first, second := New(t, repo), New(t, repo)
+	seen := map[string]bool{}
+	for _, f := range []*Fixtures{first, second} {
+		for range 3 {
+			email := f.Email()
+			if seen[email] {
+				t.Fatalf("email %s was returned twice", email)
+			}
+			seen[email] = true
+		}
+		if user := f.User(); seen[user.Email] {
+			t.Fatalf("user email %s was returned twice", user.Email)
+		}
+	}
+
+	org, admin, members := first.OrgWithMembers(3)
+	rows, err := repo.OrgMembersWhereOrg(ctx, org.ID)
+	if err != nil {
+		t.Fatalf("error listing members: %v", err)
+	}
+	if len(rows) != 4 || len(members) != 3 {
+		t.Fatalf("expected an admin and 3 members, got %d rows", len(rows))
+	}
+
+	role, err := repo.OrgMemberRole(ctx, db.OrgMemberRoleParams{OrgID: org.ID, UserID: admin.ID})
+	if err != nil || role != "admin" {
+		t.Errorf("expected %s to be admin, got %q, %v", admin.Email, role, err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(Password)); err != nil {
+		t.Errorf("fixture user's password is not Password: %v", err)
+	}
+
+	user := first.User(func(p *db.UserInsertParams) { p.FirstName = "Changed" })
+	if user.FirstName != "Changed" {
+		t.Errorf("expected update to change the first name, got %q", user.FirstName)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	cases := map[string]string{
+		"TestLogin":                     "testlogin",
+		"TestLogin/wrong_password":      "testlogin-wrong-password",
+		"TestX/#01":                     "testx-01",
+		"/":                             "test",
+		strings.Repeat("abcdefghi/", 5): "abcdefghi-abcdefghi-abcdefghi-abcdefghi",
+	}
+	for name, want := range cases {
+		if got := slug(name); got != want {
+			t.Errorf("slug(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+const testDataset = `
+seed: 7
+users:
+  - email: ada@example.com
+    firstName: Ada
+    lastName: Lovelace
+    superadmin: true
+  - email: grace@example.com
+    firstName: Grace
+    lastName: Hopper
+organisations:
+  - name: Engines
+    members:
+      - email: ada@example.com
+        role: admin
+      - email: grace@example.com
+        role: member
+generate:
+  users: 200
+  organisations: 40
+  minMemberships: 1
+  maxMemberships: 4
+`
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+
+	data, err := ReadDataset(strings.NewReader(testDataset))
+	if err != nil {
+		t.Fatalf("error reading dataset: %v", err)
+	}
+
+	seed := func() (*mock.MockRepo, SeedStats) {
+		repo := mock.NewMockRepo()
+		stats, err := Seed(ctx, repo, data)
+		if err != nil {
+			t.Fatalf("error seeding: %v", err)
+		}
+		return repo, stats
+	}
+
+	repo, stats := seed()
+	if stats.Users != 202 || stats.Organisations != 41 {
+		t.Errorf("expected 202 users and 41 organisations, got %+v", stats)
+	}
+	// 2 listed, an admin for every generated organisation
+	// and at least one membership for every generated user
+	if stats.Memberships < 2+40+200-40 || stats.Memberships > 2+40+200*4 {
+		t.Errorf("unexpected membership count %d", stats.Memberships)
+	}
+
+	ada, err := repo.UserWhereEmail(ctx, "ada@example.com")
+	if err != nil {
+		t.Fatalf("listed user was not inserted: %v", err)
+	}
+	if !ada.IsSuperadmin {
+		t.Error("expected ada to be a superadmin")
+	}
+	entries, err := repo.AuditList(ctx, db.AuditListParams{TargetID: uuid.NullUUID{UUID: ada.ID, Valid: true}, Limit: 10})
+	if err != nil || len(entries) != 1 || entries[0].Action != "user.grant_superadmin" {
+		t.Errorf("expected the grant to be recorded, got %+v, %v", entries, err)
+	}
+
+	// the same dataset makes the same rows
+	again, _ := seed()
+	all := db.UserSearchParams{Limit: 1000}
+	want, _ := repo.UserSearch(ctx, all)
+	got, _ := again.UserSearch(ctx, all)
+	if len(want) != len(got) {
+		t.Fatalf("expected %d users seeding again, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if want[i].Email != got[i].Email || want[i].Phone != got[i].Phone {
+			t.Fatalf("seeding again made %s, expected %s", got[i].Email, want[i].Email)
+		}
+	}
+
+	if _, err := Seed(ctx, repo, data); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected seeding twice to fail, got %v", err)
+	}
+}
+
+func TestReadDatasetErrors(t *testing.T) {
+	cases := map[string]struct {
+		yaml string
+		want string
+	}{
+		"unknown field": {
+			yaml: "usrs: []",
+			want: "field usrs not found",
+		},
+		"unlisted member": {
+			yaml: "organisations: [{name: A, members: [{email: a@example.com, role: admin}]}]",
+			want: "not a listed user",
+		},
+		"bad role": {
+			yaml: `
+users: [{email: a@example.com, firstName: A, lastName: B}]
+organisations: [{name: A, members: [{email: a@example.com, role: owner}]}]`,
+			want: "expected admin or member",
+		},
+		"duplicate user": {
+			yaml: `users: [{email: a@example.com, firstName: A, lastName: B}, {email: a@example.com, firstName: A, lastName: B}]`,
+			want: "listed twice",
+		},
+		"more memberships than organisations": {
+			yaml: "generate: {users: 10, organisations: 2, minMemberships: 1, maxMemberships: 3}",
+			want: "memberships must be",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadDataset(strings.NewReader(tc.yaml))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
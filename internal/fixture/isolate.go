@@ -0,0 +1,47 @@
+package fixture
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// Isolate runs t in a transaction that is rolled back when it ends
+// and returns repo bound to it, so t neither sees nor leaves behind
+// the rows of other tests. transactions begun on the returned repo
+// become savepoints, which only postgres supports, the in-memory and
+// sqlite backends give each test its own database instead. the
+// transaction is a single connection, it must not be used concurrently
+func Isolate(t testing.TB, repo db.RepoQuerier) db.RepoQuerier {
+	t.Helper()
+
+	tx, err := repo.GetDB().Begin(context.Background())
+	if err != nil {
+		t.Fatalf("error beginning the test transaction: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := tx.Rollback(context.Background()); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			t.Errorf("error rolling back the test transaction: %v", err)
+		}
+	})
+
+	return &isolated{RepoQuerier: repo.WithTx(tx), tx: tx}
+}
+
+// isolated is a repo inside the test transaction
+type isolated struct {
+	db.RepoQuerier
+	tx pgx.Tx
+}
+
+// GetDB begins transactions as savepoints of the test transaction
+func (r *isolated) GetDB() db.Db {
+	return db.TxDb(r.tx)
+}
+
+func (r *isolated) WithTx(tx pgx.Tx) db.RepoQuerier {
+	return &isolated{RepoQuerier: r.RepoQuerier.WithTx(tx), tx: tx}
+}
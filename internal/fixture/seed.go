@@ -0,0 +1,333 @@
+package fixture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"gopkg.in/yaml.v3"
+)
+
+// Dataset is the data Seed loads. Users and Organisations are
+// created as listed, Generate adds made up ones around them
+type Dataset struct {
+	// Seed picks the generated data, a dataset with the same
+	// seed makes the same rows every time it is loaded
+	Seed          uint64       `yaml:"seed"`
+	Users         []SeedUser   `yaml:"users"`
+	Organisations []SeedOrg    `yaml:"organisations"`
+	Generate      SeedGenerate `yaml:"generate"`
+}
+
+// SeedUser is a listed user, their password is Password
+type SeedUser struct {
+	Email      string `yaml:"email"`
+	FirstName  string `yaml:"firstName"`
+	LastName   string `yaml:"lastName"`
+	Phone      string `yaml:"phone"`
+	Superadmin bool   `yaml:"superadmin"`
+}
+
+type SeedOrg struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// listed users, by email
+	Members []SeedMember `yaml:"members"`
+}
+
+type SeedMember struct {
+	Email string `yaml:"email"`
+	Role  string `yaml:"role"`
+}
+
+// SeedGenerate is how many users and organisations to make up. every
+// generated organisation has an admin and every generated user joins
+// between MinMemberships and MaxMemberships of them as a member
+type SeedGenerate struct {
+	Users          int    `yaml:"users"`
+	Organisations  int    `yaml:"organisations"`
+	MinMemberships int    `yaml:"minMemberships"`
+	MaxMemberships int    `yaml:"maxMemberships"`
+	Domain         string `yaml:"domain"`
+}
+
+// SeedStats counts the rows Seed made
+type SeedStats struct {
+	Users         int
+	Organisations int
+	Memberships   int
+}
+
+// ReadDataset decodes a yaml dataset, fields it doesn't know are an error
+func ReadDataset(r io.Reader) (Dataset, error) {
+	var data Dataset
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+		return Dataset{}, fmt.Errorf("error decoding dataset: %w", err)
+	}
+
+	if err := data.check(); err != nil {
+		return Dataset{}, err
+	}
+	return data, nil
+}
+
+func (d *Dataset) check() error {
+	emails := make(map[string]bool, len(d.Users))
+	for _, user := range d.Users {
+		if user.Email == "" || user.FirstName == "" || user.LastName == "" {
+			return fmt.Errorf("user %q needs an email, first and last name", user.Email)
+		}
+		if emails[user.Email] {
+			return fmt.Errorf("user %s is listed twice", user.Email)
+		}
+		emails[user.Email] = true
+	}
+
+	for _, org := range d.Organisations {
+		if org.Name == "" {
+			return errors.New("every organisation needs a name")
+		}
+		for _, member := range org.Members {
+			if !emails[member.Email] {
+				return fmt.Errorf("member %s of %s is not a listed user", member.Email, org.Name)
+			}
+			if member.Role != "admin" && member.Role != "member" {
+				return fmt.Errorf("member %s of %s has role %q, expected admin or member", member.Email, org.Name, member.Role)
+			}
+		}
+	}
+
+	gen := &d.Generate
+	if gen.Users < 0 || gen.Organisations < 0 {
+		return errors.New("generate needs a positive number of users and organisations")
+	}
+	if gen.Users > 0 && gen.Organisations == 0 && gen.MaxMemberships > 0 {
+		return errors.New("generated users can't join organisations when none are generated")
+	}
+	if gen.MinMemberships < 0 || gen.MinMemberships > gen.MaxMemberships || gen.MaxMemberships > gen.Organisations {
+		return fmt.Errorf("memberships must be from 0 to the %d generated organisations, with min no more than max", gen.Organisations)
+	}
+	if gen.Domain == "" {
+		gen.Domain = "example.com"
+	}
+	return nil
+}
+
+// Seed loads data in one transaction, nothing is kept if any of it
+// fails. seeding a database twice fails on the listed emails
+func Seed(ctx context.Context, repo db.RepoQuerier, data Dataset) (SeedStats, error) {
+	if err := data.check(); err != nil {
+		return SeedStats{}, err
+	}
+
+	hash, err := passwordHash()
+	if err != nil {
+		return SeedStats{}, fmt.Errorf("error hashing password: %w", err)
+	}
+
+	tx, err := repo.GetDB().Begin(ctx)
+	if err != nil {
+		return SeedStats{}, fmt.Errorf("cannot create database transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	s := &seeder{ctx: ctx, q: repo.WithTx(tx), hash: hash}
+	if err := s.listed(data); err != nil {
+		return SeedStats{}, err
+	}
+	if err := s.generate(data.Generate, rand.New(rand.NewPCG(data.Seed, data.Seed))); err != nil {
+		return SeedStats{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return SeedStats{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return s.stats, nil
+}
+
+type seeder struct {
+	ctx   context.Context
+	q     db.Querier
+	hash  string
+	stats SeedStats
+}
+
+func (s *seeder) listed(data Dataset) error {
+	users := make(map[string]db.User, len(data.Users))
+	for _, listed := range data.Users {
+		user, err := s.user(db.UserInsertParams{
+			Email:     listed.Email,
+			FirstName: listed.FirstName,
+			LastName:  listed.LastName,
+			Phone:     pgtype.Text{String: listed.Phone, Valid: listed.Phone != ""},
+		})
+		if err != nil {
+			return err
+		}
+		users[user.Email] = user
+
+		if listed.Superadmin {
+			if err := s.superadmin(user); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, listed := range data.Organisations {
+		org, err := s.org(db.OrgInsertParams{
+			Name:        listed.Name,
+			Description: pgtype.Text{String: listed.Description, Valid: listed.Description != ""},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, member := range listed.Members {
+			if err := s.member(users[member.Email], org, member.Role); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *seeder) generate(gen SeedGenerate, rng *rand.Rand) error {
+	users := make([]db.User, gen.Users)
+	for i := range users {
+		first := firstNames[rng.IntN(len(firstNames))]
+		last := lastNames[rng.IntN(len(lastNames))]
+
+		// the number keeps emails unique when names repeat
+		user, err := s.user(db.UserInsertParams{
+			Email:     fmt.Sprintf("%s.%s.%d@%s", strings.ToLower(first), strings.ToLower(last), i+1, gen.Domain),
+			FirstName: first,
+			LastName:  last,
+			Phone:     pgtype.Text{String: fmt.Sprintf("+23480%08d", rng.IntN(100_000_000)), Valid: rng.IntN(4) > 0},
+		})
+		if err != nil {
+			return err
+		}
+		users[i] = user
+	}
+
+	orgs := make([]db.Organisation, gen.Organisations)
+	for i := range orgs {
+		name := orgPrefixes[rng.IntN(len(orgPrefixes))] + " " + orgNouns[rng.IntN(len(orgNouns))] + " " + orgSuffixes[rng.IntN(len(orgSuffixes))]
+		org, err := s.org(db.OrgInsertParams{
+			Name:        name,
+			Description: pgtype.Text{String: "Seeded organisation " + name, Valid: rng.IntN(2) == 0},
+		})
+		if err != nil {
+			return err
+		}
+		orgs[i] = org
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	// a user is in an organisation at most once
+	joined := make(map[[2]int]bool)
+	for o, org := range orgs {
+		u := rng.IntN(len(users))
+		joined[[2]int{u, o}] = true
+		if err := s.member(users[u], org, "admin"); err != nil {
+			return err
+		}
+	}
+
+	for u, user := range users {
+		count := gen.MinMemberships + rng.IntN(gen.MaxMemberships-gen.MinMemberships+1)
+		for _, o := range rng.Perm(len(orgs))[:count] {
+			if joined[[2]int{u, o}] {
+				continue
+			}
+			joined[[2]int{u, o}] = true
+			if err := s.member(user, orgs[o], "member"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *seeder) user(params db.UserInsertParams) (db.User, error) {
+	params.Password = s.hash
+	user, err := s.q.UserInsert(s.ctx, params)
+	if err != nil {
+		if errors.Is(err, db.ErrConflict) {
+			return db.User{}, fmt.Errorf("user %s already exists, the database has been seeded: %w", params.Email, err)
+		}
+		return db.User{}, fmt.Errorf("error inserting user %s: %w", params.Email, err)
+	}
+	s.stats.Users++
+	return user, nil
+}
+
+func (s *seeder) org(params db.OrgInsertParams) (db.Organisation, error) {
+	org, err := s.q.OrgInsert(s.ctx, params)
+	if err != nil {
+		return db.Organisation{}, fmt.Errorf("error inserting organisation %s: %w", params.Name, err)
+	}
+	s.stats.Organisations++
+	return org, nil
+}
+
+// superadmin grants the user superadmin and records it in the audit
+// log, like the admin command does, so every superadmin can be traced
+func (s *seeder) superadmin(user db.User) error {
+	if _, err := s.q.UserSetSuperadmin(s.ctx, db.UserSetSuperadminParams{ID: user.ID, IsSuperadmin: true}); err != nil {
+		return fmt.Errorf("error making %s a superadmin: %w", user.Email, err)
+	}
+
+	if _, err := s.q.AuditInsert(s.ctx, db.AuditInsertParams{
+		Action:   "user.grant_superadmin",
+		TargetID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Data:     []byte(`{"seed":true}`),
+	}); err != nil {
+		return fmt.Errorf("error recording %s as a superadmin: %w", user.Email, err)
+	}
+	return nil
+}
+
+func (s *seeder) member(user db.User, org db.Organisation, role string) error {
+	if err := s.q.UserAddOrg(s.ctx, db.UserAddOrgParams{UserID: user.ID, OrgID: org.ID, Role: role}); err != nil {
+		return fmt.Errorf("error adding %s to %s: %w", user.Email, org.Name, err)
+	}
+	s.stats.Memberships++
+	return nil
+}
+
+// the names generated rows are made from
+var (
+	firstNames = []string{
+		"Ada", "Adaeze", "Amara", "Bola", "Chidi", "Chinwe", "Dayo", "Ebere", "Emeka", "Femi",
+		"Funke", "Grace", "Ife", "Ikenna", "Jide", "Kemi", "Kunle", "Lola", "Musa", "Ngozi",
+		"Nkem", "Obinna", "Ola", "Segun", "Tayo", "Tobi", "Tunde", "Uche", "Yemi", "Zainab",
+		"Alan", "Barbara", "Claude", "Dennis", "Edsger", "Frances", "Grace", "Hedy", "Ivan", "John",
+		"Katherine", "Linus", "Margaret", "Niklaus", "Radia", "Rob", "Shafi", "Tim", "Whitfield", "Xiao",
+	}
+	lastNames = []string{
+		"Adebayo", "Adeyemi", "Afolabi", "Balogun", "Bello", "Chukwu", "Eze", "Ibrahim", "Nwosu", "Obi",
+		"Ogunleye", "Okafor", "Okeke", "Olawale", "Onyeka", "Uzor", "Yusuf", "Abubakar", "Lawal", "Mohammed",
+		"Allen", "Backus", "Cerf", "Dijkstra", "Engelbart", "Floyd", "Goldberg", "Hamilton", "Hopper", "Johnson",
+		"Kay", "Knuth", "Lamport", "Liskov", "McCarthy", "Perlman", "Pike", "Ritchie", "Thompson", "Wirth",
+	}
+	orgPrefixes = []string{
+		"Blue", "Bright", "Clear", "Copper", "Delta", "Eastern", "Golden", "Green", "Harbor", "Iron",
+		"Lagos", "Lekki", "Northern", "Open", "Quiet", "Rapid", "Silver", "Solid", "Summit", "Swift",
+	}
+	orgNouns = []string{
+		"Analytics", "Bakery", "Builders", "Cargo", "Clinic", "Data", "Energy", "Farms", "Foods", "Freight",
+		"Health", "Labs", "Logistics", "Media", "Motors", "Payments", "Realty", "Robotics", "Software", "Studios",
+	}
+	orgSuffixes = []string{"Ltd", "Inc", "Co", "Group", "Partners", "Collective", "Cooperative", "Holdings"}
+)
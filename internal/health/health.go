@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/michaelcosj/hng-task-two/internal/buildinfo"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+const checkTimeout = 2 * time.Second
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc checks a dependency, returning details to report about it
+type CheckFunc func(ctx context.Context) (map[string]any, error)
+
+type CheckResult struct {
+	Status    string         `json:"status"`
+	LatencyMs int64          `json:"latencyMs"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Readiness reports whether the instance should be sent traffic. the instance
+// is ready once SetReady(true) is called while every dependency check passes.
+// SetReady(false) is called before the server drains so the load balancer
+// stops routing requests to a stopping instance
+type Readiness struct {
+	ready  atomic.Bool
+	checks map[string]CheckFunc
+}
+
+func NewReadiness(checks map[string]CheckFunc) *Readiness {
+	return &Readiness{checks: checks}
+}
+
+func (r *Readiness) SetReady(ready bool) {
+	r.ready.Store(ready)
+}
+
+func (r *Readiness) Ready() bool {
+	return r.ready.Load()
+}
+
+// Check runs every dependency check at once
+func (r *Readiness) Check(ctx context.Context) ReadinessResponse {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	resp := ReadinessResponse{
+		Status: "ready",
+		Checks: make(map[string]CheckResult, len(r.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range r.checks {
+		wg.Add(1)
+		go func(name string, check CheckFunc) {
+			defer wg.Done()
+
+			start := time.Now()
+			details, err := check(ctx)
+			result := CheckResult{
+				Status:    StatusUp,
+				LatencyMs: time.Since(start).Milliseconds(),
+				Details:   details,
+			}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			resp.Checks[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	for _, result := range resp.Checks {
+		if result.Status != StatusUp {
+			resp.Status = "unavailable"
+		}
+	}
+
+	if !r.Ready() {
+		resp.Status = "unavailable"
+	}
+
+	return resp
+}
+
+func (r *Readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	resp := r.Check(req.Context())
+
+	statusCode := http.StatusOK
+	if resp.Status != "ready" {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, statusCode, resp)
+}
+
+// Liveness only reports that the process is able to serve requests,
+// it deliberately checks no dependencies so an outage of one doesn't
+// get healthy instances restarted
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func Version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildinfo.Get())
+}
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// DatabaseCheck pings the database
+func DatabaseCheck(pinger Pinger) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		return nil, pinger.Ping(ctx)
+	}
+}
+
+// MigrationsCheck checks that the database schema is at least at the expected
+// version. a newer schema is fine since it happens during a rolling deploy
+// when the new release has migrated the database before old instances stop
+func MigrationsCheck(conn db.DBTX, expected int) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		var version int
+		if err := conn.QueryRow(ctx, "SELECT version FROM schema_version").Scan(&version); err != nil {
+			return nil, fmt.Errorf("error reading schema version: %w", err)
+		}
+
+		details := map[string]any{"version": version, "expected": expected}
+		if version < expected {
+			return details, fmt.Errorf("database schema is at version %d, expected %d", version, expected)
+		}
+
+		return details, nil
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	up := func(ctx context.Context) (map[string]any, error) { return nil, nil }
+	down := func(ctx context.Context) (map[string]any, error) { return nil, errors.New("connection refused") }
+
+	tests := []struct {
+		name       string
+		ready      bool
+		checks     map[string]CheckFunc
+		wantStatus int
+		wantDown   string
+	}{
+		{
+			name:       "Test ready with passing checks",
+			ready:      true,
+			checks:     map[string]CheckFunc{"database": up, "migrations": up},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test not ready before start or while draining",
+			ready:      false,
+			checks:     map[string]CheckFunc{"database": up},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "Test failing dependency",
+			ready:      true,
+			checks:     map[string]CheckFunc{"database": down, "migrations": up},
+			wantStatus: http.StatusServiceUnavailable,
+			wantDown:   "database",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			readiness := NewReadiness(test.checks)
+			readiness.SetReady(test.ready)
+
+			resp := httptest.NewRecorder()
+			readiness.ServeHTTP(resp, httptest.NewRequest("GET", "/readyz", nil))
+
+			if resp.Code != test.wantStatus {
+				t.Fatalf("expected %d got %d", test.wantStatus, resp.Code)
+			}
+
+			var data ReadinessResponse
+			if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(data.Checks) != len(test.checks) {
+				t.Errorf("expected %d checks reported got %d", len(test.checks), len(data.Checks))
+			}
+
+			for name, result := range data.Checks {
+				wantStatus := StatusUp
+				if name == test.wantDown {
+					wantStatus = StatusDown
+				}
+
+				if result.Status != wantStatus {
+					t.Errorf("%s check: want %s, got %s", name, wantStatus, result.Status)
+				}
+
+				if result.Status == StatusDown && result.Error == "" {
+					t.Errorf("%s check is down without an error", name)
+				}
+			}
+		})
+	}
+}
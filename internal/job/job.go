@@ -0,0 +1,49 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// job statuses stored in the jobs table
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusDead      = "dead"
+)
+
+const (
+	DefaultMaxAttempts = 10
+
+	backoffBase = 10 * time.Second
+	backoffMax  = time.Hour
+)
+
+// Handler runs a single job. returning an error schedules a retry
+// until the job runs out of attempts and is marked dead
+type Handler func(ctx context.Context, payload []byte) error
+
+// Enqueue adds a job to the queue. pass the querier of the
+// transaction making the business write so the job is only
+// ever run if that transaction commits
+func Enqueue(ctx context.Context, q db.Querier, kind string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding %s job payload: %w", kind, err)
+	}
+
+	if _, err := q.JobInsert(ctx, db.JobInsertParams{
+		Kind:        kind,
+		Payload:     data,
+		MaxAttempts: DefaultMaxAttempts,
+	}); err != nil {
+		return fmt.Errorf("error enqueueing %s job: %w", kind, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// fakeQuerier records the job queries the runner makes
+type fakeQuerier struct {
+	db.Querier
+	inserted  []db.JobInsertParams
+	queued    []db.Job
+	completed []uuid.UUID
+	retried   []db.JobRetryParams
+	killed    []db.JobKillParams
+}
+
+func (q *fakeQuerier) JobInsert(ctx context.Context, arg db.JobInsertParams) (db.Job, error) {
+	q.inserted = append(q.inserted, arg)
+	return db.Job{ID: uuid.New(), Kind: arg.Kind, Payload: arg.Payload, MaxAttempts: arg.MaxAttempts}, nil
+}
+
+func (q *fakeQuerier) JobClaim(ctx context.Context, arg db.JobClaimParams) ([]db.Job, error) {
+	if len(q.queued) == 0 {
+		return nil, nil
+	}
+	j := q.queued[0]
+	q.queued = q.queued[1:]
+	j.Attempts++
+	return []db.Job{j}, nil
+}
+
+func (q *fakeQuerier) JobComplete(ctx context.Context, id uuid.UUID) error {
+	q.completed = append(q.completed, id)
+	return nil
+}
+
+func (q *fakeQuerier) JobRetry(ctx context.Context, arg db.JobRetryParams) error {
+	q.retried = append(q.retried, arg)
+	return nil
+}
+
+func (q *fakeQuerier) JobKill(ctx context.Context, arg db.JobKillParams) error {
+	q.killed = append(q.killed, arg)
+	return nil
+}
+
+func TestEnqueueEncodesPayload(t *testing.T) {
+	repo := &fakeQuerier{}
+
+	if err := Enqueue(context.Background(), repo, "test.job", map[string]string{"key": "value"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.inserted) != 1 {
+		t.Fatalf("expected 1 job got %d", len(repo.inserted))
+	}
+
+	var payload map[string]string
+	if err := json.Unmarshal(repo.inserted[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload["key"] != "value" {
+		t.Errorf("payload not encoded correctly: %s", repo.inserted[0].Payload)
+	}
+
+	if repo.inserted[0].MaxAttempts != DefaultMaxAttempts {
+		t.Errorf("max attempts: want %d, got %d", DefaultMaxAttempts, repo.inserted[0].MaxAttempts)
+	}
+}
+
+func TestRunNext(t *testing.T) {
+	tests := []struct {
+		name          string
+		kind          string
+		attempts      int32
+		handler       Handler
+		wantCompleted bool
+		wantRetried   bool
+		wantKilled    bool
+	}{
+		{
+			name:          "Test successful job is completed",
+			kind:          "test.job",
+			handler:       func(ctx context.Context, payload []byte) error { return nil },
+			wantCompleted: true,
+		},
+		{
+			name:        "Test failed job is retried",
+			kind:        "test.job",
+			handler:     func(ctx context.Context, payload []byte) error { return errors.New("failed") },
+			wantRetried: true,
+		},
+		{
+			name:       "Test failed job out of attempts is dead",
+			kind:       "test.job",
+			attempts:   DefaultMaxAttempts - 1,
+			handler:    func(ctx context.Context, payload []byte) error { return errors.New("failed") },
+			wantKilled: true,
+		},
+		{
+			name:        "Test panicking job is retried",
+			kind:        "test.job",
+			handler:     func(ctx context.Context, payload []byte) error { panic("oh no") },
+			wantRetried: true,
+		},
+		{
+			name:       "Test job without handler is dead",
+			kind:       "unknown.job",
+			handler:    func(ctx context.Context, payload []byte) error { return nil },
+			wantKilled: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := &fakeQuerier{queued: []db.Job{{
+				ID:          uuid.New(),
+				Kind:        test.kind,
+				Payload:     []byte(`{}`),
+				Attempts:    test.attempts,
+				MaxAttempts: DefaultMaxAttempts,
+			}}}
+
+			runner := NewRunner(repo, 1)
+			runner.Register("test.job", test.handler)
+
+			claimed, err := runner.RunNext(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !claimed {
+				t.Fatal("expected a job to be claimed")
+			}
+
+			if got := len(repo.completed) == 1; got != test.wantCompleted {
+				t.Errorf("completed: want %v, got %v", test.wantCompleted, got)
+			}
+
+			if got := len(repo.retried) == 1; got != test.wantRetried {
+				t.Errorf("retried: want %v, got %v", test.wantRetried, got)
+			}
+
+			if got := len(repo.killed) == 1; got != test.wantKilled {
+				t.Errorf("killed: want %v, got %v", test.wantKilled, got)
+			}
+
+			if test.wantRetried && !repo.retried[0].RunAt.Time.After(time.Now()) {
+				t.Errorf("retry should be scheduled in the future, got %v", repo.retried[0].RunAt.Time)
+			}
+		})
+	}
+}
+
+func TestRunStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		NewRunner(&fakeQuerier{}, 2).Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner did not stop after its context was cancelled")
+	}
+}
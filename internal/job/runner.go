@@ -0,0 +1,181 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/michaelcosj/hng-task-two/internal/backoff"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+const (
+	pollInterval = time.Second
+	// jobs get this long to finish before their context is
+	// cancelled, the lease is longer so a job is never picked
+	// up by another worker while it is still running
+	jobTimeout    = time.Minute
+	leaseDuration = jobTimeout + 30*time.Second
+
+	purgeInterval = time.Hour
+	// completed jobs are kept this long for inspection
+	completedRetention = 7 * 24 * time.Hour
+)
+
+// Runner is a pool of workers that claim jobs from the jobs table.
+// any number of runners can share the database since jobs are
+// claimed with SELECT ... FOR UPDATE SKIP LOCKED
+type Runner struct {
+	repo     db.Querier
+	workers  int
+	handlers map[string]Handler
+}
+
+func NewRunner(repo db.Querier, workers int) *Runner {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Runner{
+		repo:     repo,
+		workers:  workers,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register sets the handler for a kind of job, it must
+// be called before Run
+func (r *Runner) Register(kind string, handler Handler) {
+	r.handlers[kind] = handler
+}
+
+// Run starts the workers and blocks until the context is cancelled
+// and every job that was running at that point has finished
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.purge(ctx)
+	}()
+
+	wg.Wait()
+}
+
+func (r *Runner) work(ctx context.Context) {
+	for {
+		claimed, err := r.RunNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("error running job", "error", err)
+		}
+
+		// keep going straight away while there is work queued
+		if claimed && err == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// RunNext claims and runs a single job, it reports whether a job was claimed
+func (r *Runner) RunNext(ctx context.Context) (bool, error) {
+	jobs, err := r.repo.JobClaim(ctx, db.JobClaimParams{
+		LockedUntil: pgtype.Timestamptz{Time: time.Now().Add(leaseDuration), Valid: true},
+		BatchSize:   1,
+	})
+	if err != nil {
+		return false, fmt.Errorf("error claiming job: %w", err)
+	}
+
+	if len(jobs) == 0 {
+		return false, nil
+	}
+
+	// a claimed job is finished and recorded even if the runner is
+	// shutting down, otherwise it would wait for its lease to run out
+	return true, r.process(context.WithoutCancel(ctx), jobs[0])
+}
+
+func (r *Runner) process(ctx context.Context, j db.Job) error {
+	handler, ok := r.handlers[j.Kind]
+	if !ok {
+		return r.repo.JobKill(ctx, db.JobKillParams{
+			ID:        j.ID,
+			LastError: pgtype.Text{String: fmt.Sprintf("no handler registered for %s jobs", j.Kind), Valid: true},
+		})
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	runErr := run(jobCtx, handler, j.Payload)
+	if runErr == nil {
+		return r.repo.JobComplete(ctx, j.ID)
+	}
+
+	slog.Warn("job failed", "kind", j.Kind, "job_id", j.ID, "attempt", j.Attempts, "error", runErr)
+	lastError := pgtype.Text{String: runErr.Error(), Valid: true}
+
+	if j.Attempts >= j.MaxAttempts {
+		return r.repo.JobKill(ctx, db.JobKillParams{
+			ID:        j.ID,
+			LastError: lastError,
+		})
+	}
+
+	return r.repo.JobRetry(ctx, db.JobRetryParams{
+		ID:        j.ID,
+		RunAt:     pgtype.Timestamptz{Time: time.Now().Add(backoff.Exponential(j.Attempts, backoffBase, backoffMax)), Valid: true},
+		LastError: lastError,
+	})
+}
+
+// run calls the handler, turning a panic into an error so
+// a bad job cannot take the worker down with it
+func run(ctx context.Context, handler Handler, payload []byte) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+
+	return handler(ctx, payload)
+}
+
+func (r *Runner) purge(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		before := pgtype.Timestamptz{Time: time.Now().Add(-completedRetention), Valid: true}
+		if _, err := r.repo.JobPurgeCompleted(ctx, before); err != nil && ctx.Err() == nil {
+			slog.Error("error purging completed jobs", "error", err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+const redacted = "[REDACTED]"
+
+// keys whose values are never written to the logs, matched
+// case insensitively with dashes and underscores ignored so
+// access_token, accessToken and Access-Token all match
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"passwordhash":  true,
+	"token":         true,
+	"accesstoken":   true,
+	"refreshtoken":  true,
+	"authorization": true,
+	"cookie":        true,
+	"secret":        true,
+	"apikey":        true,
+}
+
+// New creates a logger writing to w in the given format, dropping records
+// below level and redacting sensitive attributes wherever they are nested
+func New(w io.Writer, level slog.Level, format string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+
+	switch format {
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, use %s or %s", format, FormatJSON, FormatText)
+	}
+}
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, use debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// IsSensitive reports whether values logged under key are redacted
+func IsSensitive(key string) bool {
+	key = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	return sensitiveKeys[key]
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	// maps logged as a single value aren't split into attributes
+	// so the handler never passes their keys to ReplaceAttr
+	if a.Value.Kind() == slog.KindAny {
+		switch v := a.Value.Any().(type) {
+		case map[string]any:
+			return slog.Any(a.Key, redactMap(v))
+		case map[string]string:
+			m := make(map[string]any, len(v))
+			for key, value := range v {
+				m[key] = value
+			}
+			return slog.Any(a.Key, redactMap(m))
+		}
+	}
+
+	return a
+}
+
+func redactMap(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for key, value := range m {
+		switch {
+		case IsSensitive(key):
+			out[key] = redacted
+		case isMap(value):
+			out[key] = redactMap(value.(map[string]any))
+		default:
+			out[key] = value
+		}
+	}
+	return out
+}
+
+func isMap(v any) bool {
+	_, ok := v.(map[string]any)
+	return ok
+}
+
+type contextKey struct{}
+
+// scope holds the logger for a request. it is shared through the context
+// so fields added deep in the handler chain, like the user once they
+// are authenticated, also show up in the request log written on the way out
+type scope struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+// NewContext returns a context carrying logger, replacing any logger
+// already in ctx for the code called with the new context
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &scope{logger: logger})
+}
+
+// FromContext returns the logger in ctx, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	s, ok := ctx.Value(contextKey{}).(*scope)
+	if !ok {
+		return slog.Default()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logger
+}
+
+// AddFields adds attributes to the logger in ctx, they are seen by
+// everything logging with the context, including callers further up
+func AddFields(ctx context.Context, args ...any) {
+	s, ok := ctx.Value(contextKey{}).(*scope)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	s.logger = s.logger.With(args...)
+	s.mu.Unlock()
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelInfo, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("login",
+		"email", "john@example.com",
+		"password", "hunter2",
+		slog.Group("response", "accessToken", "eyJhbGciOi"),
+		"headers", map[string]any{"Authorization": "Bearer eyJhbGciOi", "Accept": "application/json"},
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "eyJhbGciOi"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log contains %q: %s", secret, out)
+		}
+	}
+
+	for _, kept := range []string{"john@example.com", "application/json"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("log is missing %q: %s", kept, out)
+		}
+	}
+}
+
+func TestAddFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelInfo, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := NewContext(context.Background(), logger.With("request_id", "abc"))
+
+	// fields added by a handler further down are seen by the caller
+	func(ctx context.Context) {
+		AddFields(ctx, "user_id", "123")
+	}(ctx)
+
+	FromContext(ctx).Info("request handled")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record["request_id"] != "abc" || record["user_id"] != "123" {
+		t.Errorf("expected request and user ids in %v", record)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	level, err := ParseLevel("warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	logger, _ := New(&buf, level, FormatText)
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	if strings.Contains(buf.String(), "dropped") || !strings.Contains(buf.String(), "kept") {
+		t.Errorf("unexpected output for level %v: %s", level, buf.String())
+	}
+
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
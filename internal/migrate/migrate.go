@@ -0,0 +1,213 @@
+// Package migrate applies the embedded postgres migrations. it keeps
+// the version in the same table tern does so databases migrated with
+// tern carry on from where they are
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/michaelcosj/hng-task-two/database"
+)
+
+// key of the advisory lock held while migrating, so instances
+// starting together don't migrate the database at the same time
+const lockKey int64 = 0x686e675f6d6967 // "hng_mig"
+
+// Migrator migrates a postgres database to a version of the embedded migrations
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []database.Migration
+}
+
+// MigrationStatus is a migration and whether it has been applied
+type MigrationStatus struct {
+	Version int
+	Name    string
+	Applied bool
+}
+
+// Status is the version the database is at and the migrations it has
+type Status struct {
+	Version    int
+	Latest     int
+	Migrations []MigrationStatus
+}
+
+func New(pool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := database.ReadMigrations(database.Migrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+// Latest returns the version of the newest migration
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every migration the database doesn't have. a database
+// newer than the migrations is left alone, it happens during a rolling
+// deploy when the new release has migrated before old instances stop
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *pgxpool.Conn, current int) error {
+		if current >= m.Latest() {
+			return nil
+		}
+		return m.migrate(ctx, conn, current, m.Latest())
+	})
+}
+
+// Down reverts the newest applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(conn *pgxpool.Conn, current int) error {
+		if current == 0 {
+			return errors.New("no migrations to revert")
+		}
+		return m.migrate(ctx, conn, current, current-1)
+	})
+}
+
+// To applies or reverts migrations until the database is at version
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version < 0 || version > m.Latest() {
+		return fmt.Errorf("version %d does not exist, the latest is %d", version, m.Latest())
+	}
+
+	return m.locked(ctx, func(conn *pgxpool.Conn, current int) error {
+		return m.migrate(ctx, conn, current, version)
+	})
+}
+
+func (m *Migrator) Status(ctx context.Context) (Status, error) {
+	status := Status{Latest: m.Latest()}
+
+	err := m.locked(ctx, func(conn *pgxpool.Conn, current int) error {
+		status.Version = current
+		return nil
+	})
+	if err != nil {
+		return Status{}, err
+	}
+
+	for _, migration := range m.migrations {
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name,
+			Applied: migration.Version <= status.Version,
+		})
+	}
+
+	return status, nil
+}
+
+// locked runs fn on a connection holding the migration lock,
+// with the version the database is at
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn, current int) error) (err error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	// waiting for the lock and migrating can take longer than
+	// the statement timeout the pool's connections are opened with
+	if _, err := conn.Exec(ctx, "SET statement_timeout = 0"); err != nil {
+		return fmt.Errorf("error lifting statement timeout: %w", err)
+	}
+	defer func() {
+		// back to the connection's default before it returns to the pool
+		if _, resetErr := conn.Exec(context.Background(), "RESET statement_timeout"); resetErr != nil {
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&acquired); err != nil {
+		return fmt.Errorf("error taking migration lock: %w", err)
+	}
+	if !acquired {
+		slog.Info("waiting for another instance to finish migrating")
+		if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+			return fmt.Errorf("error taking migration lock: %w", err)
+		}
+	}
+
+	defer func() {
+		// the lock belongs to the session, closing the connection
+		// releases it if unlocking fails
+		if _, unlockErr := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey); unlockErr != nil {
+			conn.Conn().Close(context.Background())
+			err = errors.Join(err, fmt.Errorf("error releasing migration lock: %w", unlockErr))
+		}
+	}()
+
+	// created the way tern creates it
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_version (version int4 NOT NULL);
+		INSERT INTO schema_version (version) SELECT 0 WHERE NOT EXISTS (SELECT 1 FROM schema_version);
+	`); err != nil {
+		return fmt.Errorf("error creating schema_version table: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRow(ctx, "SELECT version FROM schema_version").Scan(&current); err != nil {
+		return fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	return fn(conn, current)
+}
+
+// migrate moves the database from version current to target,
+// one migration and transaction at a time
+func (m *Migrator) migrate(ctx context.Context, conn *pgxpool.Conn, current int, target int) error {
+	if current > m.Latest() {
+		return fmt.Errorf("database is at version %d, newer than the latest migration %d", current, m.Latest())
+	}
+
+	for current < target {
+		migration := m.migrations[current]
+		slog.Info("applying migration", "migration", migration.Name)
+
+		if err := apply(ctx, conn, migration.Up, migration.Version); err != nil {
+			return fmt.Errorf("error applying %s: %w", migration.Name, err)
+		}
+		current++
+	}
+
+	for current > target {
+		migration := m.migrations[current-1]
+		if !migration.Reversible {
+			return fmt.Errorf("migration %s can't be reverted", migration.Name)
+		}
+		slog.Info("reverting migration", "migration", migration.Name)
+
+		if err := apply(ctx, conn, migration.Down, migration.Version-1); err != nil {
+			return fmt.Errorf("error reverting %s: %w", migration.Name, err)
+		}
+		current--
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, conn *pgxpool.Conn, sql string, version int) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		// without arguments the statements are sent together
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, "UPDATE schema_version SET version = $1", version)
+		return err
+	})
+}
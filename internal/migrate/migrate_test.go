@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// openTestPool connects to MOCK_PG_URI with a schema of its own,
+// so migrating it up and down doesn't touch the shared tables
+func openTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	uri := os.Getenv("MOCK_PG_URI")
+	if uri == "" {
+		t.Skip("MOCK_PG_URI is not set")
+	}
+
+	ctx := context.Background()
+	admin, err := pgxpool.New(ctx, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE") })
+
+	config, err := pgxpool.ParseConfig(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// public is kept for the extensions the migrations use
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ",public"
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	migrator, err := New(openTestPool(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectVersion := func(want int) {
+		t.Helper()
+
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Version != want {
+			t.Fatalf("expected version %d, got %d", want, status.Version)
+		}
+	}
+
+	// instances started together all try to migrate
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- migrator.Up(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("error migrating up: %v", err)
+		}
+	}
+	expectVersion(migrator.Latest())
+
+	if err := migrator.Down(ctx); err != nil {
+		t.Fatalf("error migrating down: %v", err)
+	}
+	expectVersion(migrator.Latest() - 1)
+
+	// every down migration is run on the way to an empty database
+	if err := migrator.To(ctx, 0); err != nil {
+		t.Fatalf("error migrating to 0: %v", err)
+	}
+	expectVersion(0)
+
+	if err := migrator.Down(ctx); err == nil {
+		t.Fatal("expected an error migrating an empty database down")
+	}
+
+	if err := migrator.To(ctx, migrator.Latest()+1); err == nil {
+		t.Fatal("expected an error migrating to an unknown version")
+	}
+
+	if err := migrator.To(ctx, 2); err != nil {
+		t.Fatalf("error migrating to 2: %v", err)
+	}
+	expectVersion(2)
+}
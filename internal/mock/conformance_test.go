@@ -0,0 +1,14 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) db.RepoQuerier {
+		return NewMockRepo()
+	})
+}
@@ -2,8 +2,390 @@ package mock
 
 import (
 	"context"
-	"log"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/michaelcosj/hng-task-two/internal/db"
 )
+
+var (
+	errNoSQL         = errors.New("mock: the in-memory database does not run sql")
+	errNestedTx      = errors.New("mock: nested transactions are not supported")
+	errTxAborted     = &pgconn.PgError{Code: pgerrcode.InFailedSQLTransaction, Message: "mock: current transaction is aborted, commands ignored until end of transaction block"}
+	errSerialization = &pgconn.PgError{Code: pgerrcode.SerializationFailure, Message: "mock: could not serialize access due to concurrent update"}
+)
+
+// bits of tables.owned, one for each table
+const (
+	ownUsers uint16 = 1 << iota
+	ownOrgs
+	ownMembers
+	ownWebhooks
+	ownDeliveries
+	ownJobs
+	ownEvents
+	ownRateLimits
+	ownAudit
+)
+
+// tables is one version of the data. a version is never changed once
+// it is committed, writes go to a copy so readers and transactions
+// keep seeing the version they started with
+type tables struct {
+	users      []db.User
+	orgs       []db.Organisation
+	members    []db.UserOrganisation
+	webhooks   []db.WebhookEndpoint
+	deliveries []db.WebhookDelivery
+	jobs       []db.Job
+	events     []db.OrgEvent
+	rateLimits map[string]db.RateLimit
+	audit      []db.AuditLog
+
+	// tables already copied by this version, only those can be changed
+	owned uint16
+}
+
+// next returns a version to write to. it shares every table with t
+// until the table is first changed
+func (t *tables) next() *tables {
+	next := *t
+	next.owned = 0
+	return &next
+}
+
+// own copies rows the first time the version changes them
+func own[T any](t *tables, table uint16, rows *[]T) {
+	if t.owned&table == 0 {
+		*rows = slices.Clone(*rows)
+		t.owned |= table
+	}
+}
+
+func (t *tables) ownRateLimits() {
+	if t.owned&ownRateLimits == 0 {
+		t.rateLimits = maps.Clone(t.rateLimits)
+		if t.rateLimits == nil {
+			t.rateLimits = make(map[string]db.RateLimit)
+		}
+		t.owned |= ownRateLimits
+	}
+}
+
+// MockDB holds the committed data of the in-memory database and hands
+// out transactions over it. queries go through MockRepo instead of sql
+type MockDB struct {
+	mu      sync.RWMutex
+	current *tables
+
+	// org_events and audit_log ids, like a postgres
+	// sequence they are not rolled back
+	eventId atomic.Int64
+	auditId atomic.Int64
+
+	// organisations a transaction is inserting org events for, closed
+	// when it ends. like the advisory lock in postgres they make those
+	// inserts commit in the order their ids were taken
+	eventLocksMu sync.Mutex
+	eventLocks   map[uuid.UUID]chan struct{}
+
+	listenersMu sync.Mutex
+	listeners   map[*func(orgId uuid.UUID)]struct{}
+}
+
+var _ db.Db = (*MockDB)(nil)
+
+func NewMockDB() *MockDB {
+	return &MockDB{
+		current:    &tables{},
+		eventLocks: make(map[uuid.UUID]chan struct{}),
+		listeners:  make(map[*func(orgId uuid.UUID)]struct{}),
+	}
+}
+
+func (d *MockDB) committed() *tables {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.current
+}
+
+// write applies fn to a new version of the committed data and
+// commits it if fn succeeds
+func (d *MockDB) write(fn func(t *tables) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	next := d.current.next()
+	if err := fn(next); err != nil {
+		return err
+	}
+
+	d.current = next
+	return nil
+}
+
+// commit makes data the committed version when nothing was committed
+// since base, otherwise it applies writes again on the latest version
+func (d *MockDB) commit(base *tables, data *tables, writes []func(t *tables) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.current == base {
+		d.current = data
+		return nil
+	}
+
+	next := d.current.next()
+	for _, write := range writes {
+		if err := write(next); err != nil {
+			return errSerialization
+		}
+	}
+
+	d.current = next
+	return nil
+}
+
+// lockEvents waits until nothing else is inserting org events for the
+// organisation, unlock lets the next one in
+func (d *MockDB) lockEvents(ctx context.Context, orgId uuid.UUID) (unlock func(), err error) {
+	for {
+		d.eventLocksMu.Lock()
+		held, ok := d.eventLocks[orgId]
+		if !ok {
+			released := make(chan struct{})
+			d.eventLocks[orgId] = released
+			d.eventLocksMu.Unlock()
+
+			return func() {
+				d.eventLocksMu.Lock()
+				delete(d.eventLocks, orgId)
+				d.eventLocksMu.Unlock()
+				close(released)
+			}, nil
+		}
+		d.eventLocksMu.Unlock()
+
+		select {
+		case <-held:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Listen calls fn with the organisation of every org event once it is
+// committed, like the notifications of the org_events trigger
+func (d *MockDB) Listen(fn func(orgId uuid.UUID)) (stop func()) {
+	d.listenersMu.Lock()
+	defer d.listenersMu.Unlock()
+
+	key := &fn
+	d.listeners[key] = struct{}{}
+
+	return func() {
+		d.listenersMu.Lock()
+		defer d.listenersMu.Unlock()
+		delete(d.listeners, key)
+	}
+}
+
+func (d *MockDB) notify(orgIds ...uuid.UUID) {
+	d.listenersMu.Lock()
+	listeners := make([]func(uuid.UUID), 0, len(d.listeners))
+	for fn := range d.listeners {
+		listeners = append(listeners, *fn)
+	}
+	d.listenersMu.Unlock()
+
+	for _, orgId := range orgIds {
+		for _, fn := range listeners {
+			fn(orgId)
+		}
+	}
+}
+
+func (d *MockDB) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Begin starts a transaction that sees the data committed when it began
+func (d *MockDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	base := d.committed()
+	return &mockTx{db: d, base: base, data: base.next()}, nil
+}
+
+// BeginTx ignores opts, a mock transaction already fails to commit
+// when it conflicts with another like a serializable one does
+func (d *MockDB) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	return d.Begin(ctx)
+}
+
+func (d *MockDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errNoSQL
+}
+
+func (d *MockDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, errNoSQL
+}
+
+func (d *MockDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return errRow{errNoSQL}
+}
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(dest ...any) error { return r.err }
+
+// mockTx works on its own version of the data, started from the
+// version committed when it began. its writes are kept so they can
+// be applied again on top of anything committed in the meantime, a
+// write that no longer succeeds fails the commit with a serialization
+// failure. an error breaking a constraint aborts the transaction like
+// it does in postgres
+type mockTx struct {
+	pgx.Tx
+
+	db *MockDB
+
+	mu      sync.Mutex
+	base    *tables
+	data    *tables
+	writes  []func(t *tables) error
+	notify  []uuid.UUID
+	unlock  map[uuid.UUID]func()
+	aborted bool
+	done    bool
+}
+
+func (tx *mockTx) usable() error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	if tx.aborted {
+		return errTxAborted
+	}
+	return nil
+}
+
+// fail aborts the transaction on errors postgres aborts it for
+func (tx *mockTx) fail(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		tx.aborted = true
+	}
+	return err
+}
+
+func (tx *mockTx) read(fn func(t *tables) error) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if err := tx.usable(); err != nil {
+		return err
+	}
+	return tx.fail(fn(tx.data))
+}
+
+func (tx *mockTx) write(fn func(t *tables) error) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if err := tx.usable(); err != nil {
+		return err
+	}
+	if err := fn(tx.data); err != nil {
+		return tx.fail(err)
+	}
+
+	tx.writes = append(tx.writes, fn)
+	return nil
+}
+
+// lockEvents holds the organisation's event lock until the transaction ends
+func (tx *mockTx) lockEvents(ctx context.Context, orgId uuid.UUID) error {
+	tx.mu.Lock()
+	_, held := tx.unlock[orgId]
+	err := tx.usable()
+	tx.mu.Unlock()
+	if held || err != nil {
+		return err
+	}
+
+	unlock, err := tx.db.lockEvents(ctx, orgId)
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.unlock == nil {
+		tx.unlock = make(map[uuid.UUID]func())
+	}
+	tx.unlock[orgId] = unlock
+	return nil
+}
+
+func (tx *mockTx) release() {
+	for _, unlock := range tx.unlock {
+		unlock()
+	}
+	tx.unlock = nil
+}
+
+func (tx *mockTx) Commit(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+	defer tx.release()
+
+	if tx.aborted {
+		return pgx.ErrTxCommitRollback
+	}
+
+	if err := tx.db.commit(tx.base, tx.data, tx.writes); err != nil {
+		return err
+	}
+
+	tx.db.notify(tx.notify...)
+	return nil
+}
+
+func (tx *mockTx) Rollback(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+	tx.release()
+	return nil
+}
+
+func (tx *mockTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return nil, errNestedTx
+}
+
+func (tx *mockTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errNoSQL
+}
+
+func (tx *mockTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, errNoSQL
+}
+
+func (tx *mockTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return errRow{errNoSQL}
+}
@@ -1,65 +1,300 @@
 package mock
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/michaelcosj/hng-task-two/internal/db"
 )
 
+// MockRepo is an in-memory db.RepoQuerier, used by tests that don't
+// need postgres and by the server when it runs without a database. it
+// keeps the unique, foreign key and length constraints of the schema
+// and reports breaking them with the same errors postgres does. it is
+// safe to use from many goroutines, queries outside a transaction
+// each commit on their own
 type MockRepo struct {
-	userStore    map[string]db.User
-	orgStore     map[string]db.Organisation
-	userOrgStore map[string][]db.UserOrganisation
-
-	db MockDB
+	db *MockDB
+	// nil outside a transaction
+	tx *mockTx
 }
 
+var _ db.RepoQuerier = (*MockRepo)(nil)
+
 func NewMockRepo() *MockRepo {
-	return &MockRepo{db: MockDB{}}
+	return &MockRepo{db: NewMockDB()}
 }
 
+// WithTx returns a repo that works inside tx, which must have been
+// begun on the repo's database
 func (r *MockRepo) WithTx(tx pgx.Tx) db.RepoQuerier {
-	return r
+	mockTx, ok := tx.(*mockTx)
+	if !ok || mockTx.db != r.db {
+		panic("mock: transaction was not begun on the repo's database")
+	}
+	return &MockRepo{db: r.db, tx: mockTx}
 }
 
 func (r *MockRepo) GetDB() db.Db {
 	return r.db
 }
 
-func (r *MockRepo) FindUserInOrgs(ctx context.Context, arg db.FindUserInOrgsParams) (db.User, error) {
-	user, err := r.UserWhereId(ctx, arg.FindUser)
+// DB returns the in-memory database, to listen for org events
+func (r *MockRepo) DB() *MockDB {
+	return r.db
+}
+
+func (r *MockRepo) read(fn func(t *tables) error) error {
+	if r.tx != nil {
+		return db.ConvertError(r.tx.read(fn))
+	}
+	return db.ConvertError(fn(r.db.committed()))
+}
+
+// write runs fn against the transaction's data, or commits
+// it straight away outside a transaction. fn must check
+// constraints before changing anything
+func (r *MockRepo) write(fn func(t *tables) error) error {
+	if r.tx != nil {
+		return db.ConvertError(r.tx.write(fn))
+	}
+	return db.ConvertError(r.db.write(fn))
+}
+
+// notify tells listeners about an org event once it is committed
+func (r *MockRepo) notify(orgId uuid.UUID) {
+	if r.tx == nil {
+		r.db.notify(orgId)
+		return
+	}
+
+	r.tx.mu.Lock()
+	defer r.tx.mu.Unlock()
+	r.tx.notify = append(r.tx.notify, orgId)
+}
+
+func constraintError(code string, constraint string) error {
+	return &pgconn.PgError{Code: code, ConstraintName: constraint, Message: "mock: " + constraint}
+}
+
+// varchar reports values too long for a varchar column like postgres does
+func varchar(column string, value string, length int) error {
+	if utf8.RuneCountInString(value) <= length {
+		return nil
+	}
+
+	return &pgconn.PgError{
+		Code:       pgerrcode.StringDataRightTruncationDataException,
+		ColumnName: column,
+		Message:    fmt.Sprintf("mock: value too long for type character varying(%d)", length),
+	}
+}
+
+func now() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: time.Now(), Valid: true}
+}
+
+func find[T any](rows []T, match func(T) bool) (T, error) {
+	for _, row := range rows {
+		if match(row) {
+			return row, nil
+		}
+	}
+
+	var zero T
+	return zero, pgx.ErrNoRows
+}
+
+func filter[T any](rows []T, match func(T) bool) []T {
+	var found []T
+	for _, row := range rows {
+		if match(row) {
+			found = append(found, row)
+		}
+	}
+	return found
+}
+
+func (t *tables) hasUser(id uuid.UUID) bool {
+	return slices.ContainsFunc(t.users, func(u db.User) bool { return u.ID == id })
+}
+
+func (t *tables) hasOrg(id uuid.UUID) bool {
+	return slices.ContainsFunc(t.orgs, func(o db.Organisation) bool { return o.ID == id })
+}
+
+// ------ Users ------ //
+
+func (r *MockRepo) UserInsert(ctx context.Context, arg db.UserInsertParams) (db.User, error) {
+	user := db.User{
+		ID:        uuid.New(),
+		Email:     arg.Email,
+		FirstName: arg.FirstName,
+		LastName:  arg.LastName,
+		Password:  arg.Password,
+		Phone:     arg.Phone,
+		Status:    "active",
+	}
+
+	err := r.write(func(t *tables) error {
+		for _, err := range []error{
+			varchar("email", user.Email, 255),
+			varchar("first_name", user.FirstName, 255),
+			varchar("last_name", user.LastName, 255),
+			varchar("password", user.Password, 255),
+			varchar("phone", user.Phone.String, 16),
+		} {
+			if err != nil {
+				return err
+			}
+		}
+
+		if slices.ContainsFunc(t.users, func(u db.User) bool { return u.Email == user.Email }) {
+			return constraintError(pgerrcode.UniqueViolation, "users_email_key")
+		}
+
+		own(t, ownUsers, &t.users)
+		t.users = append(t.users, user)
+		return nil
+	})
 	if err != nil {
 		return db.User{}, err
 	}
 
-	for _, auth_user_org := range r.userOrgStore[arg.AuthUser.String()] {
-		auth_org, _ := r.OrganisationWhereId(ctx, auth_user_org.OrgID)
-		for _, find_user_org := range r.userOrgStore[arg.FindUser.String()] {
-			find_org, _ := r.OrganisationWhereId(ctx, find_user_org.OrgID)
-			if auth_org == find_org {
-				return user, nil
+	return user, nil
+}
+
+func (r *MockRepo) UserWhereEmail(ctx context.Context, email string) (user db.User, err error) {
+	err = r.read(func(t *tables) error {
+		user, err = find(t.users, func(u db.User) bool { return u.Email == email })
+		return err
+	})
+	return user, err
+}
+
+func (r *MockRepo) UserWhereId(ctx context.Context, id uuid.UUID) (user db.User, err error) {
+	err = r.read(func(t *tables) error {
+		user, err = find(t.users, func(u db.User) bool { return u.ID == id })
+		return err
+	})
+	return user, err
+}
+
+func (r *MockRepo) FindUserInOrgs(ctx context.Context, arg db.FindUserInOrgsParams) (user db.User, err error) {
+	err = r.read(func(t *tables) error {
+		for _, authMember := range t.members {
+			if authMember.UserID != arg.AuthUser {
+				continue
+			}
+
+			for _, member := range t.members {
+				if member.OrgID == authMember.OrgID && member.UserID == arg.FindUser {
+					user, err = find(t.users, func(u db.User) bool { return u.ID == arg.FindUser })
+					return err
+				}
 			}
 		}
-	}
 
-	return db.User{}, fmt.Errorf("not found")
+		return pgx.ErrNoRows
+	})
+	return user, err
 }
 
-func (r *MockRepo) OrgAllWhereUser(ctx context.Context, userID uuid.UUID) ([]db.Organisation, error) {
-	var orgs []db.Organisation
-	for _, user_org := range r.userOrgStore[userID.String()] {
-		org, err := r.OrganisationWhereId(ctx, user_org.OrgID)
-		if err == nil {
-			orgs = append(orgs, org)
+// UserSearch matches like ILIKE, ignoring case
+func (r *MockRepo) UserSearch(ctx context.Context, arg db.UserSearchParams) (users []db.User, err error) {
+	query := strings.ToLower(arg.Query)
+	err = r.read(func(t *tables) error {
+		users = filter(t.users, func(u db.User) bool {
+			return strings.Contains(strings.ToLower(u.Email), query) ||
+				strings.Contains(strings.ToLower(u.FirstName+" "+u.LastName), query)
+		})
+		return nil
+	})
+
+	slices.SortFunc(users, func(a, b db.User) int { return strings.Compare(a.Email, b.Email) })
+	users = users[min(int(arg.Offset), len(users)):]
+	return users[:min(int(arg.Limit), len(users))], err
+}
+
+func (r *MockRepo) UserUpdatePassword(ctx context.Context, arg db.UserUpdatePasswordParams) (updated int64, err error) {
+	err = r.write(func(t *tables) error {
+		if err := varchar("password", arg.Password, 255); err != nil {
+			return err
 		}
+
+		updated = t.updateUser(arg.ID, func(u *db.User) {
+			u.Password = arg.Password
+			u.TokenVersion++
+		})
+		return nil
+	})
+	return updated, err
+}
+
+var userStatuses = []string{"active", "suspended", "deactivated"}
+
+func (r *MockRepo) UserSetStatus(ctx context.Context, arg db.UserSetStatusParams) (updated int64, err error) {
+	if !slices.Contains(userStatuses, arg.Status) {
+		return 0, constraintError(pgerrcode.CheckViolation, "users_status_check")
+	}
+	if err := varchar("status_reason", arg.StatusReason.String, 255); err != nil {
+		return 0, err
 	}
 
-	return orgs, nil
+	err = r.write(func(t *tables) error {
+		updated = t.updateUser(arg.ID, func(u *db.User) {
+			u.Status = arg.Status
+			u.StatusReason = arg.StatusReason
+			u.StatusChangedAt = now()
+			if arg.Status != "active" {
+				u.TokenVersion++
+			}
+		})
+		return nil
+	})
+	return updated, err
+}
+
+func (r *MockRepo) UserSetSuperadmin(ctx context.Context, arg db.UserSetSuperadminParams) (updated int64, err error) {
+	err = r.write(func(t *tables) error {
+		updated = t.updateUser(arg.ID, func(u *db.User) { u.IsSuperadmin = arg.IsSuperadmin })
+		return nil
+	})
+	return updated, err
+}
+
+func (r *MockRepo) UserRevokeTokens(ctx context.Context, id uuid.UUID) (updated int64, err error) {
+	err = r.write(func(t *tables) error {
+		updated = t.updateUser(id, func(u *db.User) { u.TokenVersion++ })
+		return nil
+	})
+	return updated, err
+}
+
+func (t *tables) updateUser(id uuid.UUID, update func(u *db.User)) int64 {
+	i := slices.IndexFunc(t.users, func(u db.User) bool { return u.ID == id })
+	if i < 0 {
+		return 0
+	}
+
+	own(t, ownUsers, &t.users)
+	update(&t.users[i])
+	return 1
 }
 
+// ------ Organisations ------ //
+
 func (r *MockRepo) OrgInsert(ctx context.Context, arg db.OrgInsertParams) (db.Organisation, error) {
 	org := db.Organisation{
 		ID:          uuid.New(),
@@ -67,69 +302,727 @@ func (r *MockRepo) OrgInsert(ctx context.Context, arg db.OrgInsertParams) (db.Or
 		Description: arg.Description,
 	}
 
-	r.orgStore[org.ID.String()] = org
+	err := r.write(func(t *tables) error {
+		if err := varchar("name", org.Name, 255); err != nil {
+			return err
+		}
+
+		own(t, ownOrgs, &t.orgs)
+		t.orgs = append(t.orgs, org)
+		return nil
+	})
+	if err != nil {
+		return db.Organisation{}, err
+	}
 
 	return org, nil
 }
 
-func (r *MockRepo) OrgWhereUser(ctx context.Context, arg db.OrgWhereUserParams) (db.Organisation, error) {
-	for _, user_org := range r.userOrgStore[arg.UserID.String()] {
-		if user_org.OrgID.String() == arg.OrgID.String() {
-			return r.OrganisationWhereId(ctx, user_org.OrgID)
+func (r *MockRepo) OrgUpdate(ctx context.Context, arg db.OrgUpdateParams) (org db.Organisation, err error) {
+	err = r.write(func(t *tables) error {
+		if err := varchar("name", arg.Name, 255); err != nil {
+			return err
+		}
+
+		i := slices.IndexFunc(t.orgs, func(o db.Organisation) bool { return o.ID == arg.ID })
+		if i < 0 {
+			return pgx.ErrNoRows
 		}
-	}
 
-	return db.Organisation{}, fmt.Errorf("not found")
+		own(t, ownOrgs, &t.orgs)
+		t.orgs[i].Name = arg.Name
+		t.orgs[i].Description = arg.Description
+		org = t.orgs[i]
+		return nil
+	})
+	return org, err
 }
 
-func (r *MockRepo) OrganisationWhereId(ctx context.Context, id uuid.UUID) (db.Organisation, error) {
-	org, ok := r.orgStore[id.String()]
-	if !ok {
-		return db.Organisation{}, fmt.Errorf("not found")
-	}
+func (r *MockRepo) OrganisationWhereId(ctx context.Context, id uuid.UUID) (org db.Organisation, err error) {
+	err = r.read(func(t *tables) error {
+		org, err = find(t.orgs, func(o db.Organisation) bool { return o.ID == id })
+		return err
+	})
+	return org, err
+}
 
-	return org, nil
+func (r *MockRepo) OrgWhereUser(ctx context.Context, arg db.OrgWhereUserParams) (org db.Organisation, err error) {
+	err = r.read(func(t *tables) error {
+		if _, err := find(t.members, func(m db.UserOrganisation) bool {
+			return m.UserID == arg.UserID && m.OrgID == arg.OrgID
+		}); err != nil {
+			return err
+		}
+
+		org, err = find(t.orgs, func(o db.Organisation) bool { return o.ID == arg.OrgID })
+		return err
+	})
+	return org, err
+}
+
+func (r *MockRepo) OrgAllWhereUser(ctx context.Context, userID uuid.UUID) (orgs []db.Organisation, err error) {
+	err = r.read(func(t *tables) error {
+		for _, member := range t.members {
+			if member.UserID != userID {
+				continue
+			}
+
+			if org, err := find(t.orgs, func(o db.Organisation) bool { return o.ID == member.OrgID }); err == nil {
+				orgs = append(orgs, org)
+			}
+		}
+		return nil
+	})
+	return orgs, err
+}
+
+// OrgSearch matches like ILIKE, ignoring case
+func (r *MockRepo) OrgSearch(ctx context.Context, arg db.OrgSearchParams) (orgs []db.Organisation, err error) {
+	query := strings.ToLower(arg.Query)
+	err = r.read(func(t *tables) error {
+		orgs = filter(t.orgs, func(o db.Organisation) bool {
+			return strings.Contains(strings.ToLower(o.Name), query)
+		})
+		return nil
+	})
+
+	slices.SortFunc(orgs, func(a, b db.Organisation) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+	orgs = orgs[min(int(arg.Offset), len(orgs)):]
+	return orgs[:min(int(arg.Limit), len(orgs))], err
 }
 
+// ------ Members ------ //
+
 func (r *MockRepo) UserAddOrg(ctx context.Context, arg db.UserAddOrgParams) error {
-	user_org := db.UserOrganisation{
-		UserID: arg.UserID,
-		OrgID:  arg.OrgID,
+	return r.write(func(t *tables) error {
+		if err := varchar("role", arg.Role, 16); err != nil {
+			return err
+		}
+
+		if !t.hasUser(arg.UserID) {
+			return constraintError(pgerrcode.ForeignKeyViolation, "user_organisations_user_id_fkey")
+		}
+
+		if !t.hasOrg(arg.OrgID) {
+			return constraintError(pgerrcode.ForeignKeyViolation, "user_organisations_org_id_fkey")
+		}
+
+		if slices.ContainsFunc(t.members, func(m db.UserOrganisation) bool {
+			return m.UserID == arg.UserID && m.OrgID == arg.OrgID
+		}) {
+			return constraintError(pgerrcode.UniqueViolation, "user_organisations_pkey")
+		}
+
+		own(t, ownMembers, &t.members)
+		t.members = append(t.members, db.UserOrganisation{UserID: arg.UserID, OrgID: arg.OrgID, Role: arg.Role})
+		return nil
+	})
+}
+
+func (r *MockRepo) UserRemoveOrg(ctx context.Context, arg db.UserRemoveOrgParams) (removed int64, err error) {
+	err = r.write(func(t *tables) error {
+		own(t, ownMembers, &t.members)
+
+		before := len(t.members)
+		t.members = slices.DeleteFunc(t.members, func(m db.UserOrganisation) bool {
+			return m.UserID == arg.UserID && m.OrgID == arg.OrgID
+		})
+
+		removed = int64(before - len(t.members))
+		return nil
+	})
+	return removed, err
+}
+
+func (r *MockRepo) UserSetOrgRole(ctx context.Context, arg db.UserSetOrgRoleParams) (updated int64, err error) {
+	err = r.write(func(t *tables) error {
+		if err := varchar("role", arg.Role, 16); err != nil {
+			return err
+		}
+
+		i := slices.IndexFunc(t.members, func(m db.UserOrganisation) bool {
+			return m.UserID == arg.UserID && m.OrgID == arg.OrgID
+		})
+		if i < 0 {
+			return nil
+		}
+
+		own(t, ownMembers, &t.members)
+		t.members[i].Role = arg.Role
+		updated = 1
+		return nil
+	})
+	return updated, err
+}
+
+func (r *MockRepo) OrgMembersWhereOrg(ctx context.Context, orgID uuid.UUID) (members []db.OrgMembersWhereOrgRow, err error) {
+	err = r.read(func(t *tables) error {
+		for _, member := range t.members {
+			if member.OrgID != orgID {
+				continue
+			}
+
+			if u, err := find(t.users, func(u db.User) bool { return u.ID == member.UserID }); err == nil {
+				members = append(members, db.OrgMembersWhereOrgRow{
+					ID:              u.ID,
+					Email:           u.Email,
+					FirstName:       u.FirstName,
+					LastName:        u.LastName,
+					Password:        u.Password,
+					Phone:           u.Phone,
+					IsSuperadmin:    u.IsSuperadmin,
+					TokenVersion:    u.TokenVersion,
+					Status:          u.Status,
+					StatusReason:    u.StatusReason,
+					StatusChangedAt: u.StatusChangedAt,
+					Role:            member.Role,
+				})
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(members, func(a, b db.OrgMembersWhereOrgRow) int { return strings.Compare(a.Email, b.Email) })
+	return members, err
+}
+
+func (r *MockRepo) OrgMembershipsWhereUser(ctx context.Context, userID uuid.UUID) (memberships []db.OrgMembershipsWhereUserRow, err error) {
+	err = r.read(func(t *tables) error {
+		for _, member := range t.members {
+			if member.UserID != userID {
+				continue
+			}
+
+			if org, err := find(t.orgs, func(o db.Organisation) bool { return o.ID == member.OrgID }); err == nil {
+				memberships = append(memberships, db.OrgMembershipsWhereUserRow{
+					ID:          org.ID,
+					Name:        org.Name,
+					Description: org.Description,
+					Role:        member.Role,
+				})
+			}
+		}
+		return nil
+	})
+
+	slices.SortFunc(memberships, func(a, b db.OrgMembershipsWhereUserRow) int { return strings.Compare(a.Name, b.Name) })
+	return memberships, err
+}
+
+func (r *MockRepo) OrgMemberRole(ctx context.Context, arg db.OrgMemberRoleParams) (role string, err error) {
+	err = r.read(func(t *tables) error {
+		member, err := find(t.members, func(m db.UserOrganisation) bool {
+			return m.UserID == arg.UserID && m.OrgID == arg.OrgID
+		})
+		role = member.Role
+		return err
+	})
+	return role, err
+}
+
+// ------ Webhooks ------ //
+
+func (r *MockRepo) WebhookInsert(ctx context.Context, arg db.WebhookInsertParams) (db.WebhookEndpoint, error) {
+	hook := db.WebhookEndpoint{
+		ID:        uuid.New(),
+		OrgID:     arg.OrgID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+		Events:    slices.Clone(arg.Events),
+		Active:    true,
+		CreatedAt: now(),
 	}
 
-	r.userOrgStore[user_org.UserID.String()] = append(r.userOrgStore[user_org.UserID.String()], user_org)
+	err := r.write(func(t *tables) error {
+		if !t.hasOrg(hook.OrgID) {
+			return constraintError(pgerrcode.ForeignKeyViolation, "webhook_endpoints_org_id_fkey")
+		}
+
+		own(t, ownWebhooks, &t.webhooks)
+		t.webhooks = append(t.webhooks, hook)
+		return nil
+	})
+	if err != nil {
+		return db.WebhookEndpoint{}, err
+	}
+
+	return hook, nil
+}
+
+func (r *MockRepo) WebhookAllWhereOrg(ctx context.Context, orgID uuid.UUID) (hooks []db.WebhookEndpoint, err error) {
+	err = r.read(func(t *tables) error {
+		hooks = filter(t.webhooks, func(h db.WebhookEndpoint) bool { return h.OrgID == orgID })
+		return nil
+	})
+	return hooks, err
+}
+
+func (r *MockRepo) WebhookWhereOrg(ctx context.Context, arg db.WebhookWhereOrgParams) (hook db.WebhookEndpoint, err error) {
+	err = r.read(func(t *tables) error {
+		hook, err = find(t.webhooks, func(h db.WebhookEndpoint) bool { return h.ID == arg.ID && h.OrgID == arg.OrgID })
+		return err
+	})
+	return hook, err
+}
+
+func (r *MockRepo) WebhookAllForEvent(ctx context.Context, arg db.WebhookAllForEventParams) (hooks []db.WebhookEndpoint, err error) {
+	err = r.read(func(t *tables) error {
+		hooks = filter(t.webhooks, func(h db.WebhookEndpoint) bool {
+			return h.OrgID == arg.OrgID && h.Active && slices.Contains(h.Events, arg.EventType)
+		})
+		return nil
+	})
+	return hooks, err
+}
+
+func (r *MockRepo) WebhookDelete(ctx context.Context, arg db.WebhookDeleteParams) (deleted int64, err error) {
+	err = r.write(func(t *tables) error {
+		own(t, ownWebhooks, &t.webhooks)
+
+		before := len(t.webhooks)
+		t.webhooks = slices.DeleteFunc(t.webhooks, func(h db.WebhookEndpoint) bool { return h.ID == arg.ID && h.OrgID == arg.OrgID })
+		deleted = int64(before - len(t.webhooks))
+
+		// on delete cascade
+		if deleted > 0 {
+			own(t, ownDeliveries, &t.deliveries)
+			t.deliveries = slices.DeleteFunc(t.deliveries, func(d db.WebhookDelivery) bool { return d.EndpointID == arg.ID })
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// ------ Deliveries ------ //
+
+func newDelivery(endpointId uuid.UUID, eventId uuid.UUID, eventType string, payload []byte) db.WebhookDelivery {
+	return db.WebhookDelivery{
+		ID:            uuid.New(),
+		EndpointID:    endpointId,
+		EventID:       eventId,
+		EventType:     eventType,
+		Payload:       slices.Clone(payload),
+		Status:        "pending",
+		NextAttemptAt: now(),
+		CreatedAt:     now(),
+	}
+}
+
+func (t *tables) insertDelivery(delivery db.WebhookDelivery) error {
+	if err := varchar("event_type", delivery.EventType, 64); err != nil {
+		return err
+	}
+
+	if !slices.ContainsFunc(t.webhooks, func(h db.WebhookEndpoint) bool { return h.ID == delivery.EndpointID }) {
+		return constraintError(pgerrcode.ForeignKeyViolation, "webhook_deliveries_endpoint_id_fkey")
+	}
+
+	own(t, ownDeliveries, &t.deliveries)
+	t.deliveries = append(t.deliveries, delivery)
 	return nil
 }
 
-func (r *MockRepo) UserInsert(ctx context.Context, arg db.UserInsertParams) (db.User, error) {
-	user := db.User{
-		ID:        uuid.New(),
-		Email:     arg.Email,
-		FirstName: arg.FirstName,
-		LastName:  arg.LastName,
-		Password:  arg.Password,
-		Phone:     arg.Phone,
+func (r *MockRepo) DeliveryInsert(ctx context.Context, arg db.DeliveryInsertParams) (db.WebhookDelivery, error) {
+	delivery := newDelivery(arg.EndpointID, arg.EventID, arg.EventType, arg.Payload)
+
+	if err := r.write(func(t *tables) error { return t.insertDelivery(delivery) }); err != nil {
+		return db.WebhookDelivery{}, err
 	}
+	return delivery, nil
+}
 
-	r.userStore[user.ID.String()] = user
-	return user, nil
+func (r *MockRepo) DeliveryRedeliver(ctx context.Context, arg db.DeliveryRedeliverParams) (delivery db.WebhookDelivery, err error) {
+	id := uuid.New()
+
+	err = r.write(func(t *tables) error {
+		original, err := find(t.deliveries, func(d db.WebhookDelivery) bool { return d.ID == arg.ID && d.EndpointID == arg.EndpointID })
+		if err != nil {
+			return err
+		}
+
+		delivery = newDelivery(original.EndpointID, original.EventID, original.EventType, original.Payload)
+		delivery.ID = id
+		return t.insertDelivery(delivery)
+	})
+	return delivery, err
+}
+
+func (r *MockRepo) DeliveryAllWhereEndpoint(ctx context.Context, arg db.DeliveryAllWhereEndpointParams) (deliveries []db.WebhookDelivery, err error) {
+	err = r.read(func(t *tables) error {
+		// newest first
+		for i := len(t.deliveries) - 1; i >= 0 && len(deliveries) < int(arg.Limit); i-- {
+			if t.deliveries[i].EndpointID == arg.EndpointID {
+				deliveries = append(deliveries, t.deliveries[i])
+			}
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+func (r *MockRepo) DeliveryClaimDue(ctx context.Context, arg db.DeliveryClaimDueParams) (rows []db.DeliveryClaimDueRow, err error) {
+	err = r.write(func(t *tables) error {
+		rows = nil
+
+		var due []int
+		for i, delivery := range t.deliveries {
+			if delivery.Status == "pending" && !delivery.NextAttemptAt.Time.After(time.Now()) {
+				due = append(due, i)
+			}
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		sort.SliceStable(due, func(a, b int) bool {
+			return t.deliveries[due[a]].NextAttemptAt.Time.Before(t.deliveries[due[b]].NextAttemptAt.Time)
+		})
+
+		own(t, ownDeliveries, &t.deliveries)
+		for _, i := range due[:min(len(due), int(arg.BatchSize))] {
+			delivery := &t.deliveries[i]
+			delivery.Attempts++
+			delivery.NextAttemptAt = arg.LeaseUntil
+
+			hook, err := find(t.webhooks, func(h db.WebhookEndpoint) bool { return h.ID == delivery.EndpointID })
+			if err != nil {
+				continue
+			}
+
+			rows = append(rows, db.DeliveryClaimDueRow{
+				ID:             delivery.ID,
+				EndpointID:     delivery.EndpointID,
+				EventID:        delivery.EventID,
+				EventType:      delivery.EventType,
+				Payload:        delivery.Payload,
+				Status:         delivery.Status,
+				Attempts:       delivery.Attempts,
+				NextAttemptAt:  delivery.NextAttemptAt,
+				LastStatusCode: delivery.LastStatusCode,
+				LastError:      delivery.LastError,
+				CreatedAt:      delivery.CreatedAt,
+				DeliveredAt:    delivery.DeliveredAt,
+				Url:            hook.Url,
+				Secret:         hook.Secret,
+			})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (r *MockRepo) DeliveryUpdate(ctx context.Context, arg db.DeliveryUpdateParams) error {
+	return r.write(func(t *tables) error {
+		i := slices.IndexFunc(t.deliveries, func(d db.WebhookDelivery) bool { return d.ID == arg.ID })
+		if i < 0 {
+			return nil
+		}
+
+		own(t, ownDeliveries, &t.deliveries)
+		t.deliveries[i].Status = arg.Status
+		t.deliveries[i].NextAttemptAt = arg.NextAttemptAt
+		t.deliveries[i].LastStatusCode = arg.LastStatusCode
+		t.deliveries[i].LastError = arg.LastError
+		t.deliveries[i].DeliveredAt = arg.DeliveredAt
+		return nil
+	})
+}
+
+// ------ Jobs ------ //
+
+func (r *MockRepo) JobInsert(ctx context.Context, arg db.JobInsertParams) (db.Job, error) {
+	job := db.Job{
+		ID:          uuid.New(),
+		Kind:        arg.Kind,
+		Payload:     slices.Clone(arg.Payload),
+		Status:      "pending",
+		MaxAttempts: arg.MaxAttempts,
+		RunAt:       now(),
+		CreatedAt:   now(),
+	}
+
+	err := r.write(func(t *tables) error {
+		if err := varchar("kind", job.Kind, 64); err != nil {
+			return err
+		}
+
+		own(t, ownJobs, &t.jobs)
+		t.jobs = append(t.jobs, job)
+		return nil
+	})
+	if err != nil {
+		return db.Job{}, err
+	}
+
+	return job, nil
+}
+
+func (r *MockRepo) JobClaim(ctx context.Context, arg db.JobClaimParams) (claimed []db.Job, err error) {
+	err = r.write(func(t *tables) error {
+		claimed = nil
+
+		current := time.Now()
+		var due []int
+		for i, job := range t.jobs {
+			pending := job.Status == "pending" && !job.RunAt.Time.After(current)
+			abandoned := job.Status == "running" && job.LockedUntil.Time.Before(current)
+			if pending || abandoned {
+				due = append(due, i)
+			}
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		sort.SliceStable(due, func(a, b int) bool { return t.jobs[due[a]].RunAt.Time.Before(t.jobs[due[b]].RunAt.Time) })
+
+		own(t, ownJobs, &t.jobs)
+		for _, i := range due[:min(len(due), int(arg.BatchSize))] {
+			t.jobs[i].Status = "running"
+			t.jobs[i].Attempts++
+			t.jobs[i].LockedUntil = arg.LockedUntil
+			claimed = append(claimed, t.jobs[i])
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+func (r *MockRepo) updateJob(id uuid.UUID, update func(job *db.Job)) error {
+	return r.write(func(t *tables) error {
+		i := slices.IndexFunc(t.jobs, func(job db.Job) bool { return job.ID == id })
+		if i < 0 {
+			return nil
+		}
+
+		own(t, ownJobs, &t.jobs)
+		update(&t.jobs[i])
+		return nil
+	})
+}
+
+func (r *MockRepo) JobComplete(ctx context.Context, id uuid.UUID) error {
+	finishedAt := now()
+	return r.updateJob(id, func(job *db.Job) {
+		job.Status = "completed"
+		job.LockedUntil = pgtype.Timestamptz{}
+		job.LastError = pgtype.Text{}
+		job.FinishedAt = finishedAt
+	})
+}
+
+func (r *MockRepo) JobRetry(ctx context.Context, arg db.JobRetryParams) error {
+	return r.updateJob(arg.ID, func(job *db.Job) {
+		job.Status = "pending"
+		job.LockedUntil = pgtype.Timestamptz{}
+		job.RunAt = arg.RunAt
+		job.LastError = arg.LastError
+	})
+}
+
+func (r *MockRepo) JobKill(ctx context.Context, arg db.JobKillParams) error {
+	finishedAt := now()
+	return r.updateJob(arg.ID, func(job *db.Job) {
+		job.Status = "dead"
+		job.LockedUntil = pgtype.Timestamptz{}
+		job.LastError = arg.LastError
+		job.FinishedAt = finishedAt
+	})
+}
+
+func (r *MockRepo) JobPurgeCompleted(ctx context.Context, finishedAt pgtype.Timestamptz) (purged int64, err error) {
+	err = r.write(func(t *tables) error {
+		own(t, ownJobs, &t.jobs)
+
+		before := len(t.jobs)
+		t.jobs = slices.DeleteFunc(t.jobs, func(job db.Job) bool {
+			return job.Status == "completed" && job.FinishedAt.Time.Before(finishedAt.Time)
+		})
+		purged = int64(before - len(t.jobs))
+		return nil
+	})
+	return purged, err
 }
 
-func (r *MockRepo) UserWhereEmail(ctx context.Context, email string) (db.User, error) {
-	for _, user := range r.userStore {
-		if user.Email == email {
-			return user, nil
+// ------ Events ------ //
+
+func (r *MockRepo) OrgEventInsert(ctx context.Context, arg db.OrgEventInsertParams) (db.OrgEvent, error) {
+	event := db.OrgEvent{
+		OrgID:     arg.OrgID,
+		Type:      arg.Type,
+		Data:      slices.Clone(arg.Data),
+		CreatedAt: now(),
+	}
+
+	if r.tx != nil {
+		if err := r.tx.lockEvents(ctx, event.OrgID); err != nil {
+			return db.OrgEvent{}, db.ConvertError(err)
+		}
+	} else {
+		unlock, err := r.db.lockEvents(ctx, event.OrgID)
+		if err != nil {
+			return db.OrgEvent{}, err
+		}
+		defer unlock()
+	}
+
+	err := r.write(func(t *tables) error {
+		if err := varchar("type", event.Type, 64); err != nil {
+			return err
 		}
+
+		if !t.hasOrg(event.OrgID) {
+			return constraintError(pgerrcode.ForeignKeyViolation, "org_events_org_id_fkey")
+		}
+
+		// taken once, a write applied again at commit keeps its id
+		if event.ID == 0 {
+			event.ID = r.db.eventId.Add(1)
+		}
+
+		own(t, ownEvents, &t.events)
+		t.events = append(t.events, event)
+		return nil
+	})
+	if err != nil {
+		return db.OrgEvent{}, err
 	}
 
-	return db.User{}, fmt.Errorf("not found")
+	r.notify(event.OrgID)
+	return event, nil
 }
 
-func (r *MockRepo) UserWhereId(ctx context.Context, id uuid.UUID) (db.User, error) {
-	user, ok := r.userStore[id.String()]
-	if !ok {
-		return db.User{}, fmt.Errorf("not found")
+func (r *MockRepo) OrgEventsAfter(ctx context.Context, arg db.OrgEventsAfterParams) (events []db.OrgEvent, err error) {
+	err = r.read(func(t *tables) error {
+		for _, event := range t.events {
+			if len(events) == int(arg.Limit) {
+				break
+			}
+			if event.OrgID == arg.OrgID && event.ID > arg.ID {
+				events = append(events, event)
+			}
+		}
+		return nil
+	})
+	return events, err
+}
+
+func (r *MockRepo) OrgEventLatestId(ctx context.Context, orgID uuid.UUID) (latest int64, err error) {
+	err = r.read(func(t *tables) error {
+		for _, event := range t.events {
+			if event.OrgID == orgID {
+				latest = max(latest, event.ID)
+			}
+		}
+		return nil
+	})
+	return latest, err
+}
+
+func (r *MockRepo) OrgEventPurge(ctx context.Context, createdAt pgtype.Timestamptz) (purged int64, err error) {
+	err = r.write(func(t *tables) error {
+		own(t, ownEvents, &t.events)
+
+		before := len(t.events)
+		t.events = slices.DeleteFunc(t.events, func(e db.OrgEvent) bool { return e.CreatedAt.Time.Before(createdAt.Time) })
+		purged = int64(before - len(t.events))
+		return nil
+	})
+	return purged, err
+}
+
+// ------ Audit Log ------ //
+
+func (r *MockRepo) AuditInsert(ctx context.Context, arg db.AuditInsertParams) (db.AuditLog, error) {
+	entry := db.AuditLog{
+		ActorID:   arg.ActorID,
+		Action:    arg.Action,
+		TargetID:  arg.TargetID,
+		Data:      slices.Clone(arg.Data),
+		CreatedAt: now(),
 	}
 
-	return user, nil
+	err := r.write(func(t *tables) error {
+		if err := varchar("action", entry.Action, 64); err != nil {
+			return err
+		}
+
+		if entry.ActorID.Valid && !t.hasUser(entry.ActorID.UUID) {
+			return constraintError(pgerrcode.ForeignKeyViolation, "audit_log_actor_id_fkey")
+		}
+
+		// taken once, a write applied again at commit keeps its id
+		if entry.ID == 0 {
+			entry.ID = r.db.auditId.Add(1)
+		}
+
+		own(t, ownAudit, &t.audit)
+		t.audit = append(t.audit, entry)
+		return nil
+	})
+	if err != nil {
+		return db.AuditLog{}, err
+	}
+
+	return entry, nil
+}
+
+func (r *MockRepo) AuditList(ctx context.Context, arg db.AuditListParams) (entries []db.AuditLog, err error) {
+	err = r.read(func(t *tables) error {
+		entries = filter(t.audit, func(e db.AuditLog) bool {
+			return (arg.Before == 0 || e.ID < arg.Before) &&
+				(!arg.TargetID.Valid || e.TargetID == arg.TargetID)
+		})
+		return nil
+	})
+
+	// transactions commit out of id order, so the slice isn't sorted
+	slices.SortFunc(entries, func(a, b db.AuditLog) int { return cmp.Compare(b.ID, a.ID) })
+	return entries[:min(int(arg.Limit), len(entries))], err
+}
+
+// ------ Rate Limits ------ //
+
+func (r *MockRepo) RateLimitTake(ctx context.Context, arg db.RateLimitTakeParams) (row db.RateLimitTakeRow, err error) {
+	updatedAt := now()
+	err = r.write(func(t *tables) error {
+		bucket, ok := t.rateLimits[arg.Key]
+		if !ok {
+			bucket = db.RateLimit{Key: arg.Key, Tokens: arg.Capacity, UpdatedAt: updatedAt}
+		}
+
+		elapsed := updatedAt.Time.Sub(bucket.UpdatedAt.Time).Seconds()
+		tokens := min(arg.Capacity, bucket.Tokens+elapsed*arg.RefillRate)
+
+		bucket.Allowed = tokens >= 1
+		if bucket.Allowed {
+			tokens--
+		}
+		bucket.Tokens = tokens
+		bucket.UpdatedAt = updatedAt
+
+		t.ownRateLimits()
+		t.rateLimits[arg.Key] = bucket
+		row = db.RateLimitTakeRow{Tokens: bucket.Tokens, Allowed: bucket.Allowed}
+		return nil
+	})
+	return row, err
+}
+
+func (r *MockRepo) RateLimitPurge(ctx context.Context, updatedAt pgtype.Timestamptz) (purged int64, err error) {
+	err = r.write(func(t *tables) error {
+		purged = 0
+		t.ownRateLimits()
+		for key, bucket := range t.rateLimits {
+			if bucket.UpdatedAt.Time.Before(updatedAt.Time) {
+				delete(t.rateLimits, key)
+				purged++
+			}
+		}
+		return nil
+	})
+	return purged, err
 }
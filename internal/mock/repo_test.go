@@ -0,0 +1,239 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+func pgCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+func insertUser(t *testing.T, repo db.RepoQuerier, email string) db.User {
+	t.Helper()
+
+	user, err := repo.UserInsert(context.Background(), db.UserInsertParams{Email: email, FirstName: "Test", LastName: "User", Password: "password"})
+	if err != nil {
+		t.Fatalf("error inserting user: %v", err)
+	}
+	return user
+}
+
+func begin(t *testing.T, repo *MockRepo) (pgx.Tx, db.RepoQuerier) {
+	t.Helper()
+
+	tx, err := repo.GetDB().Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx, repo.WithTx(tx)
+}
+
+func TestTransactions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Test rollback discards writes", func(t *testing.T) {
+		repo := NewMockRepo()
+		tx, qTx := begin(t, repo)
+
+		user := insertUser(t, qTx, "rollback@example.com")
+		if _, err := qTx.UserWhereId(ctx, user.ID); err != nil {
+			t.Fatalf("transaction should see its own writes: %v", err)
+		}
+
+		if _, err := repo.UserWhereId(ctx, user.ID); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("uncommitted user should not be visible, got %v", err)
+		}
+
+		if err := tx.Rollback(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := repo.UserWhereId(ctx, user.ID); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("rolled back user should not be visible, got %v", err)
+		}
+
+		if err := tx.Commit(ctx); !errors.Is(err, pgx.ErrTxClosed) {
+			t.Fatalf("expected ErrTxClosed committing an ended transaction, got %v", err)
+		}
+	})
+
+	t.Run("Test transaction sees the data committed when it began", func(t *testing.T) {
+		repo := NewMockRepo()
+		tx, qTx := begin(t, repo)
+		defer tx.Rollback(ctx)
+
+		later := insertUser(t, repo, "later@example.com")
+		if _, err := qTx.UserWhereId(ctx, later.ID); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("transaction should not see later commits, got %v", err)
+		}
+	})
+
+	t.Run("Test concurrent writes are applied again on commit", func(t *testing.T) {
+		repo := NewMockRepo()
+		tx, qTx := begin(t, repo)
+
+		insertUser(t, qTx, "first@example.com")
+		other := insertUser(t, repo, "second@example.com")
+
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, email := range []string{"first@example.com", other.Email} {
+			if _, err := repo.UserWhereEmail(ctx, email); err != nil {
+				t.Errorf("expected %s after commit: %v", email, err)
+			}
+		}
+	})
+
+	t.Run("Test conflicting commit is a serialization failure", func(t *testing.T) {
+		repo := NewMockRepo()
+		tx, qTx := begin(t, repo)
+
+		insertUser(t, qTx, "taken@example.com")
+		insertUser(t, repo, "taken@example.com")
+
+		if err := tx.Commit(ctx); pgCode(err) != pgerrcode.SerializationFailure {
+			t.Fatalf("expected a serialization failure, got %v", err)
+		}
+	})
+
+	t.Run("Test constraint error aborts the transaction", func(t *testing.T) {
+		repo := NewMockRepo()
+		tx, qTx := begin(t, repo)
+
+		err := qTx.UserAddOrg(ctx, db.UserAddOrgParams{UserID: uuid.New(), OrgID: uuid.New(), Role: "member"})
+		if pgCode(err) != pgerrcode.ForeignKeyViolation {
+			t.Fatalf("expected a foreign key violation, got %v", err)
+		}
+
+		if _, err := qTx.UserWhereEmail(ctx, "any@example.com"); pgCode(err) != pgerrcode.InFailedSQLTransaction {
+			t.Fatalf("expected queries to fail in an aborted transaction, got %v", err)
+		}
+
+		if err := tx.Commit(ctx); !errors.Is(err, pgx.ErrTxCommitRollback) {
+			t.Fatalf("expected ErrTxCommitRollback, got %v", err)
+		}
+	})
+
+	t.Run("Test org events are notified on commit", func(t *testing.T) {
+		repo := NewMockRepo()
+		org, err := repo.OrgInsert(ctx, db.OrgInsertParams{Name: "Acme"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var notified []uuid.UUID
+		stop := repo.DB().Listen(func(orgId uuid.UUID) { notified = append(notified, orgId) })
+		defer stop()
+
+		tx, qTx := begin(t, repo)
+		if _, err := qTx.OrgEventInsert(ctx, db.OrgEventInsertParams{OrgID: org.ID, Type: "org.updated", Data: []byte("{}")}); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(notified) != 0 {
+			t.Fatal("event should not be notified before commit")
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(notified) != 1 || notified[0] != org.ID {
+			t.Fatalf("expected one notification for the organisation, got %v", notified)
+		}
+	})
+}
+
+func TestConstraints(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockRepo()
+
+	user := insertUser(t, repo, "unique@example.com")
+
+	if _, err := repo.UserInsert(ctx, db.UserInsertParams{Email: user.Email}); pgCode(err) != pgerrcode.UniqueViolation {
+		t.Errorf("expected a unique violation for a taken email, got %v", err)
+	}
+
+	if _, err := repo.OrgInsert(ctx, db.OrgInsertParams{Name: string(make([]byte, 256))}); pgCode(err) != pgerrcode.StringDataRightTruncationDataException {
+		t.Errorf("expected a name longer than the column to be rejected, got %v", err)
+	}
+
+	if _, err := repo.WebhookInsert(ctx, db.WebhookInsertParams{OrgID: uuid.New()}); pgCode(err) != pgerrcode.ForeignKeyViolation {
+		t.Errorf("expected a foreign key violation for a missing organisation, got %v", err)
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMockRepo()
+
+	org, err := repo.OrgInsert(ctx, db.OrgInsertParams{Name: "Acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			tx, err := repo.GetDB().Begin(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer tx.Rollback(ctx)
+			qTx := repo.WithTx(tx)
+
+			user, err := qTx.UserInsert(ctx, db.UserInsertParams{Email: fmt.Sprintf("user%d@example.com", i)})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if err := qTx.UserAddOrg(ctx, db.UserAddOrgParams{UserID: user.ID, OrgID: org.ID, Role: "member"}); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if err := tx.Commit(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	members := 0
+	for i := range workers {
+		user, err := repo.UserWhereEmail(ctx, fmt.Sprintf("user%d@example.com", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := repo.OrgWhereUser(ctx, db.OrgWhereUserParams{UserID: user.ID, OrgID: org.ID}); err == nil {
+			members++
+		}
+	}
+
+	if members != workers {
+		t.Fatalf("expected %d members, got %d", workers, members)
+	}
+}
@@ -0,0 +1,251 @@
+// Package openapi builds OpenAPI 3.1 documents from the go types
+// requests and responses are decoded from and encoded to, so the
+// document can't drift from what the api actually sends
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	Url string `json:"url"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lowercase http methods to their operation
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationId string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+	Ref         string               `json:"$ref,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	Responses       map[string]Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Operations can be found by method and path
+func (d *Document) Operation(method string, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// Patterns lists every operation as a ServeMux pattern, "METHOD /path"
+func (d *Document) Patterns() []string {
+	var patterns []string
+	for path, item := range d.Paths {
+		for method := range item {
+			patterns = append(patterns, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+const bearerAuth = "bearerAuth"
+
+// Builder collects operations and the schemas they refer to
+type Builder struct {
+	doc     Document
+	schemas *Schemas
+}
+
+func New(info Info) *Builder {
+	schemas := NewSchemas()
+	return &Builder{
+		schemas: schemas,
+		doc: Document{
+			OpenAPI: Version,
+			Info:    info,
+			Paths:   make(map[string]PathItem),
+			Components: Components{
+				Schemas:   schemas.components,
+				Responses: make(map[string]Response),
+				SecuritySchemes: map[string]SecurityScheme{
+					bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				},
+			},
+		},
+	}
+}
+
+func (b *Builder) Schemas() *Schemas {
+	return b.schemas
+}
+
+func (b *Builder) Tag(name string, description string) {
+	b.doc.Tags = append(b.doc.Tags, Tag{name, description})
+}
+
+// ErrorResponse registers a shared response that operations list
+// by status in Op.Errors
+func (b *Builder) ErrorResponse(status int, response Response) {
+	b.doc.Components.Responses[strconv.Itoa(status)] = response
+}
+
+// Op describes an operation. Request and Response are values of the
+// types the body is decoded from and encoded to
+type Op struct {
+	Id          string
+	Summary     string
+	Description string
+	Tag         string
+	// Auth is set for operations that need a bearer token
+	Auth     bool
+	Request  any
+	Status   int
+	Response any
+	// ResponseType overrides the content type of the response
+	ResponseType string
+	Errors       []int
+	// Parameters are the header and query parameters, path parameters
+	// come from the pattern
+	Parameters []Parameter
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Add adds the operation served at pattern, a ServeMux pattern such
+// as "GET /api/organisations/{orgId}". path values become parameters
+func (b *Builder) Add(pattern string, op Op) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		panic(fmt.Sprintf("openapi: pattern %q has no method", pattern))
+	}
+
+	operation := &Operation{
+		OperationId: op.Id,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses:   make(map[string]Response),
+	}
+
+	if op.Tag != "" {
+		operation.Tags = []string{op.Tag}
+	}
+
+	if op.Auth {
+		operation.Security = []map[string][]string{{bearerAuth: {}}}
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   paramSchema(match[1]),
+		})
+	}
+	operation.Parameters = append(operation.Parameters, op.Parameters...)
+
+	if op.Request != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {b.schemas.Ref(op.Request)}},
+		}
+	}
+
+	response := Response{Description: http.StatusText(op.Status)}
+	if op.Response != nil {
+		contentType := op.ResponseType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		response.Content = map[string]MediaType{contentType: {b.schemas.Ref(op.Response)}}
+	}
+	operation.Responses[strconv.Itoa(op.Status)] = response
+
+	for _, status := range op.Errors {
+		ref := strconv.Itoa(status)
+		if _, ok := b.doc.Components.Responses[ref]; !ok {
+			panic(fmt.Sprintf("openapi: operation %s lists error %d with no response", op.Id, status))
+		}
+		operation.Responses[ref] = Response{Ref: "#/components/responses/" + ref}
+	}
+
+	item, ok := b.doc.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		b.doc.Paths[path] = item
+	}
+
+	key := strings.ToLower(method)
+	if _, exists := item[key]; exists {
+		panic(fmt.Sprintf("openapi: %s is added twice", pattern))
+	}
+	item[key] = operation
+}
+
+func (b *Builder) Document() *Document {
+	return &b.doc
+}
+
+// ids in this api are uuids
+func paramSchema(name string) *Schema {
+	if strings.HasSuffix(name, "Id") {
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+	return &Schema{Type: "string"}
+}
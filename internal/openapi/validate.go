@@ -0,0 +1,225 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Match finds the operation serving r and the path it is documented under
+func (d *Document) Match(r *http.Request) (*Operation, string, error) {
+	mux := http.NewServeMux()
+	for _, pattern := range d.Patterns() {
+		mux.HandleFunc(pattern, func(http.ResponseWriter, *http.Request) {})
+	}
+
+	_, pattern := mux.Handler(r)
+	if pattern == "" {
+		return nil, "", fmt.Errorf("%s %s is not in the spec", r.Method, r.URL.Path)
+	}
+
+	method, path, _ := strings.Cut(pattern, " ")
+	return d.Operation(method, path), path, nil
+}
+
+// ValidateRequest checks the json body of a request
+// against the schema of its operation
+func (d *Document) ValidateRequest(r *http.Request, body []byte) error {
+	op, _, err := d.Match(r)
+	if err != nil {
+		return err
+	}
+
+	if op.RequestBody == nil {
+		if len(body) > 0 {
+			return errors.New("operation takes no request body")
+		}
+		return nil
+	}
+
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return errors.New("operation takes no json body")
+	}
+	return d.validateJSON(media.Schema, body)
+}
+
+// ValidateResponse checks that status is documented for the operation
+// serving r and that the body matches the schema documented for it
+func (d *Document) ValidateResponse(r *http.Request, status int, header http.Header, body []byte) error {
+	op, path, err := d.Match(r)
+	if err != nil {
+		return err
+	}
+
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("%s %s does not document status %d", r.Method, path, status)
+	}
+
+	if ref := response.Ref; ref != "" {
+		if response, ok = d.Components.Responses[strings.TrimPrefix(ref, "#/components/responses/")]; !ok {
+			return fmt.Errorf("%s does not resolve", ref)
+		}
+	}
+
+	if len(response.Content) == 0 {
+		return nil
+	}
+
+	contentType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	media, ok := response.Content[contentType]
+	if !ok {
+		return fmt.Errorf("status %d is not documented as %q", status, contentType)
+	}
+
+	// only json bodies are described by schemas
+	if !strings.HasSuffix(contentType, "json") {
+		return nil
+	}
+	return d.validateJSON(media.Schema, body)
+}
+
+func (d *Document) validateJSON(schema *Schema, body []byte) error {
+	var value any
+	dec := json.NewDecoder(strings.NewReader(string(body)))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("body is not json: %w", err)
+	}
+
+	var problems []error
+	d.validate(schema, value, "$", &problems)
+	return errors.Join(problems...)
+}
+
+// validate checks the subset of json schema Schema can express.
+// typed objects may only hold the properties they document
+func (d *Document) validate(schema *Schema, value any, path string, problems *[]error) {
+	fail := func(format string, args ...any) {
+		*problems = append(*problems, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if schema.Ref != "" {
+		resolved, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			fail("%s does not resolve", schema.Ref)
+			return
+		}
+		schema = resolved
+	}
+
+	if !typeMatches(schema.Type, value) {
+		fail("expected %v got %s", schema.Type, jsonType(value))
+		return
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				fail("missing required property %s", name)
+			}
+		}
+
+		for name, property := range value {
+			propertySchema, ok := schema.Properties[name]
+			if !ok {
+				propertySchema = schema.AdditionalProperties
+			}
+			if propertySchema == nil && schema.Type != nil {
+				fail("property %s is not documented", name)
+				continue
+			}
+			if propertySchema != nil {
+				d.validate(propertySchema, property, path+"."+name, problems)
+			}
+		}
+
+	case []any:
+		if schema.Items != nil {
+			for i, item := range value {
+				d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+
+	case string:
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, value) {
+			fail("%q is not one of %v", value, schema.Enum)
+		}
+		if schema.MaxLength != nil && len([]rune(value)) > *schema.MaxLength {
+			fail("longer than %d characters", *schema.MaxLength)
+		}
+		if schema.MinLength != nil && len([]rune(value)) < *schema.MinLength {
+			fail("shorter than %d characters", *schema.MinLength)
+		}
+		if schema.Pattern != "" && !regexp.MustCompile(schema.Pattern).MatchString(value) {
+			fail("%q does not match %s", value, schema.Pattern)
+		}
+		if !formatMatches(schema.Format, value) {
+			fail("%q is not a valid %s", value, schema.Format)
+		}
+	}
+}
+
+func typeMatches(schemaType any, value any) bool {
+	switch schemaType := schemaType.(type) {
+	case nil:
+		return true
+	case string:
+		return schemaType == jsonType(value) || (schemaType == "number" && jsonType(value) == "integer")
+	case []string:
+		for _, t := range schemaType {
+			if typeMatches(t, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonType(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func formatMatches(format string, value string) bool {
+	switch format {
+	case "uuid":
+		_, err := uuid.Parse(value)
+		return err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, value)
+		return err == nil
+	case "email":
+		_, err := mail.ParseAddress(value)
+		return err == nil
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+// Package pgtest gives a test package a postgres database of its own.
+// the first test to ask for it creates a schema in the database at
+// MOCK_PG_URI and applies the embedded migrations to it, Main drops it
+// when the package's tests are done. tests are skipped when there is
+// no database to use
+package pgtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/fixture"
+	"github.com/michaelcosj/hng-task-two/internal/migrate"
+)
+
+// how long to wait for the database before skipping
+const connectTimeout = 5 * time.Second
+
+var (
+	setupOnce sync.Once
+	// set by Main, without it the schema would never be dropped
+	inMain bool
+
+	pool   *pgxpool.Pool
+	schema string
+	// why tests are skipped, or why they fail
+	skipReason string
+	setupErr   error
+)
+
+// Main runs the package's tests and drops the schema they used, call
+// it from TestMain
+//
+//	func TestMain(m *testing.M) { os.Exit(pgtest.Main(m)) }
+func Main(m *testing.M) int {
+	inMain = true
+	code := m.Run()
+
+	if pool != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+		defer cancel()
+
+		if _, err := pool.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			fmt.Fprintf(os.Stderr, "pgtest: error dropping schema %s: %v\n", schema, err)
+		}
+		pool.Close()
+	}
+	return code
+}
+
+// Pool returns a pool for the package's migrated schema, or skips t
+// when no database is available
+func Pool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	if !inMain {
+		t.Fatal("pgtest: call pgtest.Main from the package's TestMain")
+	}
+
+	setupOnce.Do(setup)
+	if skipReason != "" {
+		t.Skip(skipReason)
+	}
+	if setupErr != nil {
+		t.Fatalf("pgtest: %v", setupErr)
+	}
+	return pool
+}
+
+// Repo returns a repo over the package's schema whose changes are
+// rolled back when t ends, see fixture.Isolate
+func Repo(t testing.TB) db.RepoQuerier {
+	t.Helper()
+
+	pool := Pool(t)
+	return fixture.Isolate(t, db.NewRepoQuerier(db.New(pool), pool))
+}
+
+func setup() {
+	uri := databaseURI()
+	if uri == "" {
+		skipReason = "MOCK_PG_URI is not set"
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	config, err := pgxpool.ParseConfig(uri)
+	if err != nil {
+		setupErr = fmt.Errorf("error parsing MOCK_PG_URI: %w", err)
+		return
+	}
+
+	// a database that can't be reached is the same as none
+	admin, err := pgxpool.NewWithConfig(ctx, config.Copy())
+	if err == nil {
+		err = admin.Ping(ctx)
+	}
+	if err != nil {
+		skipReason = fmt.Sprintf("no database at MOCK_PG_URI: %v", err)
+		if admin != nil {
+			admin.Close()
+		}
+		return
+	}
+	defer admin.Close()
+
+	name := "pgtest_" + packageName() + "_" + uuid.NewString()[:8]
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+name); err != nil {
+		setupErr = fmt.Errorf("error creating schema %s: %w", name, err)
+		return
+	}
+
+	// public is kept for the extensions the migrations use
+	config.ConnConfig.RuntimeParams["search_path"] = name + ",public"
+	pool, err = pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		admin.Exec(ctx, "DROP SCHEMA "+name+" CASCADE")
+		setupErr = fmt.Errorf("error connecting to schema %s: %w", name, err)
+		return
+	}
+	schema = name
+
+	migrator, err := migrate.New(pool)
+	if err == nil {
+		err = migrator.Up(ctx)
+	}
+	if err != nil {
+		setupErr = fmt.Errorf("error migrating schema %s: %w", name, err)
+	}
+}
+
+// databaseURI is MOCK_PG_URI from the environment or, for running
+// tests locally, from the .env file at the root of the module
+func databaseURI() string {
+	if uri := os.Getenv("MOCK_PG_URI"); uri != "" {
+		return uri
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			env, err := godotenv.Read(filepath.Join(dir, ".env"))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				fmt.Fprintf(os.Stderr, "pgtest: error reading .env: %v\n", err)
+			}
+			return env["MOCK_PG_URI"]
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// packageName names the schema after the test binary, so one
+// left behind by a crashed run can be traced to its package
+func packageName() string {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".test")
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	// leaves room in the 63 bytes postgres allows for a name
+	return b.String()[:min(b.Len(), 32)]
+}
@@ -0,0 +1,69 @@
+// Package postgres connects to the postgres database the api and
+// the admin command are configured with, so both use it the same way
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/michaelcosj/hng-task-two/internal/config"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/tracing"
+)
+
+// Open connects to postgres, retrying with backoff for
+// cfg.ConnectTimeout while it starts up or can't be reached
+func Open(ctx context.Context, cfg config.DatabaseConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.URI)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing database uri: %w", err)
+	}
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
+
+	poolConfig.MaxConns = int32(cfg.MaxConns)
+	// the pool opens connections as they are needed, without a minimum
+	// the first requests after starting or a quiet spell wait for them
+	poolConfig.MinConns = int32(cfg.MinConns)
+	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	if cfg.StatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	conn, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error initialising database: %w", err)
+	}
+
+	// the pool connects lazily, pinging finds out if postgres is up
+	deadline := time.Now().Add(cfg.ConnectTimeout)
+	for backoff := 250 * time.Millisecond; ; backoff = min(backoff*2, 5*time.Second) {
+		err := conn.Ping(ctx)
+		if err == nil {
+			return conn, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("error connecting to database: %w", err)
+		}
+
+		slog.Warn("database is not reachable, retrying", "error", err, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return nil, fmt.Errorf("error connecting to database: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// NewRepo runs the queries on pool, each limited to cfg.QueryTimeout
+func NewRepo(pool *pgxpool.Pool, cfg config.DatabaseConfig) db.RepoQuerier {
+	queryDB := db.WithQueryTimeout(pool, cfg.QueryTimeout)
+	return db.NewRepoQuerier(db.New(queryDB), queryDB)
+}
@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/logging"
+)
+
+const (
+	HeaderLimit     = "RateLimit-Limit"
+	HeaderRemaining = "RateLimit-Remaining"
+	HeaderReset     = "RateLimit-Reset"
+	HeaderPolicy    = "RateLimit-Policy"
+)
+
+// KeyFunc identifies who a request counts against
+type KeyFunc func(r *http.Request) string
+
+// Middleware limits the requests of each client to a route group. when the
+// store fails requests are let through, an outage of the store shouldn't
+// take the api down with it
+func Middleware(store Store, group string, limit Limit, key KeyFunc) func(http.Handler) http.Handler {
+	policy := fmt.Sprintf("%d;w=%d;burst=%d", limit.Requests, int(limit.Period.Seconds()), limit.Burst)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := store.Take(r.Context(), group+":"+key(r), limit, time.Now())
+			if err != nil {
+				logging.FromContext(r.Context()).Error("error checking rate limit, allowing request", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(HeaderLimit, strconv.Itoa(res.Limit))
+			w.Header().Set(HeaderRemaining, strconv.Itoa(res.Remaining))
+			w.Header().Set(HeaderReset, ceilSeconds(res.Reset))
+			w.Header().Set(HeaderPolicy, policy)
+
+			if !res.Allowed {
+				logging.FromContext(r.Context()).Info("rate limited", "group", group)
+
+				w.Header().Set("Retry-After", ceilSeconds(res.RetryAfter))
+				app.WriteError(w, r, app.ErrRateLimited)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// ClientIP finds the address of the client that sent a request. the
+// forwarding headers are only believed when the request came from one
+// of the trusted proxies, anyone else could set them to dodge the limits
+type ClientIP struct {
+	trusted []netip.Prefix
+}
+
+func NewClientIP(trusted []netip.Prefix) *ClientIP {
+	return &ClientIP{trusted: trusted}
+}
+
+// ParsePrefixes parses a comma separated list of addresses and cidr ranges
+func ParsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func (c *ClientIP) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client address, preferring the Fly-Client-IP header
+// fly.io's proxy sets, then the nearest untrusted X-Forwarded-For hop
+func (c *ClientIP) Resolve(r *http.Request) netip.Addr {
+	remote, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	addr := remote.Addr().Unmap()
+	if !c.isTrusted(addr) {
+		return addr
+	}
+
+	if flyAddr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("Fly-Client-IP"))); err == nil {
+		return flyAddr.Unmap()
+	}
+
+	// each proxy appends the address it got the request from, so walk
+	// back from our side until reaching one we don't trust
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+
+		hop = hop.Unmap()
+		if !c.isTrusted(hop) {
+			return hop
+		}
+	}
+
+	return addr
+}
+
+// Key keys requests by client address. ipv6 clients are usually given a
+// whole /64 so they are limited by network rather than by address
+func (c *ClientIP) Key(r *http.Request) string {
+	addr := c.Resolve(r)
+	if !addr.IsValid() {
+		return "ip:unknown"
+	}
+
+	if addr.Is6() {
+		prefix, _ := addr.Prefix(64)
+		return "ip:" + prefix.String()
+	}
+	return "ip:" + addr.String()
+}
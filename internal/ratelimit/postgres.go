@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// buckets untouched for this long are deleted, by then
+// any limit we configure has refilled them
+const purgeAfter = time.Hour
+
+// PostgresStore keeps buckets in the database so the limits
+// hold across every instance of the api
+type PostgresStore struct {
+	repo db.Querier
+}
+
+func NewPostgresStore(repo db.Querier) *PostgresStore {
+	return &PostgresStore{repo: repo}
+}
+
+// Take refills buckets by the database's clock rather than now, the
+// instances' clocks can disagree and the bucket is shared by all of them
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, _ time.Time) (Result, error) {
+	row, err := s.repo.RateLimitTake(ctx, db.RateLimitTakeParams{
+		Key:        key,
+		Capacity:   float64(limit.Burst),
+		RefillRate: limit.refillRate(),
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("error taking rate limit token: %w", err)
+	}
+
+	return limit.result(row.Allowed, row.Tokens), nil
+}
+
+// Run deletes idle buckets until ctx is cancelled
+func (s *PostgresStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(purgeAfter / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			before := pgtype.Timestamptz{Time: time.Now().Add(-purgeAfter), Valid: true}
+			if _, err := s.repo.RateLimitPurge(ctx, before); err != nil && ctx.Err() == nil {
+				slog.Error("error purging rate limits", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket that holds Burst tokens and refills at
+// Requests per Period. each request takes a token
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// ParseLimit parses a limit such as "10/1m", the burst is
+// the number of requests unless set with "10/1m,20"
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(s, ",")
+
+	requests, period, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%q is not a limit such as 10/1m", s)
+	}
+
+	var limit Limit
+	var err error
+	if limit.Requests, err = strconv.Atoi(strings.TrimSpace(requests)); err != nil {
+		return Limit{}, fmt.Errorf("%q is not a limit such as 10/1m: %w", s, err)
+	}
+
+	if limit.Period, err = time.ParseDuration(strings.TrimSpace(period)); err != nil {
+		return Limit{}, fmt.Errorf("%q is not a limit such as 10/1m: %w", s, err)
+	}
+
+	limit.Burst = limit.Requests
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil {
+			return Limit{}, fmt.Errorf("%q has an invalid burst: %w", s, err)
+		}
+	}
+
+	return limit, limit.Validate()
+}
+
+func (l Limit) Validate() error {
+	if l.Requests < 1 || l.Period <= 0 || l.Burst < 1 {
+		return fmt.Errorf("limit %s must allow at least one request in a positive period", l)
+	}
+	return nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%v,%d", l.Requests, l.Period, l.Burst)
+}
+
+// refillRate is the number of tokens added each second
+func (l Limit) refillRate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result is the state of a bucket after a request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// time until the bucket is full again
+	Reset time.Duration
+	// time until the next request is allowed, zero when allowed
+	RetryAfter time.Duration
+}
+
+// result works out the headers for a bucket left with tokens
+func (l Limit) result(allowed bool, tokens float64) Result {
+	rate := l.refillRate()
+	res := Result{
+		Allowed:   allowed,
+		Limit:     l.Burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((float64(l.Burst) - tokens) / rate),
+	}
+
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// Store keeps the buckets. key identifies the client and the route group
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
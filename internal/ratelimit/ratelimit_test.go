@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Limit
+		wantErr bool
+	}{
+		{input: "10/1m", want: Limit{Requests: 10, Period: time.Minute, Burst: 10}},
+		{input: "5/1s, 20", want: Limit{Requests: 5, Period: time.Second, Burst: 20}},
+		{input: "10", wantErr: true},
+		{input: "0/1m", wantErr: true},
+		{input: "10/forever", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseLimit(test.input)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseLimit(%q): unexpected error %v", test.input, err)
+			continue
+		}
+
+		if !test.wantErr && got != test.want {
+			t.Errorf("ParseLimit(%q): want %v, got %v", test.input, test.want, got)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Period: time.Second, Burst: 2}
+	now := time.Now()
+
+	for i, wantRemaining := range []int{1, 0} {
+		res, _ := store.Take(ctx, "client", limit, now)
+		if !res.Allowed || res.Remaining != wantRemaining {
+			t.Fatalf("request %d: expected allowed with %d remaining, got %+v", i+1, wantRemaining, res)
+		}
+	}
+
+	res, _ := store.Take(ctx, "client", limit, now)
+	if res.Allowed {
+		t.Fatal("expected the empty bucket to deny the request")
+	}
+
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("expected to retry once a token refills in 500ms, got %v", res.RetryAfter)
+	}
+
+	if other, _ := store.Take(ctx, "other client", limit, now); !other.Allowed {
+		t.Error("clients should have buckets of their own")
+	}
+
+	res, _ = store.Take(ctx, "client", limit, now.Add(500*time.Millisecond))
+	if !res.Allowed {
+		t.Error("expected the refilled token to allow the request")
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	return Result{}, errors.New("connection refused")
+}
+
+func TestMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	limit := Limit{Requests: 1, Period: time.Minute, Burst: 1}
+	byPath := func(r *http.Request) string { return r.URL.Path }
+
+	handler := Middleware(NewMemoryStore(), "test", limit, byPath)(ok)
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/a", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected first request allowed, got %d", resp.Code)
+	}
+
+	if resp.Header().Get(HeaderLimit) != "1" || resp.Header().Get(HeaderRemaining) != "0" || resp.Header().Get(HeaderPolicy) != "1;w=60;burst=1" {
+		t.Errorf("unexpected rate limit headers %v", resp.Header())
+	}
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/a", nil))
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected second request limited, got %d", resp.Code)
+	}
+
+	if resp.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected Retry-After 60, got %q", resp.Header().Get("Retry-After"))
+	}
+
+	t.Run("Test store errors let requests through", func(t *testing.T) {
+		handler := Middleware(failingStore{}, "test", limit, byPath)(ok)
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest("GET", "/a", nil))
+		if resp.Code != http.StatusOK {
+			t.Errorf("expected request allowed, got %d", resp.Code)
+		}
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParsePrefixes("10.0.0.0/8, 172.16.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientIP := NewClientIP(trusted)
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "Test untrusted peer can't spoof headers",
+			remote:  "203.0.113.7:4000",
+			headers: map[string]string{"Fly-Client-IP": "198.51.100.1", "X-Forwarded-For": "198.51.100.1"},
+			want:    "ip:203.0.113.7",
+		},
+		{
+			name:    "Test Fly-Client-IP from a trusted proxy",
+			remote:  "10.1.2.3:4000",
+			headers: map[string]string{"Fly-Client-IP": "198.51.100.1"},
+			want:    "ip:198.51.100.1",
+		},
+		{
+			name:    "Test nearest untrusted forwarded hop",
+			remote:  "172.16.0.1:4000",
+			headers: map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 10.9.9.9"},
+			want:    "ip:198.51.100.1",
+		},
+		{
+			name:   "Test ipv6 clients keyed by network",
+			remote: "[2001:db8:1:2:3:4:5:6]:4000",
+			want:   "ip:2001:db8:1:2::/64",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = test.remote
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+
+			if got := clientIP.Key(req); got != test.want {
+				t.Errorf("want %s, got %s", test.want, got)
+			}
+		})
+	}
+}
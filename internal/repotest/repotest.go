@@ -0,0 +1,966 @@
+// Package repotest is a conformance suite for db.RepoQuerier
+// implementations. every backend runs it so they behave the same
+// as postgres for the queries and errors the service relies on
+package repotest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// Run runs the suite against the repo returned by open. the suite
+// makes its own unique rows so the repo doesn't need to be empty
+func Run(t *testing.T, open func(t *testing.T) db.RepoQuerier) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s *suite)
+	}{
+		{"Test users", testUsers},
+		{"Test unique email", testUniqueEmail},
+		{"Test user search", testUserSearch},
+		{"Test user updates", testUserUpdates},
+		{"Test superadmins and tokens", testSuperadmin},
+		{"Test organisation search", testOrgSearch},
+		{"Test column lengths", testColumnLengths},
+		{"Test organisations", testOrganisations},
+		{"Test members", testMembers},
+		{"Test find user in orgs", testFindUserInOrgs},
+		{"Test transactions", testTransactions},
+		{"Test aborted transaction", testAbortedTransaction},
+		{"Test webhooks", testWebhooks},
+		{"Test deliveries", testDeliveries},
+		{"Test jobs", testJobs},
+		{"Test org events", testOrgEvents},
+		{"Test org event commit order", testOrgEventCommitOrder},
+		{"Test audit log", testAuditLog},
+		{"Test rate limits", testRateLimits},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, &suite{t: t, ctx: context.Background(), repo: open(t)})
+		})
+	}
+}
+
+type suite struct {
+	t    *testing.T
+	ctx  context.Context
+	repo db.RepoQuerier
+}
+
+func (s *suite) user() db.User {
+	s.t.Helper()
+
+	user, err := s.repo.UserInsert(s.ctx, db.UserInsertParams{
+		Email:     uuid.NewString() + "@example.com",
+		FirstName: "Test",
+		LastName:  "User",
+		Password:  "password",
+	})
+	if err != nil {
+		s.t.Fatalf("error inserting user: %v", err)
+	}
+	return user
+}
+
+func (s *suite) org() db.Organisation {
+	s.t.Helper()
+
+	org, err := s.repo.OrgInsert(s.ctx, db.OrgInsertParams{Name: "Org " + uuid.NewString()})
+	if err != nil {
+		s.t.Fatalf("error inserting organisation: %v", err)
+	}
+	return org
+}
+
+func (s *suite) member(user db.User, org db.Organisation, role string) {
+	s.t.Helper()
+
+	if err := s.repo.UserAddOrg(s.ctx, db.UserAddOrgParams{UserID: user.ID, OrgID: org.ID, Role: role}); err != nil {
+		s.t.Fatalf("error adding member: %v", err)
+	}
+}
+
+func (s *suite) webhook(org db.Organisation, events ...string) db.WebhookEndpoint {
+	s.t.Helper()
+
+	hook, err := s.repo.WebhookInsert(s.ctx, db.WebhookInsertParams{OrgID: org.ID, Url: "https://example.com/hook", Secret: "secret", Events: events})
+	if err != nil {
+		s.t.Fatalf("error inserting webhook: %v", err)
+	}
+	return hook
+}
+
+func pgCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+func expectCode(t *testing.T, err error, code string) {
+	t.Helper()
+
+	if got := pgCode(err); got != code {
+		t.Fatalf("expected error code %s, got %v", code, err)
+	}
+}
+
+// expectError checks err is the domain error target, which the
+// service matches on instead of driver errors
+func expectError(t *testing.T, err error, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Fatalf("expected %q, got %v", target, err)
+	}
+}
+
+func expectNoRows(t *testing.T, err error) {
+	t.Helper()
+	expectError(t, err, db.ErrNotFound)
+}
+
+func expectJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid json %q: %v", got, err)
+	}
+	json.Unmarshal([]byte(want), &wantValue)
+
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Fatalf("expected json %s, got %s", want, got)
+	}
+}
+
+func timestamp(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t, Valid: true}
+}
+
+func testUsers(t *testing.T, s *suite) {
+	params := db.UserInsertParams{
+		Email:     uuid.NewString() + "@example.com",
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Password:  "hash",
+		Phone:     pgtype.Text{String: "+2348012345678", Valid: true},
+	}
+
+	user, err := s.repo.UserInsert(s.ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := db.User{ID: user.ID, Email: params.Email, FirstName: params.FirstName, LastName: params.LastName, Password: params.Password, Phone: params.Phone, Status: "active"}
+	if user != want || user.ID == uuid.Nil {
+		t.Fatalf("expected %+v, got %+v", want, user)
+	}
+
+	byEmail, err := s.repo.UserWhereEmail(s.ctx, params.Email)
+	if err != nil || byEmail != user {
+		t.Fatalf("expected %+v by email, got %+v, %v", user, byEmail, err)
+	}
+
+	byId, err := s.repo.UserWhereId(s.ctx, user.ID)
+	if err != nil || byId != user {
+		t.Fatalf("expected %+v by id, got %+v, %v", user, byId, err)
+	}
+
+	// a missing phone is null, not an empty string
+	noPhone := s.user()
+	if noPhone.Phone.Valid {
+		t.Fatalf("expected a null phone, got %+v", noPhone.Phone)
+	}
+
+	_, err = s.repo.UserWhereId(s.ctx, uuid.New())
+	expectNoRows(t, err)
+
+	_, err = s.repo.UserWhereEmail(s.ctx, "missing-"+uuid.NewString()+"@example.com")
+	expectNoRows(t, err)
+}
+
+func testUniqueEmail(t *testing.T, s *suite) {
+	user := s.user()
+
+	_, err := s.repo.UserInsert(s.ctx, db.UserInsertParams{Email: user.Email, FirstName: "Other", LastName: "User", Password: "password"})
+	expectError(t, err, db.ErrConflict)
+
+	var pgErr *pgconn.PgError
+	errors.As(err, &pgErr)
+	if pgErr.ConstraintName != "users_email_key" {
+		t.Fatalf("expected the users_email_key constraint, got %q", pgErr.ConstraintName)
+	}
+
+	// emails are compared exactly
+	if _, err := s.repo.UserInsert(s.ctx, db.UserInsertParams{Email: strings.ToUpper(user.Email), FirstName: "Other", LastName: "User", Password: "password"}); err != nil {
+		t.Fatalf("expected an email differing in case to be allowed, got %v", err)
+	}
+}
+
+func testUserSearch(t *testing.T, s *suite) {
+	// a token no other rows have, since the suite can share a database
+	token := strings.ReplaceAll(uuid.NewString(), "-", "")
+	var inserted []db.User
+	for _, name := range []string{"Carol", "alice", "Bob"} {
+		user, err := s.repo.UserInsert(s.ctx, db.UserInsertParams{
+			Email:     strings.ToLower(name) + "-" + token + "@example.com",
+			FirstName: name,
+			LastName:  "Search" + token,
+			Password:  "password",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		inserted = append(inserted, user)
+	}
+
+	search := func(query string, limit int32, offset int32) []string {
+		t.Helper()
+
+		users, err := s.repo.UserSearch(s.ctx, db.UserSearchParams{Query: query, Limit: limit, Offset: offset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, u := range users {
+			names = append(names, u.FirstName)
+		}
+		return names
+	}
+
+	// ordered by email and matched ignoring case
+	if got := search(strings.ToUpper(token), 10, 0); !reflect.DeepEqual(got, []string{"alice", "Bob", "Carol"}) {
+		t.Fatalf("expected every user matching the email, got %v", got)
+	}
+
+	if got := search("bob search"+token, 10, 0); !reflect.DeepEqual(got, []string{"Bob"}) {
+		t.Fatalf("expected the user matching the full name, got %v", got)
+	}
+
+	if got := search(token, 1, 1); !reflect.DeepEqual(got, []string{"Bob"}) {
+		t.Fatalf("expected the second page of one, got %v", got)
+	}
+
+	if got := search("", 1, 0); len(got) != 1 {
+		t.Fatalf("expected an empty query to match any user, got %v", got)
+	}
+}
+
+func testUserUpdates(t *testing.T, s *suite) {
+	user := s.user()
+
+	updated, err := s.repo.UserUpdatePassword(s.ctx, db.UserUpdatePasswordParams{ID: user.ID, Password: "new password"})
+	if err != nil || updated != 1 {
+		t.Fatalf("expected one user updated, got %d, %v", updated, err)
+	}
+
+	reason := pgtype.Text{String: "spam", Valid: true}
+	updated, err = s.repo.UserSetStatus(s.ctx, db.UserSetStatusParams{ID: user.ID, Status: "suspended", StatusReason: reason})
+	if err != nil || updated != 1 {
+		t.Fatalf("expected one user suspended, got %d, %v", updated, err)
+	}
+
+	found, err := s.repo.UserWhereId(s.ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Password != "new password" || found.Status != "suspended" || found.StatusReason != reason || !found.StatusChangedAt.Valid {
+		t.Fatalf("expected the new password and the suspension, got %+v", found)
+	}
+	// one for the password and one for leaving the active status
+	if found.TokenVersion != user.TokenVersion+2 {
+		t.Fatalf("expected a new password and the suspension to revoke tokens, got version %d", found.TokenVersion)
+	}
+
+	if _, err := s.repo.UserSetStatus(s.ctx, db.UserSetStatusParams{ID: user.ID, Status: "active"}); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := s.repo.UserWhereId(s.ctx, user.ID); again.Status != "active" || again.StatusReason.Valid || again.TokenVersion != found.TokenVersion {
+		t.Fatalf("expected the user active without revoking tokens, got %+v", again)
+	}
+
+	if _, err := s.repo.UserSetStatus(s.ctx, db.UserSetStatusParams{ID: user.ID, Status: "banished"}); err == nil {
+		t.Fatal("expected an unknown status to be refused")
+	}
+
+	updated, err = s.repo.UserUpdatePassword(s.ctx, db.UserUpdatePasswordParams{ID: uuid.New(), Password: "password"})
+	if err != nil || updated != 0 {
+		t.Fatalf("expected no missing user updated, got %d, %v", updated, err)
+	}
+}
+
+func testSuperadmin(t *testing.T, s *suite) {
+	user := s.user()
+	if user.IsSuperadmin || user.TokenVersion != 0 {
+		t.Fatalf("expected a new user to be a plain user, got %+v", user)
+	}
+
+	updated, err := s.repo.UserSetSuperadmin(s.ctx, db.UserSetSuperadminParams{ID: user.ID, IsSuperadmin: true})
+	if err != nil || updated != 1 {
+		t.Fatalf("expected one user updated, got %d, %v", updated, err)
+	}
+
+	for range 2 {
+		if updated, err := s.repo.UserRevokeTokens(s.ctx, user.ID); err != nil || updated != 1 {
+			t.Fatalf("expected one user updated, got %d, %v", updated, err)
+		}
+	}
+
+	found, err := s.repo.UserWhereEmail(s.ctx, user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found.IsSuperadmin || found.TokenVersion != 2 {
+		t.Fatalf("expected a superadmin at token version 2, got %+v", found)
+	}
+
+	updated, err = s.repo.UserRevokeTokens(s.ctx, uuid.New())
+	if err != nil || updated != 0 {
+		t.Fatalf("expected no missing user updated, got %d, %v", updated, err)
+	}
+}
+
+func testOrgSearch(t *testing.T, s *suite) {
+	token := strings.ReplaceAll(uuid.NewString(), "-", "")
+	for _, name := range []string{"Zeta", "Alpha", "Beta"} {
+		if _, err := s.repo.OrgInsert(s.ctx, db.OrgInsertParams{Name: name + " " + token}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(query string, limit int32, offset int32) []string {
+		t.Helper()
+
+		orgs, err := s.repo.OrgSearch(s.ctx, db.OrgSearchParams{Query: query, Limit: limit, Offset: offset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, o := range orgs {
+			names = append(names, strings.Fields(o.Name)[0])
+		}
+		return names
+	}
+
+	// ordered by name and matched ignoring case
+	if got := search(strings.ToUpper(token), 10, 0); !reflect.DeepEqual(got, []string{"Alpha", "Beta", "Zeta"}) {
+		t.Fatalf("expected every matching organisation, got %v", got)
+	}
+
+	if got := search(token, 1, 2); !reflect.DeepEqual(got, []string{"Zeta"}) {
+		t.Fatalf("expected the last page of one, got %v", got)
+	}
+}
+
+func testColumnLengths(t *testing.T, s *suite) {
+	_, err := s.repo.UserInsert(s.ctx, db.UserInsertParams{
+		Email:     uuid.NewString() + "@example.com",
+		FirstName: strings.Repeat("a", 256),
+		LastName:  "User",
+		Password:  "password",
+	})
+	expectCode(t, err, pgerrcode.StringDataRightTruncationDataException)
+
+	_, err = s.repo.UserInsert(s.ctx, db.UserInsertParams{
+		Email:     uuid.NewString() + "@example.com",
+		FirstName: "Test",
+		LastName:  "User",
+		Password:  "password",
+		Phone:     pgtype.Text{String: "+23480123456789012", Valid: true},
+	})
+	expectCode(t, err, pgerrcode.StringDataRightTruncationDataException)
+
+	// lengths count characters, not bytes
+	if _, err := s.repo.OrgInsert(s.ctx, db.OrgInsertParams{Name: strings.Repeat("é", 255)}); err != nil {
+		t.Fatalf("expected 255 characters to fit, got %v", err)
+	}
+}
+
+func testOrganisations(t *testing.T, s *suite) {
+	org, err := s.repo.OrgInsert(s.ctx, db.OrgInsertParams{Name: "Acme", Description: pgtype.Text{String: "Rockets", Valid: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.repo.OrganisationWhereId(s.ctx, org.ID)
+	if err != nil || found != org {
+		t.Fatalf("expected %+v, got %+v, %v", org, found, err)
+	}
+
+	updated, err := s.repo.OrgUpdate(s.ctx, db.OrgUpdateParams{ID: org.ID, Name: "Acme Inc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Acme Inc" || updated.Description.Valid {
+		t.Fatalf("expected the name updated and the description cleared, got %+v", updated)
+	}
+
+	_, err = s.repo.OrgUpdate(s.ctx, db.OrgUpdateParams{ID: uuid.New(), Name: "Missing"})
+	expectNoRows(t, err)
+
+	_, err = s.repo.OrganisationWhereId(s.ctx, uuid.New())
+	expectNoRows(t, err)
+}
+
+func testMembers(t *testing.T, s *suite) {
+	user := s.user()
+	org, other := s.org(), s.org()
+	s.member(user, org, "admin")
+
+	found, err := s.repo.OrgWhereUser(s.ctx, db.OrgWhereUserParams{UserID: user.ID, OrgID: org.ID})
+	if err != nil || found != org {
+		t.Fatalf("expected %+v for a member, got %+v, %v", org, found, err)
+	}
+
+	_, err = s.repo.OrgWhereUser(s.ctx, db.OrgWhereUserParams{UserID: user.ID, OrgID: other.ID})
+	expectNoRows(t, err)
+
+	s.member(user, other, "member")
+	orgs, err := s.repo.OrgAllWhereUser(s.ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, o := range orgs {
+		ids = append(ids, o.ID.String())
+	}
+	want := []string{org.ID.String(), other.ID.String()}
+	sort.Strings(ids)
+	sort.Strings(want)
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("expected organisations %v, got %v", want, ids)
+	}
+
+	role, err := s.repo.OrgMemberRole(s.ctx, db.OrgMemberRoleParams{UserID: user.ID, OrgID: org.ID})
+	if err != nil || role != "admin" {
+		t.Fatalf("expected the admin role, got %q, %v", role, err)
+	}
+
+	err = s.repo.UserAddOrg(s.ctx, db.UserAddOrgParams{UserID: user.ID, OrgID: org.ID, Role: "member"})
+	expectError(t, err, db.ErrConflict)
+
+	updated, err := s.repo.UserSetOrgRole(s.ctx, db.UserSetOrgRoleParams{UserID: user.ID, OrgID: other.ID, Role: "admin"})
+	if err != nil || updated != 1 {
+		t.Fatalf("expected one role updated, got %d, %v", updated, err)
+	}
+
+	memberships, err := s.repo.OrgMembershipsWhereUser(s.ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memberships) != 2 || memberships[0].Role != "admin" || memberships[1].Role != "admin" {
+		t.Fatalf("expected two admin memberships, got %+v", memberships)
+	}
+
+	err = s.repo.UserAddOrg(s.ctx, db.UserAddOrgParams{UserID: uuid.New(), OrgID: org.ID, Role: "member"})
+	expectError(t, err, db.ErrForeignKey)
+
+	err = s.repo.UserAddOrg(s.ctx, db.UserAddOrgParams{UserID: user.ID, OrgID: uuid.New(), Role: "member"})
+	expectError(t, err, db.ErrForeignKey)
+
+	removed, err := s.repo.UserRemoveOrg(s.ctx, db.UserRemoveOrgParams{UserID: user.ID, OrgID: other.ID})
+	if err != nil || removed != 1 {
+		t.Fatalf("expected one membership removed, got %d, %v", removed, err)
+	}
+
+	removed, err = s.repo.UserRemoveOrg(s.ctx, db.UserRemoveOrgParams{UserID: user.ID, OrgID: other.ID})
+	if err != nil || removed != 0 {
+		t.Fatalf("expected nothing removed the second time, got %d, %v", removed, err)
+	}
+
+	_, err = s.repo.OrgMemberRole(s.ctx, db.OrgMemberRoleParams{UserID: user.ID, OrgID: other.ID})
+	expectNoRows(t, err)
+
+	colleague := s.user()
+	s.member(colleague, org, "member")
+	members, err := s.repo.OrgMembersWhereOrg(s.ctx, org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{user.Email + " admin", colleague.Email + " member"}
+	sort.Strings(want)
+	got := []string{}
+	for _, m := range members {
+		got = append(got, m.Email+" "+m.Role)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected members %v ordered by email, got %v", want, got)
+	}
+}
+
+func testFindUserInOrgs(t *testing.T, s *suite) {
+	auth, colleague, stranger := s.user(), s.user(), s.user()
+	shared, elsewhere := s.org(), s.org()
+	s.member(auth, shared, "admin")
+	s.member(colleague, shared, "member")
+	s.member(stranger, elsewhere, "admin")
+
+	found, err := s.repo.FindUserInOrgs(s.ctx, db.FindUserInOrgsParams{AuthUser: auth.ID, FindUser: colleague.ID})
+	if err != nil || found != colleague {
+		t.Fatalf("expected to find a colleague, got %+v, %v", found, err)
+	}
+
+	found, err = s.repo.FindUserInOrgs(s.ctx, db.FindUserInOrgsParams{AuthUser: auth.ID, FindUser: auth.ID})
+	if err != nil || found != auth {
+		t.Fatalf("expected users to find themselves, got %+v, %v", found, err)
+	}
+
+	_, err = s.repo.FindUserInOrgs(s.ctx, db.FindUserInOrgsParams{AuthUser: auth.ID, FindUser: stranger.ID})
+	expectNoRows(t, err)
+
+	// users in no organisation can't find anyone, themselves included
+	loner := s.user()
+	_, err = s.repo.FindUserInOrgs(s.ctx, db.FindUserInOrgsParams{AuthUser: loner.ID, FindUser: loner.ID})
+	expectNoRows(t, err)
+}
+
+func testTransactions(t *testing.T, s *suite) {
+	begin := func() (pgx.Tx, db.RepoQuerier) {
+		tx, err := s.repo.GetDB().Begin(s.ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx, s.repo.WithTx(tx)
+	}
+
+	tx, qTx := begin()
+	discarded, err := qTx.OrgInsert(s.ctx, db.OrgInsertParams{Name: "Discarded"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := qTx.OrganisationWhereId(s.ctx, discarded.ID); err != nil {
+		t.Fatalf("transaction should see its own writes, got %v", err)
+	}
+
+	if err := tx.Rollback(s.ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.repo.OrganisationWhereId(s.ctx, discarded.ID)
+	expectNoRows(t, err)
+
+	tx, qTx = begin()
+	kept, err := qTx.OrgInsert(s.ctx, db.OrgInsertParams{Name: "Kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(s.ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.repo.OrganisationWhereId(s.ctx, kept.ID); err != nil {
+		t.Fatalf("committed organisation should be visible, got %v", err)
+	}
+
+	if err := tx.Rollback(s.ctx); !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatalf("expected pgx.ErrTxClosed rolling back a committed transaction, got %v", err)
+	}
+}
+
+func testAbortedTransaction(t *testing.T, s *suite) {
+	user := s.user()
+
+	tx, err := s.repo.GetDB().Begin(s.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(s.ctx)
+	qTx := s.repo.WithTx(tx)
+
+	org, err := qTx.OrgInsert(s.ctx, db.OrgInsertParams{Name: "Aborted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = qTx.UserInsert(s.ctx, db.UserInsertParams{Email: user.Email, FirstName: "Test", LastName: "User", Password: "password"})
+	expectError(t, err, db.ErrConflict)
+
+	_, err = qTx.OrganisationWhereId(s.ctx, org.ID)
+	expectCode(t, err, pgerrcode.InFailedSQLTransaction)
+
+	if err := tx.Commit(s.ctx); !errors.Is(err, pgx.ErrTxCommitRollback) {
+		t.Fatalf("expected pgx.ErrTxCommitRollback, got %v", err)
+	}
+
+	_, err = s.repo.OrganisationWhereId(s.ctx, org.ID)
+	expectNoRows(t, err)
+}
+
+func testWebhooks(t *testing.T, s *suite) {
+	org := s.org()
+	created := s.webhook(org, "member.added", "org.updated")
+	foreign := s.webhook(s.org(), "member.added")
+
+	if !created.Active || !created.CreatedAt.Valid || !reflect.DeepEqual(created.Events, []string{"member.added", "org.updated"}) {
+		t.Fatalf("unexpected webhook %+v", created)
+	}
+
+	found, err := s.repo.WebhookWhereOrg(s.ctx, db.WebhookWhereOrgParams{ID: created.ID, OrgID: org.ID})
+	if err != nil || found.ID != created.ID || !reflect.DeepEqual(found.Events, created.Events) {
+		t.Fatalf("expected %+v, got %+v, %v", created, found, err)
+	}
+
+	_, err = s.repo.WebhookWhereOrg(s.ctx, db.WebhookWhereOrgParams{ID: foreign.ID, OrgID: org.ID})
+	expectNoRows(t, err)
+
+	second := s.webhook(org, "member.removed")
+	all, err := s.repo.WebhookAllWhereOrg(s.ctx, org.ID)
+	if err != nil || len(all) != 2 || all[0].ID != created.ID || all[1].ID != second.ID {
+		t.Fatalf("expected both webhooks oldest first, got %+v, %v", all, err)
+	}
+
+	forEvent, err := s.repo.WebhookAllForEvent(s.ctx, db.WebhookAllForEventParams{OrgID: org.ID, EventType: "org.updated"})
+	if err != nil || len(forEvent) != 1 || forEvent[0].ID != created.ID {
+		t.Fatalf("expected only the webhook subscribed to the event, got %+v, %v", forEvent, err)
+	}
+
+	_, err = s.repo.WebhookInsert(s.ctx, db.WebhookInsertParams{OrgID: uuid.New(), Url: "https://example.com", Secret: "secret", Events: []string{"org.updated"}})
+	expectError(t, err, db.ErrForeignKey)
+
+	delivery, err := s.repo.DeliveryInsert(s.ctx, db.DeliveryInsertParams{EndpointID: created.ID, EventID: uuid.New(), EventType: "org.updated", Payload: []byte(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := s.repo.WebhookDelete(s.ctx, db.WebhookDeleteParams{ID: created.ID, OrgID: org.ID})
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected the webhook deleted, got %d, %v", deleted, err)
+	}
+
+	// deliveries are deleted with their endpoint
+	_, err = s.repo.DeliveryRedeliver(s.ctx, db.DeliveryRedeliverParams{ID: delivery.ID, EndpointID: created.ID})
+	expectNoRows(t, err)
+}
+
+func testDeliveries(t *testing.T, s *suite) {
+	hook := s.webhook(s.org(), "org.updated")
+	eventId := uuid.New()
+
+	delivery, err := s.repo.DeliveryInsert(s.ctx, db.DeliveryInsertParams{EndpointID: hook.ID, EventID: eventId, EventType: "org.updated", Payload: []byte(`{"name":"Acme"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != "pending" || delivery.Attempts != 0 || !delivery.NextAttemptAt.Valid || delivery.DeliveredAt.Valid {
+		t.Fatalf("unexpected new delivery %+v", delivery)
+	}
+	expectJSON(t, delivery.Payload, `{"name":"Acme"}`)
+
+	_, err = s.repo.DeliveryInsert(s.ctx, db.DeliveryInsertParams{EndpointID: uuid.New(), EventID: eventId, EventType: "org.updated", Payload: []byte(`{}`)})
+	expectError(t, err, db.ErrForeignKey)
+
+	lease := time.Now().Add(time.Minute)
+	claimed, err := s.repo.DeliveryClaimDue(s.ctx, db.DeliveryClaimDueParams{LeaseUntil: timestamp(lease), BatchSize: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claim *db.DeliveryClaimDueRow
+	for i := range claimed {
+		if claimed[i].ID == delivery.ID {
+			claim = &claimed[i]
+		}
+	}
+	if claim == nil {
+		t.Fatal("expected the due delivery to be claimed")
+	}
+	if claim.Attempts != 1 || claim.Url != hook.Url || claim.Secret != hook.Secret || claim.NextAttemptAt.Time.Sub(lease).Abs() > time.Millisecond {
+		t.Fatalf("unexpected claim %+v", claim)
+	}
+
+	// leased deliveries aren't claimed again
+	claimed, err = s.repo.DeliveryClaimDue(s.ctx, db.DeliveryClaimDueParams{LeaseUntil: timestamp(lease), BatchSize: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range claimed {
+		if c.ID == delivery.ID {
+			t.Fatal("leased delivery was claimed twice")
+		}
+	}
+
+	deliveredAt := time.Now()
+	err = s.repo.DeliveryUpdate(s.ctx, db.DeliveryUpdateParams{
+		ID:             delivery.ID,
+		Status:         "delivered",
+		NextAttemptAt:  timestamp(deliveredAt),
+		LastStatusCode: pgtype.Int4{Int32: 200, Valid: true},
+		DeliveredAt:    timestamp(deliveredAt),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redelivery, err := s.repo.DeliveryRedeliver(s.ctx, db.DeliveryRedeliverParams{ID: delivery.ID, EndpointID: hook.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redelivery.ID == delivery.ID || redelivery.EventID != eventId || redelivery.Status != "pending" {
+		t.Fatalf("unexpected redelivery %+v", redelivery)
+	}
+
+	deliveries, err := s.repo.DeliveryAllWhereEndpoint(s.ctx, db.DeliveryAllWhereEndpointParams{EndpointID: hook.ID, Limit: 10})
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("expected two deliveries, got %+v, %v", deliveries, err)
+	}
+	if deliveries[0].ID != redelivery.ID || deliveries[1].Status != "delivered" || deliveries[1].LastStatusCode.Int32 != 200 {
+		t.Fatalf("expected the newest delivery first and the update kept, got %+v", deliveries)
+	}
+
+	limited, err := s.repo.DeliveryAllWhereEndpoint(s.ctx, db.DeliveryAllWhereEndpointParams{EndpointID: hook.ID, Limit: 1})
+	if err != nil || len(limited) != 1 {
+		t.Fatalf("expected the limit to apply, got %+v, %v", limited, err)
+	}
+}
+
+func testJobs(t *testing.T, s *suite) {
+	kind := "test." + uuid.NewString()[:8]
+	job, err := s.repo.JobInsert(s.ctx, db.JobInsertParams{Kind: kind, Payload: []byte(`{"n":1}`), MaxAttempts: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "pending" || job.Attempts != 0 || job.MaxAttempts != 3 || !job.RunAt.Valid || job.LockedUntil.Valid {
+		t.Fatalf("unexpected new job %+v", job)
+	}
+
+	claim := func() *db.Job {
+		jobs, err := s.repo.JobClaim(s.ctx, db.JobClaimParams{LockedUntil: timestamp(time.Now().Add(time.Minute)), BatchSize: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range jobs {
+			if jobs[i].ID == job.ID {
+				return &jobs[i]
+			}
+		}
+		return nil
+	}
+
+	claimed := claim()
+	if claimed == nil || claimed.Status != "running" || claimed.Attempts != 1 || !claimed.LockedUntil.Valid {
+		t.Fatalf("expected the job to be claimed, got %+v", claimed)
+	}
+
+	if claim() != nil {
+		t.Fatal("locked job was claimed twice")
+	}
+
+	err = s.repo.JobRetry(s.ctx, db.JobRetryParams{ID: job.ID, RunAt: timestamp(time.Now().Add(-time.Second)), LastError: pgtype.Text{String: "failed", Valid: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claimed = claim()
+	if claimed == nil || claimed.Attempts != 2 || claimed.LastError.String != "failed" {
+		t.Fatalf("expected the retried job to be claimed again, got %+v", claimed)
+	}
+
+	if err := s.repo.JobComplete(s.ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := s.repo.JobPurgeCompleted(s.ctx, timestamp(time.Now().Add(time.Second)))
+	if err != nil || purged < 1 {
+		t.Fatalf("expected the completed job purged, got %d, %v", purged, err)
+	}
+
+	dead, err := s.repo.JobInsert(s.ctx, db.JobInsertParams{Kind: kind, Payload: []byte(`{}`), MaxAttempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repo.JobKill(s.ctx, db.JobKillParams{ID: dead.ID, LastError: pgtype.Text{String: "gave up", Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	job = dead
+	if claim() != nil {
+		t.Fatal("dead job was claimed")
+	}
+
+	_, err = s.repo.JobInsert(s.ctx, db.JobInsertParams{Kind: strings.Repeat("k", 65), Payload: []byte(`{}`), MaxAttempts: 1})
+	expectCode(t, err, pgerrcode.StringDataRightTruncationDataException)
+}
+
+func testOrgEvents(t *testing.T, s *suite) {
+	org := s.org()
+
+	latest, err := s.repo.OrgEventLatestId(s.ctx, org.ID)
+	if err != nil || latest != 0 {
+		t.Fatalf("expected no events, got %d, %v", latest, err)
+	}
+
+	var events []db.OrgEvent
+	for range 3 {
+		event, err := s.repo.OrgEventInsert(s.ctx, db.OrgEventInsertParams{OrgID: org.ID, Type: "org.updated", Data: []byte(`{"a":1}`)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+
+	if events[0].ID >= events[1].ID || events[1].ID >= events[2].ID || !events[0].CreatedAt.Valid {
+		t.Fatalf("expected increasing ids, got %+v", events)
+	}
+	expectJSON(t, events[0].Data, `{"a":1}`)
+
+	latest, err = s.repo.OrgEventLatestId(s.ctx, org.ID)
+	if err != nil || latest != events[2].ID {
+		t.Fatalf("expected latest id %d, got %d, %v", events[2].ID, latest, err)
+	}
+
+	after, err := s.repo.OrgEventsAfter(s.ctx, db.OrgEventsAfterParams{OrgID: org.ID, ID: events[0].ID, Limit: 1})
+	if err != nil || len(after) != 1 || after[0].ID != events[1].ID {
+		t.Fatalf("expected the event after the first, got %+v, %v", after, err)
+	}
+
+	_, err = s.repo.OrgEventInsert(s.ctx, db.OrgEventInsertParams{OrgID: uuid.New(), Type: "org.updated", Data: []byte(`{}`)})
+	expectError(t, err, db.ErrForeignKey)
+
+	purged, err := s.repo.OrgEventPurge(s.ctx, timestamp(time.Now().Add(time.Second)))
+	if err != nil || purged < 3 {
+		t.Fatalf("expected the events purged, got %d, %v", purged, err)
+	}
+}
+
+// an event inserted while another transaction's event for the
+// organisation is uncommitted must wait for it, or a stream could
+// read the later id first and skip the earlier one
+func testOrgEventCommitOrder(t *testing.T, s *suite) {
+	org := s.org()
+	insert := func(q db.Querier) db.OrgEvent {
+		event, err := q.OrgEventInsert(s.ctx, db.OrgEventInsertParams{OrgID: org.ID, Type: "org.updated", Data: []byte(`{}`)})
+		if err != nil {
+			t.Error(err)
+		}
+		return event
+	}
+
+	first, err := s.repo.GetDB().Begin(s.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	earlier := insert(s.repo.WithTx(first))
+
+	inserted := make(chan db.OrgEvent)
+	go func() {
+		inserted <- insert(s.repo)
+	}()
+
+	select {
+	case event := <-inserted:
+		t.Fatalf("expected the insert to wait for the open transaction, got %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := first.Commit(s.ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	later := <-inserted
+	if later.ID <= earlier.ID {
+		t.Fatalf("expected the waiting insert to get a later id, got %d after %d", later.ID, earlier.ID)
+	}
+
+	events, err := s.repo.OrgEventsAfter(s.ctx, db.OrgEventsAfterParams{OrgID: org.ID, Limit: 10})
+	if err != nil || len(events) != 2 || events[0].ID != earlier.ID || events[1].ID != later.ID {
+		t.Fatalf("expected both events in order, got %+v, %v", events, err)
+	}
+}
+
+func testAuditLog(t *testing.T, s *suite) {
+	actor, target := s.user(), s.user()
+
+	var entries []db.AuditLog
+	for _, arg := range []db.AuditInsertParams{
+		{ActorID: uuid.NullUUID{UUID: actor.ID, Valid: true}, Action: "user.disable", TargetID: uuid.NullUUID{UUID: target.ID, Valid: true}, Data: []byte(`{"a":1}`)},
+		{Action: "user.enable", TargetID: uuid.NullUUID{UUID: target.ID, Valid: true}, Data: []byte(`{}`)},
+		{ActorID: uuid.NullUUID{UUID: actor.ID, Valid: true}, Action: "users.search", Data: []byte(`{}`)},
+	} {
+		entry, err := s.repo.AuditInsert(s.ctx, arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if entries[0].ActorID.UUID != actor.ID || entries[1].ActorID.Valid || entries[2].TargetID.Valid || !entries[0].CreatedAt.Valid {
+		t.Fatalf("expected the actors and targets inserted, got %+v", entries)
+	}
+	expectJSON(t, entries[0].Data, `{"a":1}`)
+
+	// newest first
+	list, err := s.repo.AuditList(s.ctx, db.AuditListParams{TargetID: uuid.NullUUID{UUID: target.ID, Valid: true}, Limit: 10})
+	if err != nil || len(list) != 2 || list[0].ID != entries[1].ID || list[1].ID != entries[0].ID {
+		t.Fatalf("expected the entries about the target, got %+v, %v", list, err)
+	}
+
+	list, err = s.repo.AuditList(s.ctx, db.AuditListParams{Before: entries[2].ID, Limit: 1})
+	if err != nil || len(list) != 1 || list[0].ID != entries[1].ID {
+		t.Fatalf("expected the entry before the last, got %+v, %v", list, err)
+	}
+
+	_, err = s.repo.AuditInsert(s.ctx, db.AuditInsertParams{ActorID: uuid.NullUUID{UUID: uuid.New(), Valid: true}, Action: "user.disable", Data: []byte(`{}`)})
+	expectError(t, err, db.ErrForeignKey)
+}
+
+func testRateLimits(t *testing.T, s *suite) {
+	key := "test:" + uuid.NewString()
+	// buckets are refilled by the database's clock, so the rates are
+	// far enough from the test's timing that a slow run can't flip them
+	take := func(key string, capacity, refillRate float64) db.RateLimitTakeRow {
+		row, err := s.repo.RateLimitTake(s.ctx, db.RateLimitTakeParams{Key: key, Capacity: capacity, RefillRate: refillRate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		row.Tokens = math.Floor(row.Tokens)
+		return row
+	}
+
+	// a token every 10s
+	for i, want := range []db.RateLimitTakeRow{
+		{Tokens: 1, Allowed: true},
+		{Tokens: 0, Allowed: true},
+		{Tokens: 0, Allowed: false},
+	} {
+		if got := take(key, 2, 0.1); got != want {
+			t.Fatalf("take %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+
+	// a token every 50ms, the sleep covers several of them
+	refilled := "test:" + uuid.NewString()
+	if got := take(refilled, 1, 20); !got.Allowed {
+		t.Fatalf("expected the first take allowed, got %+v", got)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if got := take(refilled, 1, 20); !got.Allowed {
+		t.Fatalf("expected a refilled token, got %+v", got)
+	}
+
+	purged, err := s.repo.RateLimitPurge(s.ctx, timestamp(time.Now().Add(time.Minute)))
+	if err != nil || purged < 1 {
+		t.Fatalf("expected the buckets purged, got %d, %v", purged, err)
+	}
+
+	if got := take(key, 2, 0.1); got != (db.RateLimitTakeRow{Tokens: 1, Allowed: true}) {
+		t.Fatalf("expected a full bucket after the purge, got %+v", got)
+	}
+}
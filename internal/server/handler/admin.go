@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/service"
+	"github.com/michaelcosj/hng-task-two/internal/validate"
+)
+
+// the admin api routes, they are only reachable by superadmins
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+func (s *Handler) AdminListUsers(w http.ResponseWriter, r *http.Request) error {
+	actorId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	param, err := readSearch(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	data, err := s.service.SearchAccounts(r.Context(), actorId, param)
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: "Users found successfully",
+		Data:    data,
+	})
+
+	return nil
+}
+
+func (s *Handler) AdminListOrganisations(w http.ResponseWriter, r *http.Request) error {
+	actorId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	param, err := readSearch(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	data, err := s.service.SearchOrganisations(r.Context(), actorId, param)
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: "Organisations found successfully",
+		Data:    data,
+	})
+
+	return nil
+}
+
+func (s *Handler) AdminGetOrganisationMembers(w http.ResponseWriter, r *http.Request) error {
+	actorId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	orgId, err := uuid.Parse(r.PathValue("orgId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	data, err := s.service.GetOrganisationMembers(r.Context(), actorId, orgId)
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: "Organisation members found successfully",
+		Data:    data,
+	})
+
+	return nil
+}
+
+func (s *Handler) AdminSuspendUser(w http.ResponseWriter, r *http.Request) error {
+	req, err := decodeAndValidate[SuspendUserRequest](w, r)
+	if err != nil {
+		return err
+	}
+
+	return s.updateAccount(w, r, "User suspended successfully", func(actorId uuid.UUID, userId uuid.UUID) error {
+		return s.service.SuspendAccount(r.Context(), actorId, userId, req.Reason)
+	})
+}
+
+func (s *Handler) AdminReactivateUser(w http.ResponseWriter, r *http.Request) error {
+	return s.updateAccount(w, r, "User reactivated successfully", func(actorId uuid.UUID, userId uuid.UUID) error {
+		return s.service.ReactivateAccount(r.Context(), actorId, userId)
+	})
+}
+
+func (s *Handler) AdminLogoutUser(w http.ResponseWriter, r *http.Request) error {
+	return s.updateAccount(w, r, "User logged out successfully", func(actorId uuid.UUID, userId uuid.UUID) error {
+		return s.service.LogoutUser(r.Context(), actorId, userId)
+	})
+}
+
+// updateAccount runs update on the user in the path and responds with
+// the account as it is afterwards
+func (s *Handler) updateAccount(w http.ResponseWriter, r *http.Request, message string, update func(actorId uuid.UUID, userId uuid.UUID) error) error {
+	actorId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	userId, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	if err := update(actorId, userId); err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	data, err := s.service.GetAccount(r.Context(), userId)
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: message,
+		Data:    data,
+	})
+
+	return nil
+}
+
+func (s *Handler) AdminGetAuditLog(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	v := validate.New()
+	param := service.AuditLogParam{
+		Before: int64(queryInt(v, query, "before", 0, 0, maxInt32)),
+		Limit:  queryInt(v, query, "limit", defaultPageSize, 1, maxPageSize),
+	}
+	if targetId := query.Get("targetId"); targetId != "" {
+		v.String("targetId", targetId).UUID()
+		param.TargetId, _ = uuid.Parse(targetId)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	data, err := s.service.GetAuditLog(r.Context(), param)
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: "Audit log found successfully",
+		Data:    data,
+	})
+
+	return nil
+}
+
+const maxInt32 = 1<<31 - 1
+
+// readSearch reads the search, limit and offset query parameters
+func readSearch(query url.Values) (service.SearchParam, error) {
+	v := validate.New()
+	param := service.SearchParam{
+		Query:  query.Get("search"),
+		Limit:  queryInt(v, query, "limit", defaultPageSize, 1, maxPageSize),
+		Offset: queryInt(v, query, "offset", 0, 0, maxInt32),
+	}
+	v.String("search", param.Query).MaxLength(validate.MaxNameLength)
+
+	return param, v.Err()
+}
+
+// queryInt reads an integer query parameter between min and max,
+// returning def when it isn't set
+func queryInt(v *validate.Validator, query url.Values, name string, def int, min int, max int) int {
+	raw := query.Get(name)
+	if raw == "" {
+		return def
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min || value > max {
+		v.Fail(name, fmt.Sprintf("must be a whole number from %d to %d", min, max))
+		return def
+	}
+	return value
+}
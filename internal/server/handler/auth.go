@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/michaelcosj/hng-task-two/internal/app"
@@ -9,14 +8,9 @@ import (
 )
 
 func (s *Handler) AuthRegister(w http.ResponseWriter, r *http.Request) error {
-	var req RegisterUserRequest
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return app.InvalidJson()
-	}
-
-	if problems := req.Validate(); len(problems) > 0 {
-		return app.NewValidationError(problems)
+	req, err := decodeAndValidate[RegisterUserRequest](w, r)
+	if err != nil {
+		return err
 	}
 
 	data, err := s.service.Register(r.Context(), service.RegisterParams{
@@ -41,14 +35,9 @@ func (s *Handler) AuthRegister(w http.ResponseWriter, r *http.Request) error {
 }
 
 func (s *Handler) AuthLogin(w http.ResponseWriter, r *http.Request) error {
-	var req LoginUserRequest
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return app.InvalidJson()
-	}
-
-	if problems := req.Validate(); len(problems) > 0 {
-		return app.NewValidationError(problems)
+	req, err := decodeAndValidate[LoginUserRequest](w, r)
+	if err != nil {
+		return err
 	}
 
 	data, err := s.service.Login(r.Context(), service.LoginParams{
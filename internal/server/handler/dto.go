@@ -1,11 +1,13 @@
 package handler
 
 import (
-	"net/mail"
+	"github.com/michaelcosj/hng-task-two/internal/validate"
+	"github.com/michaelcosj/hng-task-two/internal/webhook"
 )
 
-// Validation in this application is basic
-// only to conform to the task specifications
+// reasons are stored in a varchar(255) column
+const maxReasonLength = 255
+
 type RegisterUserRequest struct {
 	FirstName string `json:"firstName"`
 	LastName  string `json:"lastName"`
@@ -14,30 +16,12 @@ type RegisterUserRequest struct {
 	Phone     string `json:"phone"`
 }
 
-func (req *RegisterUserRequest) Validate() map[string]string {
-	problems := make(map[string]string)
-
-	if len(req.Email) == 0 {
-		problems["email"] = "email must be provided"
-	}
-
-	if !isValidEmail(req.Email) {
-		problems["email"] = "email is invalid"
-	}
-
-	if len(req.FirstName) == 0 {
-		problems["firstName"] = "first name must be provided"
-	}
-
-	if len(req.LastName) == 0 {
-		problems["lastName"] = "last name must be provided"
-	}
-
-	if len(req.Password) == 0 {
-		problems["password"] = "password must be provided"
-	}
-
-	return problems
+func (req *RegisterUserRequest) Validate(v *validate.Validator) {
+	v.String("firstName", req.FirstName).Required().Name()
+	v.String("lastName", req.LastName).Required().Name()
+	v.String("email", req.Email).Required().AccountEmail()
+	v.String("password", req.Password).Required().Password()
+	v.String("phone", req.Phone).Phone()
 }
 
 type LoginUserRequest struct {
@@ -45,22 +29,27 @@ type LoginUserRequest struct {
 	Password string `json:"password"`
 }
 
-func (req *LoginUserRequest) Validate() map[string]string {
-	problems := make(map[string]string)
+func (req *LoginUserRequest) Validate(v *validate.Validator) {
+	v.String("email", req.Email).Required().Email()
+	v.String("password", req.Password).Required()
+}
 
-	if len(req.Email) == 0 {
-		problems["email"] = "email must be provided"
-	}
+type DeactivateAccountRequest struct {
+	Password string `json:"password"`
+	Reason   string `json:"reason"`
+}
 
-	if !isValidEmail(req.Email) {
-		problems["email"] = "email is invalid"
-	}
+func (req *DeactivateAccountRequest) Validate(v *validate.Validator) {
+	v.String("password", req.Password).Required()
+	v.String("reason", req.Reason).MaxLength(maxReasonLength)
+}
 
-	if len(req.Password) == 0 {
-		problems["password"] = "password must be provided"
-	}
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
 
-	return problems
+func (req *SuspendUserRequest) Validate(v *validate.Validator) {
+	v.String("reason", req.Reason).Required().MaxLength(maxReasonLength)
 }
 
 type CreateOrgRequest struct {
@@ -68,17 +57,38 @@ type CreateOrgRequest struct {
 	Description string `json:"description"`
 }
 
-func (req *CreateOrgRequest) Validate() map[string]string {
-	problems := make(map[string]string)
+func (req *CreateOrgRequest) Validate(v *validate.Validator) {
+	v.String("name", req.Name).Required().Name()
+}
+
+type UpdateOrgRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
 
-	if len(req.Name) == 0 {
-		problems["name"] = "name must be provided"
-	}
+func (req *UpdateOrgRequest) Validate(v *validate.Validator) {
+	v.String("name", req.Name).Required().Name()
+}
+
+type AddUserToOrgRequest struct {
+	UserId string `json:"userId"`
+}
+
+func (req *AddUserToOrgRequest) Validate(v *validate.Validator) {
+	v.String("userId", req.UserId).Required().UUID()
+}
 
-	return problems
+type CreateWebhookRequest struct {
+	Url    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
 }
 
-func isValidEmail(email string) bool {
-	_, err := mail.ParseAddress(email)
-	return err == nil
+func (req *CreateWebhookRequest) Validate(v *validate.Validator) {
+	v.String("url", req.Url).Required().Url("http", "https")
+	v.String("secret", req.Secret).MinLength(16)
+	validate.Slice(v, "events", req.Events).Required().MaxItems(len(webhook.EventTypes))
+	validate.Each(v, "events", req.Events, func(v *validate.Validator, event string) {
+		v.String("", event).Required().OneOf(webhook.EventTypes...)
+	})
 }
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/logging"
+	"github.com/michaelcosj/hng-task-two/internal/service"
+)
+
+// streams send a comment this often so proxies keep the connection
+// open, and check for events in case a notification was missed
+const heartbeatInterval = 15 * time.Second
+
+// EventSubscriber wakes organisation event streams when
+// the organisation may have new events
+type EventSubscriber interface {
+	Subscribe(orgId uuid.UUID) (<-chan struct{}, func())
+}
+
+// StreamOrganisationEvents sends the organisation's events as server-sent
+// events. clients resume from the last event they received with the
+// Last-Event-ID header, otherwise the stream starts from new events.
+// the user is authenticated again whenever the stream wakes, so the
+// stream ends once they are suspended, deactivated or logged out
+func (s *Handler) StreamOrganisationEvents(w http.ResponseWriter, r *http.Request) error {
+	userId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+	tokenVersion := getTokenVersionFromContext(r.Context())
+
+	orgId, err := uuid.Parse(r.PathValue("orgId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	var lastId int64
+	if lastEventId := r.Header.Get("Last-Event-ID"); lastEventId != "" {
+		lastId, err = strconv.ParseInt(lastEventId, 10, 64)
+		if err != nil {
+			return app.InvalidRequestData(fmt.Errorf("error parsing Last-Event-ID: %w", err))
+		}
+	} else {
+		lastId, err = s.service.GetLatestOrganisationEventId(r.Context(), userId, orgId)
+		if err != nil {
+			return app.ApiErrorFrom(err)
+		}
+	}
+
+	// read the backlog before committing to a stream
+	// so errors are still sent as normal responses
+	backlog, err := s.service.GetOrganisationEvents(r.Context(), userId, orgId, lastId)
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	rc := http.NewResponseController(w)
+
+	// the server's write timeout would cut the stream off
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("error clearing write deadline: %w", err)
+	}
+
+	var wake <-chan struct{}
+	if s.events != nil {
+		var unsubscribe func()
+		wake, unsubscribe = s.events.Subscribe(orgId)
+		defer unsubscribe()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+
+	// once the stream has started errors can only end it
+	if lastId, err = writeEvents(w, backlog, lastId); err != nil {
+		return nil
+	}
+	if err := rc.Flush(); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case _, ok := <-wake:
+			if !ok {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		}
+
+		if _, err := s.service.Authenticate(r.Context(), userId, tokenVersion); err != nil {
+			if r.Context().Err() == nil {
+				logging.FromContext(r.Context()).Info("closing organisation event stream", "error", err)
+			}
+			return nil
+		}
+
+		lastId, err = s.sendNewEvents(r.Context(), w, userId, orgId, lastId)
+		if err != nil {
+			if r.Context().Err() == nil {
+				logging.FromContext(r.Context()).Error("error streaming organisation events", "error", err)
+			}
+			return nil
+		}
+
+		if err := rc.Flush(); err != nil {
+			return nil
+		}
+	}
+}
+
+func (s *Handler) sendNewEvents(ctx context.Context, w http.ResponseWriter, userId uuid.UUID, orgId uuid.UUID, lastId int64) (int64, error) {
+	for {
+		events, err := s.service.GetOrganisationEvents(ctx, userId, orgId, lastId)
+		if err != nil {
+			return lastId, err
+		}
+
+		if lastId, err = writeEvents(w, events, lastId); err != nil {
+			return lastId, err
+		}
+
+		if len(events) < service.OrgEventPageSize {
+			return lastId, nil
+		}
+	}
+}
+
+func writeEvents(w http.ResponseWriter, events []service.OrgEventData, lastId int64) (int64, error) {
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return lastId, err
+		}
+
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data); err != nil {
+			return lastId, err
+		}
+
+		lastId = event.Id
+	}
+
+	return lastId, nil
+}
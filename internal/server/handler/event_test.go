@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/mock"
+	"github.com/michaelcosj/hng-task-two/internal/service"
+)
+
+// wakeSubscriber wakes every stream when wake is sent to
+type wakeSubscriber struct {
+	wake chan struct{}
+}
+
+func (s wakeSubscriber) Subscribe(orgId uuid.UUID) (<-chan struct{}, func()) {
+	return s.wake, func() {}
+}
+
+func TestStreamEndsWhenTokenIsRevoked(t *testing.T) {
+	ctx := context.Background()
+	tokens := app.NewTokenIssuer("secret", time.Hour)
+	svc := service.New(mock.NewMockRepo(), tokens)
+
+	auth, err := svc.Register(ctx, service.RegisterParams{Email: "ada@example.com", FirstName: "Ada", LastName: "Lovelace", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgs, err := svc.GetUserOrganisations(ctx, uuid.MustParse(auth.User.Id))
+	if err != nil || len(orgs.Orgs) != 1 {
+		t.Fatalf("expected the default organisation, got %+v, %v", orgs, err)
+	}
+
+	events := wakeSubscriber{wake: make(chan struct{})}
+	h := New(svc, tokens, events)
+	routes := http.NewServeMux()
+	routes.HandleFunc("GET /organisations/{orgId}/events", Handle(h.StreamOrganisationEvents))
+	srv := httptest.NewServer(h.Authenticate(routes))
+	defer srv.Close()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/organisations/"+orgs.Orgs[0].Id+"/events", nil)
+	req.Header.Set("Authorization", "Bearer "+auth.Token)
+	client := &http.Client{Timeout: 5 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body := bufio.NewReader(res.Body)
+	if line, err := body.ReadString('\n'); err != nil || !strings.HasPrefix(line, "retry:") {
+		t.Fatalf("expected the stream to start, got %q, %v", line, err)
+	}
+
+	if err := svc.LogoutUser(ctx, uuid.Nil, uuid.MustParse(auth.User.Id)); err != nil {
+		t.Fatal(err)
+	}
+	events.wake <- struct{}{}
+
+	// the stream is closed rather than left waiting for the next wake
+	if _, err := io.ReadAll(body); err != nil {
+		t.Fatalf("expected the stream to end, got %v", err)
+	}
+}
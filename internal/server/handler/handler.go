@@ -3,47 +3,58 @@ package handler
 import (
 	"context"
 	"encoding/json"
-	"errors"
-	"log"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/logging"
 	"github.com/michaelcosj/hng-task-two/internal/service"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ApiFunc func(w http.ResponseWriter, r *http.Request) error
 
 type Handler struct {
 	service service.Service
+	tokens  *app.TokenIssuer
+	events  EventSubscriber
 }
 
-func New(svc service.Service) *Handler {
-	return &Handler{service: svc}
+// New creates the route handlers. events may be nil in which case
+// event streams only check for new events on their heartbeat
+func New(svc service.Service, tokens *app.TokenIssuer, events EventSubscriber) *Handler {
+	return &Handler{service: svc, tokens: tokens, events: events}
 }
 
 func Handle(handler ApiFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		setRoute(w, r.Pattern)
+
+		if orgId := r.PathValue("orgId"); orgId != "" {
+			logging.AddFields(r.Context(), "org_id", orgId)
+		}
+
 		if err := handler(w, r); err != nil {
-			if apiError, ok := err.(app.ApiError); ok {
-				writeJSON(w, apiError.StatusCode, apiError)
-			} else if apiValidationError, ok := err.(app.ApiValidationError); ok {
-				writeJSON(w, http.StatusUnprocessableEntity, apiValidationError)
-			} else {
-				errResp := map[string]any{
-					"status":     "internal server error",
-					"statusCode": http.StatusInternalServerError,
-					"message":    "something went wrong, please don't fail me",
-				}
-
-				writeJSON(w, http.StatusInternalServerError, errResp)
-			}
-
-			log.Printf("an error occured: %v", err)
+			reportError(w, r, err)
 		}
 	}
 }
 
+// reportError logs err and writes it as the response
+func reportError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := logging.FromContext(r.Context())
+
+	apiError := app.AsApiError(err)
+	if apiError.StatusCode >= http.StatusInternalServerError {
+		logger.Error("an error occured", "error", err)
+		trace.SpanFromContext(r.Context()).RecordError(err)
+	} else {
+		logger.Info("request failed", "code", apiError.Code, "error", err)
+	}
+
+	app.WriteError(w, r, apiError)
+}
+
 type SuccessResponse struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
@@ -57,15 +68,17 @@ func writeJSON(w http.ResponseWriter, statusCode int, data any) error {
 }
 
 func getAuthUserFromContext(ctx context.Context) (uuid.UUID, error) {
-	userIdValue, ok := ctx.Value("userId").(string)
+	a, ok := authFromContext(ctx)
 	if !ok {
-		return uuid.UUID{}, app.ErrAuthenticationFailed
+		return uuid.UUID{}, app.ApiErrorFrom(app.ErrUnauthenticated)
 	}
 
-	userId, err := uuid.Parse(userIdValue)
-	if err != nil {
-		return uuid.UUID{}, app.ApiErrorFrom(errors.Join(err, app.ErrAuthenticationFailed))
-	}
+	return a.userId, nil
+}
 
-	return userId, nil
+// getTokenVersionFromContext returns the version of the
+// token the request was authenticated with
+func getTokenVersionFromContext(ctx context.Context) int32 {
+	a, _ := authFromContext(ctx)
+	return a.tokenVersion
 }
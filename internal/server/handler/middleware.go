@@ -2,12 +2,25 @@ package handler
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/logging"
+	"github.com/michaelcosj/hng-task-two/internal/metrics"
+	"github.com/michaelcosj/hng-task-two/internal/ratelimit"
+	"github.com/michaelcosj/hng-task-two/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func StripSlashes(next http.Handler) http.Handler {
@@ -25,10 +38,13 @@ func StripSlashes(next http.Handler) http.Handler {
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	// pattern of the nested route that handled the request,
+	// the outer mux only knows the prefix it was sent to
+	route string
 }
 
 func NewLoggingResponseWriter(w http.ResponseWriter) *loggingResponseWriter {
-	return &loggingResponseWriter{w, http.StatusOK}
+	return &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
@@ -36,58 +52,226 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying
+// writer to flush and change deadlines for streamed responses
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+// setRoute records the route pattern on the logging writer wrapping w
+func setRoute(w http.ResponseWriter, pattern string) {
+	for {
+		switch rw := w.(type) {
+		case *loggingResponseWriter:
+			rw.route = pattern
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// routeLabel joins the pattern matched by the outer mux, such as "/api/",
+// with the one matched by the nested mux it forwarded to so requests are
+// grouped by route instead of by path, which contains ids
+func routeLabel(outer, inner string) string {
+	outer = stripMethod(outer)
+	if outer == "" {
+		return "unmatched"
+	}
+
+	if inner == "" || !strings.HasSuffix(outer, "/") {
+		return outer
+	}
+
+	return strings.TrimSuffix(outer, "/") + stripMethod(inner)
+}
+
+func stripMethod(pattern string) string {
+	if i := strings.Index(pattern, " "); i >= 0 {
+		return pattern[i+1:]
+	}
+	return pattern
+}
+
+const RequestIdHeader = "X-Request-ID"
+
+// longest client supplied request id that is kept, longer
+// ones are replaced so clients can't bloat the logs
+const maxRequestIdLength = 128
+
+// RequestId gives each request an id, reusing the one set by the client or
+// a proxy in front of us so a request can be followed across services,
+// and puts a logger carrying it in the request context
+func RequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(RequestIdHeader)
+		if !validRequestId(requestId) {
+			requestId = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIdHeader, requestId)
+		ctx := logging.NewContext(r.Context(), slog.Default().With("request_id", requestId))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Logger traces, logs and records metrics for every request. the server
+// span continues the caller's trace when it sends a traceparent header,
+// and the request log has any fields added to the logger along the way
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		if traceId := tracing.TraceId(ctx); traceId != "" {
+			logging.AddFields(ctx, "trace_id", traceId)
+		}
+
+		// the mux sets the matched pattern on the request it is given
+		r = r.WithContext(ctx)
 		lrw := NewLoggingResponseWriter(w)
 		next.ServeHTTP(lrw, r)
-		log.Printf("%d %s %s %v", lrw.statusCode, r.Method, r.URL.Path, time.Since(start))
+
+		duration := time.Since(start)
+		route := routeLabel(r.Pattern, lrw.route)
+		status := strconv.Itoa(lrw.statusCode)
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, route, status).Observe(duration.Seconds())
+
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(lrw.statusCode))
+		if lrw.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(lrw.statusCode))
+		}
+
+		level := slog.LevelInfo
+		if lrw.statusCode >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		logging.FromContext(r.Context()).LogAttrs(r.Context(), level, "request handled",
+			slog.Int("status", lrw.statusCode),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", route),
+			slog.Duration("duration", duration),
+		)
 	})
 }
 
-func Authenticate(next http.Handler) http.Handler {
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenString := r.Header.Get("Authorization")
-
-		if tokenString == "" {
-			errResp := map[string]any{
-				"status":     "Unauthorized",
-				"statusCode": http.StatusUnauthorized,
-				"message":    "Not authorised to access this resource",
-			}
-			writeJSON(w, http.StatusUnauthorized, errResp)
+		tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || tokenString == "" {
+			app.WriteError(w, r, app.ErrUnauthenticated)
 			return
 		}
 
-		tokenString = tokenString[len("Bearer "):]
-		token, err := app.VerifyToken(tokenString)
+		token, err := h.tokens.VerifyToken(tokenString)
 		if err != nil {
-			log.Printf("error validating jwt token: %v", err)
-
-			errResp := map[string]any{
-				"status":     "Invalid jwt token",
-				"statusCode": http.StatusUnauthorized,
-				"message":    "JWT token is invalid or expired",
-			}
-			writeJSON(w, http.StatusUnauthorized, errResp)
+			logging.FromContext(r.Context()).Info("invalid jwt token", "error", err)
+			app.WriteError(w, r, app.ErrInvalidToken)
 			return
 		}
 
 		mapClaims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			errResp := map[string]any{
-				"status":     "Invalid jwt token",
-				"statusCode": http.StatusUnauthorized,
-				"message":    "JWT token is invalid or expired",
-			}
-			writeJSON(w, http.StatusUnauthorized, errResp)
+			app.WriteError(w, r, app.ErrInvalidToken)
 			return
 		}
 
-		userId := mapClaims["id"].(string)
-		ctx := context.WithValue(r.Context(), "userId", userId)
+		userId, err := uuid.Parse(fmt.Sprint(mapClaims["id"]))
+		if err != nil {
+			app.WriteError(w, r, app.ErrInvalidToken)
+			return
+		}
+		logging.AddFields(r.Context(), "user_id", userId.String())
+
+		// tokens issued before versions were added have none, they are at 0
+		version, _ := mapClaims["ver"].(float64)
+		session, err := h.service.Authenticate(r.Context(), userId, int32(version))
+		if err != nil {
+			reportError(w, r, err)
+			return
+		}
+
+		ctx := withAuth(r.Context(), auth{
+			userId:       userId,
+			superadmin:   session.Superadmin,
+			tokenVersion: int32(version),
+		})
 		req := r.WithContext(ctx)
 
 		next.ServeHTTP(w, req)
 	})
 }
+
+type authContextKey struct{}
+
+// auth is the user Authenticate let a request through for
+type auth struct {
+	userId       uuid.UUID
+	superadmin   bool
+	tokenVersion int32
+}
+
+func withAuth(ctx context.Context, a auth) context.Context {
+	return context.WithValue(ctx, authContextKey{}, a)
+}
+
+// authFromContext reports false for requests that weren't authenticated
+func authFromContext(ctx context.Context) (auth, bool) {
+	a, ok := ctx.Value(authContextKey{}).(auth)
+	return a, ok
+}
+
+// RequireSuperadmin only lets superadmins through, it must run
+// after Authenticate
+func RequireSuperadmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a, _ := authFromContext(r.Context()); !a.superadmin {
+			logging.FromContext(r.Context()).Warn("admin api used by a user who isn't a superadmin")
+			app.WriteError(w, r, app.ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UserOrIPKey counts requests against the authenticated user,
+// or the client address when there isn't one
+func UserOrIPKey(clientIP *ratelimit.ClientIP) ratelimit.KeyFunc {
+	return func(r *http.Request) string {
+		if a, ok := authFromContext(r.Context()); ok {
+			return "user:" + a.userId.String()
+		}
+		return clientIP.Key(r)
+	}
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestRouteLabels(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	// the same shape as the routes, a nested mux behind a stripped prefix
+	apiRoutes := http.NewServeMux()
+	apiRoutes.HandleFunc("GET /organisations/{orgId}/users/{userId}", Handle(ok))
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", apiRoutes))
+	mux.HandleFunc("POST /api/auth/login", Handle(ok))
+	handler := RequestId(Logger(StripSlashes(mux)))
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		wantRoute string
+		wantCode  int
+	}{
+		{
+			name:      "Test nested route is labelled by its full pattern",
+			method:    "GET",
+			path:      "/api/organisations/0190a5e4-0b6a-7d48-8a53-2f6b1c3d4e5f/users/0190a5e4-0b6a-7d48-8a53-2f6b1c3d4e60",
+			wantRoute: "/api/organisations/{orgId}/users/{userId}",
+			wantCode:  http.StatusNoContent,
+		},
+		{
+			name:      "Test top level route",
+			method:    "POST",
+			path:      "/api/auth/login/",
+			wantRoute: "/api/auth/login",
+			wantCode:  http.StatusNoContent,
+		},
+		{
+			name:      "Test unknown paths share a label",
+			method:    "GET",
+			path:      "/nothing/here",
+			wantRoute: "unmatched",
+			wantCode:  http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			counter := metrics.HTTPRequests.WithLabelValues(test.method, test.wantRoute, strconv.Itoa(test.wantCode))
+			before := testutil.ToFloat64(counter)
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, httptest.NewRequest(test.method, test.path, nil))
+
+			if resp.Code != test.wantCode {
+				t.Fatalf("expected %d got %d", test.wantCode, resp.Code)
+			}
+
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("expected request counted under route %q, counter moved by %v", test.wantRoute, got)
+			}
+		})
+	}
+}
+
+func TestRequestId(t *testing.T) {
+	handler := RequestId(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	t.Run("Test client request id is kept", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIdHeader, "req-123")
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		if got := resp.Header().Get(RequestIdHeader); got != "req-123" {
+			t.Errorf("expected req-123 got %q", got)
+		}
+	})
+
+	t.Run("Test invalid request id is replaced", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIdHeader, "has spaces\nand newlines")
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		if got := resp.Header().Get(RequestIdHeader); got == "" || got == req.Header.Get(RequestIdHeader) {
+			t.Errorf("expected a generated request id got %q", got)
+		}
+	})
+}
+
+func TestTracePropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	failing := func(w http.ResponseWriter, r *http.Request) error {
+		return app.ApiErrorFrom(app.ErrOrgNotFound)
+	}
+
+	apiRoutes := http.NewServeMux()
+	apiRoutes.HandleFunc("GET /organisations/{orgId}", Handle(failing))
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", apiRoutes))
+	handler := RequestId(Logger(StripSlashes(mux)))
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/api/organisations/0190a5e4-0b6a-7d48-8a53-2f6b1c3d4e5f", nil)
+	req.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	var body app.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.TraceId != traceId {
+		t.Errorf("expected the caller's trace id in the error, got %q", body.TraceId)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span got %d", len(spans))
+	}
+
+	if spans[0].Name != "GET /api/organisations/{orgId}" {
+		t.Errorf("unexpected span name %q", spans[0].Name)
+	}
+
+	if spans[0].SpanContext.TraceID().String() != traceId {
+		t.Errorf("span did not continue the caller's trace")
+	}
+}
+
+func TestRequireSuperadmin(t *testing.T) {
+	handler := RequireSuperadmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name     string
+		auth     *auth
+		wantCode int
+	}{
+		{"Test superadmin is let through", &auth{userId: uuid.New(), superadmin: true}, http.StatusNoContent},
+		{"Test other users are forbidden", &auth{userId: uuid.New()}, http.StatusForbidden},
+		{"Test unauthenticated requests are forbidden", nil, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/users", nil)
+			if test.auth != nil {
+				req = req.WithContext(withAuth(req.Context(), *test.auth))
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != test.wantCode {
+				t.Errorf("expected status %d, got %d", test.wantCode, rec.Code)
+			}
+		})
+	}
+}
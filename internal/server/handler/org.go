@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -62,9 +61,9 @@ func (s *Handler) CreateNewOrganisation(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 
-	var req CreateOrgRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return app.InvalidJson()
+	req, err := decodeAndValidate[CreateOrgRequest](w, r)
+	if err != nil {
+		return err
 	}
 
 	data, err := s.service.CreateOrganisation(r.Context(), userId, service.CreateOrgParam{
@@ -92,26 +91,83 @@ func (s *Handler) AddUserToOrganisation(w http.ResponseWriter, r *http.Request)
 		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
 	}
 
-	var req struct {
-		UserId string `json:"userId"`
+	req, err := decodeAndValidate[AddUserToOrgRequest](w, r)
+	if err != nil {
+		return err
+	}
+
+	// checked by the request's rules
+	userId := uuid.MustParse(req.UserId)
+
+	err = s.service.AddUserToOrganisation(r.Context(), orgId, userId)
+	if err != nil {
+		return app.ApiErrorFrom(err)
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return app.InvalidJson()
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: "User added to organisation successfully",
+	})
+
+	return nil
+}
+
+func (s *Handler) UpdateOrganisation(w http.ResponseWriter, r *http.Request) error {
+	userId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
 	}
 
-	userId, err := uuid.Parse(req.UserId)
+	orgId, err := uuid.Parse(r.PathValue("orgId"))
 	if err != nil {
-		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %v", err))
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
 	}
 
-	err = s.service.AddUserToOrganisation(r.Context(), orgId, userId)
+	req, err := decodeAndValidate[UpdateOrgRequest](w, r)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.service.UpdateOrganisation(r.Context(), userId, orgId, service.UpdateOrgParam{
+		Name:        req.Name,
+		Description: req.Description,
+	})
 	if err != nil {
 		return app.ApiErrorFrom(err)
 	}
 
 	writeJSON(w, http.StatusOK, SuccessResponse{
 		Status:  "success",
-		Message: "User added to organisation successfully",
+		Message: "Organisation updated successfully",
+		Data:    data,
+	})
+
+	return nil
+}
+
+func (s *Handler) RemoveUserFromOrganisation(w http.ResponseWriter, r *http.Request) error {
+	authUserId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	orgId, err := uuid.Parse(r.PathValue("orgId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	userId, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	if err := s.service.RemoveUserFromOrganisation(r.Context(), authUserId, orgId, userId); err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: "User removed from organisation successfully",
 	})
 
 	return nil
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/validate"
+)
+
+// no request body the api accepts comes close to this
+const maxBodySize = 1 << 20
+
+// decodeJSON strictly decodes the request body into dst. the body must
+// be a single json value no larger than maxBodySize holding only fields
+// dst knows about
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		return app.ApiErrorFrom(app.ErrUnsupportedMediaType)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+
+	// anything after the value means the body was not the one value we expect
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return decodeError(err)
+		}
+		return app.InvalidJson("Request body must contain a single json value")
+	}
+
+	return nil
+}
+
+// decodeAndValidate reads the json body into a T and checks it
+// against the rules T declares
+func decodeAndValidate[T any, P interface {
+	*T
+	validate.Validatable
+}](w http.ResponseWriter, r *http.Request) (T, error) {
+	var req T
+	if err := decodeJSON(w, r, &req); err != nil {
+		return req, err
+	}
+
+	if err := validate.Check(P(&req)); err != nil {
+		return req, err
+	}
+
+	return req, nil
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+// decodeError describes why the body could not be decoded in terms
+// of the json the client sent, not the go types it decodes into
+func decodeError(err error) error {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return app.InvalidJson("Request body must not be empty")
+
+	case errors.As(err, &syntaxError):
+		return app.InvalidJson(fmt.Sprintf("Request body has malformed json at position %d", syntaxError.Offset))
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return app.InvalidJson("Request body has malformed json")
+
+	case errors.As(err, &typeError):
+		if typeError.Field == "" {
+			return app.InvalidJson(fmt.Sprintf("Request body must be %s", jsonTypeName(typeError.Type)))
+		}
+		return app.InvalidJson("", app.FieldError{
+			Field:   typeError.Field,
+			Message: fmt.Sprintf("must be %s", jsonTypeName(typeError.Type)),
+		})
+
+	case errors.As(err, &maxBytesError):
+		return app.ApiErrorFrom(fmt.Errorf("request body over %d bytes: %w", maxBytesError.Limit, app.ErrBodyTooLarge))
+	}
+
+	// the decoder has no typed error for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return app.InvalidJson("", app.FieldError{
+			Field:   strings.Trim(field, `"`),
+			Message: "is not a known field",
+		})
+	}
+
+	return app.InvalidJson("")
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	}
+	return "a " + t.String()
+}
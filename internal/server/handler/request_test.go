@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/michaelcosj/hng-task-two/internal/app"
+)
+
+func TestDecodeAndValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    string
+		wantStatus  int
+		wantField   string
+		wantMessage string
+	}{
+		{
+			name:        "Test valid body",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name": "Acme", "description": "Rockets"}`,
+		},
+		{
+			name:        "Test wrong content type",
+			contentType: "text/plain",
+			body:        `{"name": "Acme"}`,
+			wantCode:    "request.unsupported_media_type",
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "Test missing content type",
+			body:       `{"name": "Acme"}`,
+			wantCode:   "request.unsupported_media_type",
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "Test empty body",
+			contentType: "application/json",
+			wantCode:    "request.invalid_json",
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "Test malformed json",
+			contentType: "application/json",
+			body:        `{"name": "Acme",}`,
+			wantCode:    "request.invalid_json",
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "Test unknown field",
+			contentType: "application/json",
+			body:        `{"name": "Acme", "owner": "me"}`,
+			wantCode:    "request.invalid_json",
+			wantStatus:  http.StatusBadRequest,
+			wantField:   "owner",
+			wantMessage: "is not a known field",
+		},
+		{
+			name:        "Test wrong field type",
+			contentType: "application/json",
+			body:        `{"name": 42}`,
+			wantCode:    "request.invalid_json",
+			wantStatus:  http.StatusBadRequest,
+			wantField:   "name",
+			wantMessage: "must be a string",
+		},
+		{
+			name:        "Test trailing values",
+			contentType: "application/json",
+			body:        `{"name": "Acme"} {"name": "Evil"}`,
+			wantCode:    "request.invalid_json",
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "Test body too large",
+			contentType: "application/json",
+			body:        `{"name": "` + strings.Repeat("a", maxBodySize) + `"}`,
+			wantCode:    "request.body_too_large",
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "Test decoded body is validated",
+			contentType: "application/json",
+			body:        `{"description": "Rockets"}`,
+			wantCode:    "request.validation_failed",
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantField:   "name",
+			wantMessage: "is required",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/organisations", strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+
+			got, err := decodeAndValidate[CreateOrgRequest](httptest.NewRecorder(), req)
+			if test.wantCode == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if got.Name != "Acme" || got.Description != "Rockets" {
+					t.Errorf("unexpected request %+v", got)
+				}
+				return
+			}
+
+			var apiError app.ApiError
+			if !errors.As(err, &apiError) {
+				t.Fatalf("expected an api error got %v", err)
+			}
+
+			if apiError.Code != test.wantCode || apiError.StatusCode != test.wantStatus {
+				t.Fatalf("expected %s (%d) got %s (%d)", test.wantCode, test.wantStatus, apiError.Code, apiError.StatusCode)
+			}
+
+			if test.wantField == "" {
+				return
+			}
+
+			if len(apiError.Errors) != 1 || apiError.Errors[0] != (app.FieldError{Field: test.wantField, Message: test.wantMessage}) {
+				t.Errorf("expected %s %s got %v", test.wantField, test.wantMessage, apiError.Errors)
+			}
+		})
+	}
+}
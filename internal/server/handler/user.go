@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/service"
 )
 
 func (s *Handler) GetUser(w http.ResponseWriter, r *http.Request) error {
@@ -33,3 +34,33 @@ func (s *Handler) GetUser(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+// DeactivateAccount closes the account of the user making the request,
+// logging in again within the grace period reopens it
+func (s *Handler) DeactivateAccount(w http.ResponseWriter, r *http.Request) error {
+	userId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	req, err := decodeAndValidate[DeactivateAccountRequest](w, r)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.service.DeactivateAccount(r.Context(), userId, service.DeactivateParam{
+		Password: req.Password,
+		Reason:   req.Reason,
+	})
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: "Account deactivated successfully",
+		Data:    data,
+	})
+
+	return nil
+}
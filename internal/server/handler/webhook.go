@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/service"
+)
+
+func (s *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	userId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	orgId, err := uuid.Parse(r.PathValue("orgId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	req, err := decodeAndValidate[CreateWebhookRequest](w, r)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.service.CreateWebhook(r.Context(), userId, orgId, service.CreateWebhookParam{
+		Url:    req.Url,
+		Secret: req.Secret,
+		Events: req.Events,
+	})
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusCreated, SuccessResponse{
+		Status:  "success",
+		Message: "Webhook created successfully",
+		Data:    data,
+	})
+
+	return nil
+}
+
+func (s *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) error {
+	userId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	orgId, err := uuid.Parse(r.PathValue("orgId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	data, err := s.service.GetWebhooks(r.Context(), userId, orgId)
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: "Webhooks found successfully",
+		Data:    data,
+	})
+
+	return nil
+}
+
+func (s *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	userId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	orgId, err := uuid.Parse(r.PathValue("orgId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	webhookId, err := uuid.Parse(r.PathValue("webhookId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	if err := s.service.DeleteWebhook(r.Context(), userId, orgId, webhookId); err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: "Webhook deleted successfully",
+	})
+
+	return nil
+}
+
+func (s *Handler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	userId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	orgId, err := uuid.Parse(r.PathValue("orgId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	webhookId, err := uuid.Parse(r.PathValue("webhookId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	data, err := s.service.GetWebhookDeliveries(r.Context(), userId, orgId, webhookId)
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusOK, SuccessResponse{
+		Status:  "success",
+		Message: "Webhook deliveries found successfully",
+		Data:    data,
+	})
+
+	return nil
+}
+
+func (s *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) error {
+	userId, err := getAuthUserFromContext(r.Context())
+	if err != nil {
+		return err
+	}
+
+	orgId, err := uuid.Parse(r.PathValue("orgId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	webhookId, err := uuid.Parse(r.PathValue("webhookId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	deliveryId, err := uuid.Parse(r.PathValue("deliveryId"))
+	if err != nil {
+		return app.InvalidRequestData(fmt.Errorf("error parsing uuid: %w", err))
+	}
+
+	data, err := s.service.RedeliverWebhook(r.Context(), userId, orgId, webhookId, deliveryId)
+	if err != nil {
+		return app.ApiErrorFrom(err)
+	}
+
+	writeJSON(w, http.StatusAccepted, SuccessResponse{
+		Status:  "success",
+		Message: "Webhook delivery queued successfully",
+		Data:    data,
+	})
+
+	return nil
+}
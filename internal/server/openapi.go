@@ -0,0 +1,429 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/buildinfo"
+	"github.com/michaelcosj/hng-task-two/internal/openapi"
+	"github.com/michaelcosj/hng-task-two/internal/server/handler"
+	"github.com/michaelcosj/hng-task-two/internal/service"
+	"github.com/michaelcosj/hng-task-two/internal/webhook"
+	"github.com/swaggest/swgui/v5emb"
+)
+
+// Spec describes every route in RegisterRoutes, TestSpecCoversRoutes
+// fails when a route is added without being described here
+var Spec = sync.OnceValue(func() *openapi.Document {
+	b := openapi.New(openapi.Info{
+		Title:       "HNG Stage Two API",
+		Version:     buildinfo.Get().Version,
+		Description: "Users, organisations and their webhooks. Errors are RFC 9457 problem details identified by a stable code.",
+	})
+
+	b.Tag("auth", "Registration and login")
+	b.Tag("users", "User records")
+	b.Tag("organisations", "Organisations and their members")
+	b.Tag("webhooks", "Webhooks and their deliveries")
+	b.Tag("admin", "Platform administration, only superadmins can use it")
+
+	problem := b.Schemas().Ref(app.Problem{})
+	for _, status := range []int{
+		http.StatusBadRequest,
+		http.StatusUnauthorized,
+		http.StatusForbidden,
+		http.StatusNotFound,
+		http.StatusConflict,
+		http.StatusRequestEntityTooLarge,
+		http.StatusUnsupportedMediaType,
+		http.StatusUnprocessableEntity,
+		http.StatusTooManyRequests,
+	} {
+		b.ErrorResponse(status, openapi.Response{
+			Description: http.StatusText(status) + ". " + codesFor(status),
+			Content:     map[string]openapi.MediaType{app.ProblemContentType: {Schema: problem}},
+		})
+	}
+
+	// every route can be rate limited, and suspended users are
+	// refused wherever a token is needed
+	public := []int{http.StatusTooManyRequests}
+	authed := []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests}
+	withBody := []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity}
+
+	for _, prefix := range []string{"/auth", "/api/auth"} {
+		b.Add("POST "+prefix+"/register", openapi.Op{
+			Id:       opId(prefix, "register"),
+			Summary:  "Register a user and their default organisation",
+			Tag:      "auth",
+			Request:  handler.RegisterUserRequest{},
+			Status:   http.StatusCreated,
+			Response: success(b, service.AuthData{}),
+			Errors:   concat(withBody, public),
+		})
+
+		b.Add("POST "+prefix+"/login", openapi.Op{
+			Id:       opId(prefix, "login"),
+			Summary:  "Log in with an email and password",
+			Tag:      "auth",
+			Request:  handler.LoginUserRequest{},
+			Status:   http.StatusOK,
+			Response: success(b, service.AuthData{}),
+			Errors:   concat(withBody, []int{http.StatusUnauthorized, http.StatusForbidden}, public),
+		})
+	}
+
+	b.Add("GET /api/users/{userId}", openapi.Op{
+		Id:          "getUser",
+		Summary:     "Get a user",
+		Description: "Users can get themselves and the members of organisations they belong to.",
+		Tag:         "users",
+		Auth:        true,
+		Status:      http.StatusCreated,
+		Response:    success(b, service.UserData{}),
+		Errors:      concat([]int{http.StatusBadRequest, http.StatusNotFound}, authed),
+	})
+
+	b.Add("POST /api/users/me/deactivate", openapi.Op{
+		Id:          "deactivateAccount",
+		Summary:     "Deactivate the user's own account",
+		Description: "The user's tokens stop working. Logging in before reactivateBefore reopens the account.",
+		Tag:         "users",
+		Auth:        true,
+		Request:     handler.DeactivateAccountRequest{},
+		Status:      http.StatusOK,
+		Response:    success(b, service.DeactivationData{}),
+		Errors:      concat(withBody, authed),
+	})
+
+	b.Add("GET /api/organisations", openapi.Op{
+		Id:       "getOrganisations",
+		Summary:  "List the organisations the user belongs to",
+		Tag:      "organisations",
+		Auth:     true,
+		Status:   http.StatusOK,
+		Response: success(b, service.OrgsData{}),
+		Errors:   authed,
+	})
+
+	b.Add("POST /api/organisations", openapi.Op{
+		Id:       "createOrganisation",
+		Summary:  "Create an organisation owned by the user",
+		Tag:      "organisations",
+		Auth:     true,
+		Request:  handler.CreateOrgRequest{},
+		Status:   http.StatusCreated,
+		Response: success(b, service.OrgData{}),
+		Errors:   concat(withBody, authed),
+	})
+
+	b.Add("GET /api/organisations/{orgId}", openapi.Op{
+		Id:       "getOrganisation",
+		Summary:  "Get an organisation the user belongs to",
+		Tag:      "organisations",
+		Auth:     true,
+		Status:   http.StatusOK,
+		Response: success(b, service.OrgData{}),
+		Errors:   concat([]int{http.StatusBadRequest, http.StatusNotFound}, authed),
+	})
+
+	b.Add("PUT /api/organisations/{orgId}", openapi.Op{
+		Id:       "updateOrganisation",
+		Summary:  "Update an organisation",
+		Tag:      "organisations",
+		Auth:     true,
+		Request:  handler.UpdateOrgRequest{},
+		Status:   http.StatusOK,
+		Response: success(b, service.OrgData{}),
+		Errors:   concat(withBody, []int{http.StatusForbidden, http.StatusNotFound}, authed),
+	})
+
+	b.Add("GET /api/organisations/{orgId}/events", openapi.Op{
+		Id:           "streamOrganisationEvents",
+		Summary:      "Stream the organisation's events",
+		Description:  "A server-sent event stream. Each event's data is an OrgEventData and its id can be sent back as Last-Event-ID to resume.",
+		Tag:          "organisations",
+		Auth:         true,
+		Status:       http.StatusOK,
+		Response:     &openapi.Schema{Type: "string"},
+		ResponseType: "text/event-stream",
+		Parameters: []openapi.Parameter{{
+			Name:        "Last-Event-ID",
+			In:          "header",
+			Description: "Resume after this event instead of starting from new events",
+			Schema:      &openapi.Schema{Type: "string"},
+		}},
+		Errors: concat([]int{http.StatusBadRequest, http.StatusNotFound}, authed),
+	})
+
+	b.Add("POST /api/organisations/{orgId}/users", openapi.Op{
+		Id:       "addOrganisationUser",
+		Summary:  "Add a user to an organisation",
+		Tag:      "organisations",
+		Auth:     true,
+		Request:  handler.AddUserToOrgRequest{},
+		Status:   http.StatusOK,
+		Response: success(b, nil),
+		Errors:   concat(withBody, []int{http.StatusNotFound, http.StatusConflict}, authed),
+	})
+
+	b.Add("DELETE /api/organisations/{orgId}/users/{userId}", openapi.Op{
+		Id:       "removeOrganisationUser",
+		Summary:  "Remove a user from an organisation",
+		Tag:      "organisations",
+		Auth:     true,
+		Status:   http.StatusOK,
+		Response: success(b, nil),
+		Errors:   concat([]int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}, authed),
+	})
+
+	b.Add("POST /api/organisations/{orgId}/webhooks", openapi.Op{
+		Id:          "createWebhook",
+		Summary:     "Create a webhook",
+		Description: "The secret signs every delivery and is only returned here, one is generated when it isn't provided.",
+		Tag:         "webhooks",
+		Auth:        true,
+		Request:     handler.CreateWebhookRequest{},
+		Status:      http.StatusCreated,
+		Response:    success(b, service.WebhookData{}),
+		Errors:      concat(withBody, []int{http.StatusForbidden, http.StatusNotFound}, authed),
+	})
+
+	b.Add("GET /api/organisations/{orgId}/webhooks", openapi.Op{
+		Id:       "getWebhooks",
+		Summary:  "List the organisation's webhooks",
+		Tag:      "webhooks",
+		Auth:     true,
+		Status:   http.StatusOK,
+		Response: success(b, service.WebhooksData{}),
+		Errors:   concat([]int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}, authed),
+	})
+
+	b.Add("DELETE /api/organisations/{orgId}/webhooks/{webhookId}", openapi.Op{
+		Id:       "deleteWebhook",
+		Summary:  "Delete a webhook",
+		Tag:      "webhooks",
+		Auth:     true,
+		Status:   http.StatusOK,
+		Response: success(b, nil),
+		Errors:   concat([]int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}, authed),
+	})
+
+	b.Add("GET /api/organisations/{orgId}/webhooks/{webhookId}/deliveries", openapi.Op{
+		Id:       "getWebhookDeliveries",
+		Summary:  "List a webhook's deliveries",
+		Tag:      "webhooks",
+		Auth:     true,
+		Status:   http.StatusOK,
+		Response: success(b, service.DeliveriesData{}),
+		Errors:   concat([]int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}, authed),
+	})
+
+	b.Add("POST /api/organisations/{orgId}/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", openapi.Op{
+		Id:       "redeliverWebhook",
+		Summary:  "Queue a delivery to be sent again",
+		Tag:      "webhooks",
+		Auth:     true,
+		Status:   http.StatusAccepted,
+		Response: success(b, service.DeliveryData{}),
+		Errors:   concat([]int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}, authed),
+	})
+
+	search := []openapi.Parameter{
+		queryParam("search", "Only include matches containing this", &openapi.Schema{Type: "string"}),
+		queryParam("limit", "The page size, 50 when it isn't set", &openapi.Schema{Type: "integer", Format: "int32"}),
+		queryParam("offset", "The number of matches to skip", &openapi.Schema{Type: "integer", Format: "int32"}),
+	}
+	query := []int{http.StatusUnprocessableEntity}
+
+	b.Add("GET /admin/api/users", openapi.Op{
+		Id:          "adminListUsers",
+		Summary:     "Search users",
+		Description: "Matches the email, first and last name, ordered by email.",
+		Tag:         "admin",
+		Auth:        true,
+		Status:      http.StatusOK,
+		Response:    success(b, service.AccountsData{}),
+		Parameters:  search,
+		Errors:      concat(query, authed),
+	})
+
+	b.Add("POST /admin/api/users/{userId}/suspend", openapi.Op{
+		Id:          "adminSuspendUser",
+		Summary:     "Suspend a user",
+		Description: "The user can't log in and their tokens stop working until they are reactivated.",
+		Tag:         "admin",
+		Auth:        true,
+		Request:     handler.SuspendUserRequest{},
+		Status:      http.StatusOK,
+		Response:    success(b, service.AccountData{}),
+		Errors:      concat(withBody, []int{http.StatusNotFound}, authed),
+	})
+
+	b.Add("POST /admin/api/users/{userId}/reactivate", openapi.Op{
+		Id:          "adminReactivateUser",
+		Summary:     "Reactivate a suspended or deactivated user",
+		Description: "Tokens issued before the user left the active status stay revoked.",
+		Tag:         "admin",
+		Auth:        true,
+		Status:      http.StatusOK,
+		Response:    success(b, service.AccountData{}),
+		Errors:      concat([]int{http.StatusBadRequest, http.StatusNotFound}, authed),
+	})
+
+	b.Add("POST /admin/api/users/{userId}/logout", openapi.Op{
+		Id:       "adminLogoutUser",
+		Summary:  "Revoke every token the user has been issued",
+		Tag:      "admin",
+		Auth:     true,
+		Status:   http.StatusOK,
+		Response: success(b, service.AccountData{}),
+		Errors:   concat([]int{http.StatusBadRequest, http.StatusNotFound}, authed),
+	})
+
+	b.Add("GET /admin/api/organisations", openapi.Op{
+		Id:          "adminListOrganisations",
+		Summary:     "Search organisations",
+		Description: "Matches the name, ordered by name.",
+		Tag:         "admin",
+		Auth:        true,
+		Status:      http.StatusOK,
+		Response:    success(b, service.OrgsData{}),
+		Parameters:  search,
+		Errors:      concat(query, authed),
+	})
+
+	b.Add("GET /admin/api/organisations/{orgId}/users", openapi.Op{
+		Id:       "adminGetOrganisationMembers",
+		Summary:  "List an organisation's members and their roles",
+		Tag:      "admin",
+		Auth:     true,
+		Status:   http.StatusOK,
+		Response: success(b, service.MemberAccountsData{}),
+		Errors:   concat([]int{http.StatusBadRequest, http.StatusNotFound}, authed),
+	})
+
+	b.Add("GET /admin/api/audit", openapi.Op{
+		Id:          "adminGetAuditLog",
+		Summary:     "List the audit log",
+		Description: "Every admin action, newest first. Pass the id of the last entry as before to get the next page.",
+		Tag:         "admin",
+		Auth:        true,
+		Status:      http.StatusOK,
+		Response:    success(b, service.AuditLogData{}),
+		Parameters: []openapi.Parameter{
+			queryParam("before", "Only include entries older than this id", &openapi.Schema{Type: "integer", Format: "int64"}),
+			queryParam("targetId", "Only include entries about this user or organisation", &openapi.Schema{Type: "string", Format: "uuid"}),
+			queryParam("limit", "The page size, 50 when it isn't set", &openapi.Schema{Type: "integer", Format: "int32"}),
+		},
+		Errors: concat(query, authed),
+	})
+
+	describeRequests(b.Schemas())
+	return b.Document()
+})
+
+// describeRequests adds what the validation rules of the request
+// types check, which the go types can't say
+func describeRequests(schemas *openapi.Schemas) {
+	maxName, maxReason, maxPassword, minSecret := 255, 255, 72, 16
+
+	register := schemas.Component("RegisterUserRequest")
+	register.Required = []string{"firstName", "lastName", "email", "password"}
+	register.Properties["firstName"].MaxLength = &maxName
+	register.Properties["lastName"].MaxLength = &maxName
+	register.Properties["email"].Format = "email"
+	register.Properties["email"].MaxLength = &maxName
+	register.Properties["password"].MaxLength = &maxPassword
+	register.Properties["password"].Description = "At most 72 bytes once UTF-8 encoded"
+	register.Properties["phone"].Description = "E.164 phone number"
+	register.Properties["phone"].Pattern = `^\+[1-9][0-9]{1,14}$`
+	register.Properties["phone"].Example = "+2348012345678"
+
+	login := schemas.Component("LoginUserRequest")
+	login.Properties["email"].Format = "email"
+
+	for _, name := range []string{"CreateOrgRequest", "UpdateOrgRequest"} {
+		org := schemas.Component(name)
+		org.Required = []string{"name"}
+		org.Properties["name"].MaxLength = &maxName
+	}
+
+	schemas.Component("AddUserToOrgRequest").Properties["userId"].Format = "uuid"
+
+	deactivate := schemas.Component("DeactivateAccountRequest")
+	deactivate.Required = []string{"password"}
+	deactivate.Properties["reason"].MaxLength = &maxReason
+
+	suspend := schemas.Component("SuspendUserRequest")
+	suspend.Required = []string{"reason"}
+	suspend.Properties["reason"].MaxLength = &maxReason
+
+	schemas.Component("AccountData").Properties["status"].Enum = []string{service.StatusActive, service.StatusSuspended, service.StatusDeactivated}
+
+	hook := schemas.Component("CreateWebhookRequest")
+	hook.Required = []string{"url", "events"}
+	hook.Properties["url"].Format = "uri"
+	hook.Properties["secret"].MinLength = &minSecret
+	hook.Properties["events"].Items.Enum = webhook.EventTypes
+}
+
+// success is the schema of the envelope successful responses are
+// written in, data is null when the response has none
+func success(b *openapi.Builder, data any) *openapi.Schema {
+	schema := &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"status":  {Type: "string", Enum: []string{"success"}},
+			"message": {Type: "string"},
+		},
+		Required: []string{"status", "message"},
+	}
+
+	schema.Properties["data"] = &openapi.Schema{Type: "null"}
+	if data != nil {
+		schema.Properties["data"] = b.Schemas().Ref(data)
+		schema.Required = append(schema.Required, "data")
+	}
+	return schema
+}
+
+// codesFor lists the error codes a status is used for
+func codesFor(status int) string {
+	codes := "Codes:"
+	for _, entry := range app.Catalogue {
+		if entry.Code.StatusCode == status {
+			codes += " " + entry.Code.Code
+		}
+	}
+	return codes
+}
+
+func queryParam(name string, description string, schema *openapi.Schema) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func opId(prefix string, name string) string {
+	if prefix == "/auth" {
+		return name
+	}
+	// the duplicate routes under /api
+	return name + "Api"
+}
+
+func concat(lists ...[]int) []int {
+	var all []int
+	for _, list := range lists {
+		all = append(all, list...)
+	}
+	return all
+}
+
+func serveSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Spec())
+}
+
+var docs = v5emb.New("HNG Stage Two API", "/openapi.json", "/docs/")
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// registeredRoutes reads the patterns RegisterRoutes registers from
+// its source, following the muxes mounted under a prefix
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type registration struct {
+		mux     string
+		pattern string
+		args    []ast.Expr
+	}
+
+	var registrations []registration
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+			return true
+		}
+
+		mux, ok := sel.X.(*ast.Ident)
+		lit, isLit := call.Args[0].(*ast.BasicLit)
+		if !ok || !isLit || lit.Kind != token.STRING {
+			return true
+		}
+
+		pattern, _ := strconv.Unquote(lit.Value)
+		registrations = append(registrations, registration{mux.Name, pattern, call.Args[1:]})
+		return true
+	})
+
+	// a pattern without a method mounts another mux under a prefix
+	prefixes := map[string]string{"mux": ""}
+	for _, reg := range registrations {
+		if strings.Contains(reg.pattern, " ") {
+			continue
+		}
+		ast.Inspect(reg.args[0], func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Ident); ok {
+				prefixes[ident.Name] = strings.TrimSuffix(reg.pattern, "/")
+			}
+			return true
+		})
+	}
+
+	var routes []string
+	for _, reg := range registrations {
+		method, path, ok := strings.Cut(reg.pattern, " ")
+		if !ok {
+			continue
+		}
+
+		prefix, ok := prefixes[reg.mux]
+		if !ok {
+			t.Fatalf("can't tell where %s is mounted", reg.mux)
+		}
+		routes = append(routes, method+" "+prefix+path)
+	}
+
+	slices.Sort(routes)
+	return routes
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	routes := registeredRoutes(t)
+	if len(routes) == 0 {
+		t.Fatal("found no routes in routes.go")
+	}
+
+	described := Spec().Patterns()
+
+	for _, route := range routes {
+		if !slices.Contains(described, route) {
+			t.Errorf("%s is registered but missing from the openapi spec", route)
+		}
+	}
+
+	for _, pattern := range described {
+		if !slices.Contains(routes, pattern) {
+			t.Errorf("%s is in the openapi spec but not registered", pattern)
+		}
+	}
+}
+
+func TestSpecRefsResolve(t *testing.T) {
+	body, err := json.Marshal(Spec())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(map[string]bool)
+	var walk func(value any)
+	walk = func(value any) {
+		switch value := value.(type) {
+		case map[string]any:
+			if ref, ok := value["$ref"].(string); ok && !resolves(doc, ref) {
+				t.Errorf("%s does not resolve", ref)
+			}
+			if id, ok := value["operationId"].(string); ok {
+				if ids[id] {
+					t.Errorf("operationId %s is used twice", id)
+				}
+				ids[id] = true
+			}
+			for _, v := range value {
+				walk(v)
+			}
+		case []any:
+			for _, v := range value {
+				walk(v)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func resolves(doc map[string]any, ref string) bool {
+	var node any = doc
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return false
+		}
+		if node, ok = object[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDocsServed(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /openapi.json", serveSpec)
+	mux.Handle("GET /docs/", docs)
+
+	for _, path := range []string{"/openapi.json", "/docs/"} {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", path, nil))
+		if resp.Code != http.StatusOK {
+			t.Errorf("GET %s: expected 200 got %d", path, resp.Code)
+		}
+	}
+}
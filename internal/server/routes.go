@@ -3,12 +3,32 @@ package server
 import (
 	"net/http"
 
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/ratelimit"
 	"github.com/michaelcosj/hng-task-two/internal/server/handler"
 	"github.com/michaelcosj/hng-task-two/internal/service"
 )
 
-func RegisterRoutes(svc service.Service) http.Handler {
-	h := handler.New(svc)
+// RateLimits are the limits of each route group, requests
+// aren't limited when Store is nil
+type RateLimits struct {
+	Store    ratelimit.Store
+	ClientIP *ratelimit.ClientIP
+	Auth     ratelimit.Limit
+	API      ratelimit.Limit
+}
+
+func RegisterRoutes(svc service.Service, tokens *app.TokenIssuer, events handler.EventSubscriber, limits RateLimits) http.Handler {
+	h := handler.New(svc, tokens, events)
+
+	// auth routes are limited by client since there is no user yet, they
+	// are the expensive ones to hammer with a password hash each
+	limitAuth := func(next http.Handler) http.Handler { return next }
+	limitAPI := limitAuth
+	if limits.Store != nil {
+		limitAuth = ratelimit.Middleware(limits.Store, "auth", limits.Auth, limits.ClientIP.Key)
+		limitAPI = ratelimit.Middleware(limits.Store, "api", limits.API, handler.UserOrIPKey(limits.ClientIP))
+	}
 
 	mux := http.NewServeMux()
 	// ------ Auth Routes ------ //
@@ -19,17 +39,39 @@ func RegisterRoutes(svc service.Service) http.Handler {
 	// ------ API Routes ------ //
 	apiRoutes := http.NewServeMux()
 	apiRoutes.HandleFunc("GET /users/{userId}", handler.Handle(h.GetUser))
+	apiRoutes.HandleFunc("POST /users/me/deactivate", handler.Handle(h.DeactivateAccount))
 	apiRoutes.HandleFunc("GET /organisations", handler.Handle(h.GetUserOrganisations))
 	apiRoutes.HandleFunc("POST /organisations", handler.Handle(h.CreateNewOrganisation))
 	apiRoutes.HandleFunc("GET /organisations/{orgId}", handler.Handle(h.GetSingleOrganisation))
+	apiRoutes.HandleFunc("PUT /organisations/{orgId}", handler.Handle(h.UpdateOrganisation))
+	apiRoutes.HandleFunc("GET /organisations/{orgId}/events", handler.Handle(h.StreamOrganisationEvents))
 	apiRoutes.HandleFunc("POST /organisations/{orgId}/users", handler.Handle(h.AddUserToOrganisation))
+	apiRoutes.HandleFunc("DELETE /organisations/{orgId}/users/{userId}", handler.Handle(h.RemoveUserFromOrganisation))
+
+	// ------ Webhook Routes ------ //
+	apiRoutes.HandleFunc("POST /organisations/{orgId}/webhooks", handler.Handle(h.CreateWebhook))
+	apiRoutes.HandleFunc("GET /organisations/{orgId}/webhooks", handler.Handle(h.GetWebhooks))
+	apiRoutes.HandleFunc("DELETE /organisations/{orgId}/webhooks/{webhookId}", handler.Handle(h.DeleteWebhook))
+	apiRoutes.HandleFunc("GET /organisations/{orgId}/webhooks/{webhookId}/deliveries", handler.Handle(h.GetWebhookDeliveries))
+	apiRoutes.HandleFunc("POST /organisations/{orgId}/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", handler.Handle(h.RedeliverWebhook))
+
+	// ------ Admin Routes ------ //
+	adminRoutes := http.NewServeMux()
+	adminRoutes.HandleFunc("GET /users", handler.Handle(h.AdminListUsers))
+	adminRoutes.HandleFunc("POST /users/{userId}/suspend", handler.Handle(h.AdminSuspendUser))
+	adminRoutes.HandleFunc("POST /users/{userId}/reactivate", handler.Handle(h.AdminReactivateUser))
+	adminRoutes.HandleFunc("POST /users/{userId}/logout", handler.Handle(h.AdminLogoutUser))
+	adminRoutes.HandleFunc("GET /organisations", handler.Handle(h.AdminListOrganisations))
+	adminRoutes.HandleFunc("GET /organisations/{orgId}/users", handler.Handle(h.AdminGetOrganisationMembers))
+	adminRoutes.HandleFunc("GET /audit", handler.Handle(h.AdminGetAuditLog))
 
-	mux.Handle("/auth/", http.StripPrefix("/auth", authRoutes))
-	mux.Handle("/api/", http.StripPrefix("/api", handler.Authenticate(apiRoutes)))
+	mux.Handle("/auth/", http.StripPrefix("/auth", limitAuth(authRoutes)))
+	mux.Handle("/api/", http.StripPrefix("/api", h.Authenticate(limitAPI(apiRoutes))))
+	mux.Handle("/admin/api/", http.StripPrefix("/admin/api", h.Authenticate(limitAPI(handler.RequireSuperadmin(adminRoutes)))))
 
 	// just incase
-	mux.HandleFunc("POST /api/auth/register", handler.Handle(h.AuthRegister))
-	mux.HandleFunc("POST /api/auth/login", handler.Handle(h.AuthLogin))
+	mux.Handle("POST /api/auth/register", limitAuth(handler.Handle(h.AuthRegister)))
+	mux.Handle("POST /api/auth/login", limitAuth(handler.Handle(h.AuthLogin)))
 
-	return handler.Logger(handler.StripSlashes(mux))
+	return handler.RequestId(handler.Logger(handler.StripSlashes(mux)))
 }
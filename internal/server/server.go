@@ -3,29 +3,44 @@ package server
 import (
 	"fmt"
 	"net/http"
-	"os"
-	"strconv"
 	"time"
 
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/config"
 	database "github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/health"
+	"github.com/michaelcosj/hng-task-two/internal/metrics"
+	"github.com/michaelcosj/hng-task-two/internal/ratelimit"
+	"github.com/michaelcosj/hng-task-two/internal/server/handler"
 	"github.com/michaelcosj/hng-task-two/internal/service"
 )
 
-func New(db database.Db) *http.Server {
-	port, err := strconv.Atoi(os.Getenv("PORT"))
-	if err != nil {
-		port = 6969
-	}
-
-	querier := database.New(db)
-	repo := database.NewRepoQuerier(querier, db)
+// New creates the server. limitStore keeps the rate limits and
+// is nil when rate limiting is turned off
+func New(cfg config.Config, repo database.RepoQuerier, tokens *app.TokenIssuer, events handler.EventSubscriber, limitStore ratelimit.Store, readiness *health.Readiness) *http.Server {
+	svc := service.WithTracing(service.New(repo, tokens))
 
-	svc := service.New(repo)
-	handler := RegisterRoutes(svc)
+	// probes, metrics and docs are served outside the api routes so
+	// frequent health checks and scrapes don't fill the request log
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", health.Liveness)
+	mux.Handle("GET /readyz", readiness)
+	mux.HandleFunc("GET /version", health.Version)
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("GET /openapi.json", serveSpec)
+	mux.Handle("GET /docs/", docs)
+	mux.Handle("GET /docs", http.RedirectHandler("/docs/", http.StatusMovedPermanently))
+	mux.Handle("/", RegisterRoutes(svc, tokens, events, RateLimits{
+		Store:    limitStore,
+		ClientIP: ratelimit.NewClientIP(cfg.RateLimit.TrustedProxies),
+		Auth:     cfg.RateLimit.Auth,
+		API:      cfg.RateLimit.API,
+	}))
 
+	// event streams clear the write timeout for their own responses
 	httpServer := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      handler,
+		Addr:         fmt.Sprintf(":%d", cfg.Port),
+		Handler:      mux,
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// the methods here are for administrators, they don't check
+// who is asking so they must not be reachable by users
+
+func (s *service) GetAccount(ctx context.Context, userId uuid.UUID) (*AccountData, error) {
+	user, err := s.repo.UserWhereId(ctx, userId)
+	if err != nil {
+		return nil, accountError(err)
+	}
+
+	data := accountData(user)
+	return &data, nil
+}
+
+func (s *service) GetAccountByEmail(ctx context.Context, email string) (*AccountData, error) {
+	user, err := s.repo.UserWhereEmail(ctx, email)
+	if err != nil {
+		return nil, accountError(err)
+	}
+
+	data := accountData(user)
+	return &data, nil
+}
+
+// SearchAccounts lists the users whose email or name contains the
+// query, ordered by email
+func (s *service) SearchAccounts(ctx context.Context, actorId uuid.UUID, param SearchParam) (*AccountsData, error) {
+	users, err := s.repo.UserSearch(ctx, db.UserSearchParams{
+		Query:  param.Query,
+		Limit:  int32(param.Limit),
+		Offset: int32(param.Offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching users: %w", err)
+	}
+
+	if err := record(ctx, s.repo, actorId, "users.search", uuid.Nil, searchData(param)); err != nil {
+		return nil, err
+	}
+
+	resp := &AccountsData{Accounts: []AccountData{}}
+	for _, user := range users {
+		resp.Accounts = append(resp.Accounts, accountData(user))
+	}
+	return resp, nil
+}
+
+// SearchOrganisations lists the organisations whose name contains
+// the query, ordered by name
+func (s *service) SearchOrganisations(ctx context.Context, actorId uuid.UUID, param SearchParam) (*OrgsData, error) {
+	orgs, err := s.repo.OrgSearch(ctx, db.OrgSearchParams{
+		Query:  param.Query,
+		Limit:  int32(param.Limit),
+		Offset: int32(param.Offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching organisations: %w", err)
+	}
+
+	if err := record(ctx, s.repo, actorId, "organisations.search", uuid.Nil, searchData(param)); err != nil {
+		return nil, err
+	}
+
+	resp := &OrgsData{Orgs: []OrgData{}}
+	for _, org := range orgs {
+		resp.Orgs = append(resp.Orgs, OrgData{
+			Id:          org.ID.String(),
+			Name:        org.Name,
+			Description: org.Description.String,
+		})
+	}
+	return resp, nil
+}
+
+func (s *service) GetOrganisationMembers(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID) (*MemberAccountsData, error) {
+	if _, err := s.repo.OrganisationWhereId(ctx, orgId); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, app.ApiErrorFrom(fmt.Errorf("error finding organisation: %w", app.ErrOrgNotFound))
+		}
+		return nil, fmt.Errorf("error finding organisation: %w", err)
+	}
+
+	members, err := s.repo.OrgMembersWhereOrg(ctx, orgId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding organisation members: %w", err)
+	}
+
+	if err := record(ctx, s.repo, actorId, "organisation.view_members", orgId, nil); err != nil {
+		return nil, err
+	}
+
+	resp := &MemberAccountsData{Members: []MemberAccountData{}}
+	for _, member := range members {
+		resp.Members = append(resp.Members, MemberAccountData{
+			AccountData: accountData(db.User{
+				ID:              member.ID,
+				Email:           member.Email,
+				FirstName:       member.FirstName,
+				LastName:        member.LastName,
+				Phone:           member.Phone,
+				IsSuperadmin:    member.IsSuperadmin,
+				Status:          member.Status,
+				StatusReason:    member.StatusReason,
+				StatusChangedAt: member.StatusChangedAt,
+			}),
+			Role: member.Role,
+		})
+	}
+	return resp, nil
+}
+
+// ResetPassword sets a user's password, logging them out everywhere
+func (s *service) ResetPassword(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, password string) error {
+	passwordHash, err := hashPassword(ctx, password)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+
+	return s.updateUser(ctx, actorId, "user.reset_password", userId, nil, func(q db.RepoQuerier) (int64, error) {
+		return q.UserUpdatePassword(ctx, db.UserUpdatePasswordParams{
+			ID:       userId,
+			Password: string(passwordHash),
+		})
+	})
+}
+
+// SuspendAccount blocks a user until they are reactivated, they
+// can't log in and the tokens they have stop working
+func (s *service) SuspendAccount(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, reason string) error {
+	if reason == "" {
+		return app.NewValidationError([]app.FieldError{{Field: "reason", Message: "is required"}})
+	}
+
+	return s.updateUser(ctx, actorId, "user.suspend", userId, map[string]any{"reason": reason}, func(q db.RepoQuerier) (int64, error) {
+		return q.UserSetStatus(ctx, db.UserSetStatusParams{
+			ID:           userId,
+			Status:       StatusSuspended,
+			StatusReason: pgtype.Text{String: reason, Valid: true},
+		})
+	})
+}
+
+// ReactivateAccount makes a suspended or deactivated user active
+// again, their old tokens stay revoked
+func (s *service) ReactivateAccount(ctx context.Context, actorId uuid.UUID, userId uuid.UUID) error {
+	return s.updateUser(ctx, actorId, "user.reactivate", userId, nil, func(q db.RepoQuerier) (int64, error) {
+		return q.UserSetStatus(ctx, db.UserSetStatusParams{ID: userId, Status: StatusActive})
+	})
+}
+
+// SetSuperadmin grants or revokes access to the admin api,
+// only the admin command can do it
+func (s *service) SetSuperadmin(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, superadmin bool) error {
+	action := "user.revoke_superadmin"
+	if superadmin {
+		action = "user.grant_superadmin"
+	}
+
+	return s.updateUser(ctx, actorId, action, userId, nil, func(q db.RepoQuerier) (int64, error) {
+		return q.UserSetSuperadmin(ctx, db.UserSetSuperadminParams{
+			ID:           userId,
+			IsSuperadmin: superadmin,
+		})
+	})
+}
+
+// LogoutUser revokes every token the user has been issued
+func (s *service) LogoutUser(ctx context.Context, actorId uuid.UUID, userId uuid.UUID) error {
+	return s.updateUser(ctx, actorId, "user.logout", userId, nil, func(q db.RepoQuerier) (int64, error) {
+		return q.UserRevokeTokens(ctx, userId)
+	})
+}
+
+// updateUser runs update and records action in the same transaction
+func (s *service) updateUser(ctx context.Context, actorId uuid.UUID, action string, userId uuid.UUID, data map[string]any, update func(q db.RepoQuerier) (int64, error)) error {
+	return s.inTx(ctx, func(q db.RepoQuerier) error {
+		updated, err := update(q)
+		if err != nil {
+			return fmt.Errorf("error updating user: %w", err)
+		}
+		if updated == 0 {
+			return app.ErrUserNotFound
+		}
+
+		return record(ctx, q, actorId, action, userId, data)
+	})
+}
+
+func (s *service) SetMemberRole(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID, userId uuid.UUID, role string) error {
+	if err := checkRole(role); err != nil {
+		return err
+	}
+
+	return s.inRetryTx(ctx, func(q db.RepoQuerier) error {
+		updated, err := q.UserSetOrgRole(ctx, db.UserSetOrgRoleParams{
+			UserID: userId,
+			OrgID:  orgId,
+			Role:   role,
+		})
+		if err != nil {
+			return fmt.Errorf("error updating member role: %w", err)
+		}
+		if updated == 0 {
+			return app.ErrUserNotFound
+		}
+
+		return record(ctx, q, actorId, "member.set_role", userId, map[string]any{
+			"orgId": orgId,
+			"role":  role,
+		})
+	})
+}
+
+// CreateAccount registers a user for them, with a default
+// organisation like users who register themselves
+func (s *service) CreateAccount(ctx context.Context, actorId uuid.UUID, param RegisterParams) (*AuthData, error) {
+	return s.register(ctx, param, func(q db.Querier, user db.User) error {
+		return record(ctx, q, actorId, "user.create", user.ID, map[string]any{
+			"email": user.Email,
+		})
+	})
+}
+
+// CreateOrganisationFor creates an organisation with ownerId as its admin
+func (s *service) CreateOrganisationFor(ctx context.Context, actorId uuid.UUID, ownerId uuid.UUID, param CreateOrgParam) (*OrgData, error) {
+	return s.createOrganisation(ctx, ownerId, param, func(q db.Querier, org db.Organisation) error {
+		return record(ctx, q, actorId, "org.create", org.ID, map[string]any{
+			"ownerId": ownerId,
+			"name":    org.Name,
+		})
+	})
+}
+
+// AddMember adds the user to the organisation with role, the membership
+// and its role are saved together so a failure can't leave a member
+// without the role they were given
+func (s *service) AddMember(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID, userId uuid.UUID, role string) error {
+	if err := checkRole(role); err != nil {
+		return err
+	}
+
+	return s.inRetryTx(ctx, func(q db.RepoQuerier) error {
+		if err := addMember(ctx, q, orgId, userId, role); err != nil {
+			return err
+		}
+
+		return record(ctx, q, actorId, "member.add", userId, map[string]any{
+			"orgId": orgId,
+			"role":  role,
+		})
+	})
+}
+
+func checkRole(role string) error {
+	if role != RoleAdmin && role != RoleMember {
+		return app.NewValidationError([]app.FieldError{{Field: "role", Message: "must be one of admin, member"}})
+	}
+	return nil
+}
+
+// RemoveMember removes the user from the organisation without the
+// org admin check members removing each other go through
+func (s *service) RemoveMember(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID, userId uuid.UUID) error {
+	return s.inRetryTx(ctx, func(q db.RepoQuerier) error {
+		if err := removeMember(ctx, q, orgId, userId); err != nil {
+			return err
+		}
+
+		return record(ctx, q, actorId, "member.remove", userId, map[string]any{
+			"orgId": orgId,
+		})
+	})
+}
+
+// ExportUser returns everything kept about a user except their password
+func (s *service) ExportUser(ctx context.Context, actorId uuid.UUID, userId uuid.UUID) (*UserExportData, error) {
+	user, err := s.repo.UserWhereId(ctx, userId)
+	if err != nil {
+		return nil, accountError(err)
+	}
+
+	memberships, err := s.repo.OrgMembershipsWhereUser(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding user orgs: %w", err)
+	}
+
+	if err := record(ctx, s.repo, actorId, "user.export", userId, nil); err != nil {
+		return nil, err
+	}
+
+	data := &UserExportData{
+		AccountData:   accountData(user),
+		Organisations: []MembershipData{},
+	}
+	for _, membership := range memberships {
+		data.Organisations = append(data.Organisations, MembershipData{
+			OrgData: OrgData{
+				Id:          membership.ID.String(),
+				Name:        membership.Name,
+				Description: membership.Description.String,
+			},
+			Role: membership.Role,
+		})
+	}
+	return data, nil
+}
+
+// GetAuditLog lists the audit log newest first
+func (s *service) GetAuditLog(ctx context.Context, param AuditLogParam) (*AuditLogData, error) {
+	entries, err := s.repo.AuditList(ctx, db.AuditListParams{
+		Before:   param.Before,
+		TargetID: nullUUID(param.TargetId),
+		Limit:    int32(param.Limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing audit log: %w", err)
+	}
+
+	resp := &AuditLogData{Entries: []AuditEntryData{}}
+	for _, entry := range entries {
+		data := AuditEntryData{
+			Id:        entry.ID,
+			Action:    entry.Action,
+			Data:      entry.Data,
+			CreatedAt: entry.CreatedAt.Time,
+		}
+		if entry.ActorID.Valid {
+			data.ActorId = entry.ActorID.UUID.String()
+		}
+		if entry.TargetID.Valid {
+			data.TargetId = entry.TargetID.UUID.String()
+		}
+		resp.Entries = append(resp.Entries, data)
+	}
+	return resp, nil
+}
+
+// record adds action to the audit log. the action fails if it
+// can't be recorded, nothing is done without leaving a trace
+func record(ctx context.Context, q db.Querier, actorId uuid.UUID, action string, targetId uuid.UUID, data map[string]any) error {
+	if data == nil {
+		data = map[string]any{}
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding audit data: %w", err)
+	}
+
+	if _, err := q.AuditInsert(ctx, db.AuditInsertParams{
+		ActorID:  nullUUID(actorId),
+		Action:   action,
+		TargetID: nullUUID(targetId),
+		Data:     raw,
+	}); err != nil {
+		return fmt.Errorf("error recording %s in the audit log: %w", action, err)
+	}
+	return nil
+}
+
+func searchData(param SearchParam) map[string]any {
+	return map[string]any{"query": param.Query, "limit": param.Limit, "offset": param.Offset}
+}
+
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+func accountError(err error) error {
+	if errors.Is(err, db.ErrNotFound) {
+		return app.ApiErrorFrom(fmt.Errorf("error retrieving user from db: %w", app.ErrUserNotFound))
+	}
+	return fmt.Errorf("error retrieving user from db: %w", err)
+}
+
+func accountData(user db.User) AccountData {
+	return AccountData{
+		UserData: UserData{
+			Id:        user.ID.String(),
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Email:     user.Email,
+			Phone:     user.Phone.String,
+		},
+		Status:          user.Status,
+		StatusReason:    user.StatusReason.String,
+		StatusChangedAt: timePtr(user.StatusChangedAt),
+		Superadmin:      user.IsSuperadmin,
+	}
+}
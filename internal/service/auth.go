@@ -2,78 +2,99 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
-	"github.com/jackc/pgerrcode"
-	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/michaelcosj/hng-task-two/internal/app"
 	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/metrics"
+	"github.com/michaelcosj/hng-task-two/internal/tracing"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
 
-func (s *service) Register(ctx context.Context, param RegisterParams) (*AuthData, error) {
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(param.Password), bcrypt.DefaultCost)
+// user statuses, only active users can log in and use their tokens
+const (
+	StatusActive      = "active"
+	StatusSuspended   = "suspended"
+	StatusDeactivated = "deactivated"
+)
+
+// DeactivationGracePeriod is how long users who deactivated their
+// account have to reopen it by logging in
+const DeactivationGracePeriod = 30 * 24 * time.Hour
+
+func (s *service) Register(ctx context.Context, param RegisterParams) (_ *AuthData, err error) {
+	defer func() { metrics.Registrations.WithLabelValues(metrics.Result(err)).Inc() }()
+
+	return s.register(ctx, param, nil)
+}
+
+// register creates the user and their default organisation. audit,
+// when given, records the registration in the same transaction
+func (s *service) register(ctx context.Context, param RegisterParams, audit func(q db.Querier, user db.User) error) (*AuthData, error) {
+	passwordHash, err := hashPassword(ctx, param.Password)
 	if err != nil {
 		return nil, fmt.Errorf("error hashing password: %w", err)
 	}
 
 	// use a transaction so a user cannot be created if
 	// creating and attaching it to it's default organisation fails
-	tx, err := s.repo.GetDB().Begin(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("cannot create database transaction: %w", err)
-	}
-	defer tx.Rollback(ctx)
+	var user db.User
+	err = s.inRetryTx(ctx, func(qTx db.RepoQuerier) (err error) {
+		user, err = qTx.UserInsert(ctx, db.UserInsertParams{
+			Email:     param.Email,
+			FirstName: param.FirstName,
+			LastName:  param.LastName,
+			Password:  string(passwordHash),
+			Phone:     pgtype.Text{String: param.Phone, Valid: param.Phone != ""},
+		})
+
+		if err != nil {
+			// a conflict means a user with this email already exists
+			// this is a user request error
+			if errors.Is(err, db.ErrConflict) {
+				return app.ErrUserAlreadyExists
+			}
+			return fmt.Errorf("error in user registration service: %w", err)
+		}
 
-	qTx := s.repo.WithTx(tx)
+		// create a default organisation for the user
+		// using the user's name to generate the organisation name
+		fNameCapitalised := cases.Title(language.English, cases.Compact).String(param.FirstName)
+		org, err := qTx.OrgInsert(ctx, db.OrgInsertParams{
+			Name:        fmt.Sprintf("%s's Organisation", fNameCapitalised),
+			Description: pgtype.Text{Valid: false},
+		})
 
-	user, err := qTx.UserInsert(ctx, db.UserInsertParams{
-		Email:     param.Email,
-		FirstName: param.FirstName,
-		LastName:  param.LastName,
-		Password:  string(passwordHash),
-		Phone:     pgtype.Text{String: param.Phone, Valid: len(param.Phone) == 11},
-	})
+		if err != nil {
+			return fmt.Errorf("error in user registration service: %w", err)
+		}
 
-	if err != nil {
-		// if a unique violation error occurs, it means a user with this email already exists
-		// this is a user request error
-		if pgerr, ok := err.(*pgconn.PgError); ok && pgerr.Code == pgerrcode.UniqueViolation {
-			return nil, app.ErrUserAlreadyExists
+		// add the user to the default organisation
+		if err = qTx.UserAddOrg(ctx, db.UserAddOrgParams{
+			UserID: user.ID,
+			OrgID:  org.ID,
+			Role:   RoleAdmin,
+		}); err != nil {
+			return fmt.Errorf("error in user registration service: %w", err)
 		}
-		return nil, fmt.Errorf("error in user registration service: %w", err)
-	}
 
-	// create a default organisation for the user
-	// using the user's name to generate the organisation name
-	fNameCapitalised := cases.Title(language.English, cases.Compact).String(param.FirstName)
-	org, err := qTx.OrgInsert(ctx, db.OrgInsertParams{
-		Name:        fmt.Sprintf("%s's Organisation", fNameCapitalised),
-		Description: pgtype.Text{Valid: false},
+		if audit != nil {
+			return audit(qTx, user)
+		}
+		return nil
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("error in user registration service: %w", err)
-	}
-
-	// add the user to the default organisation
-	if err = qTx.UserAddOrg(ctx, db.UserAddOrgParams{
-		UserID: user.ID,
-		OrgID:  org.ID,
-	}); err != nil {
-		return nil, fmt.Errorf("error in user registration service: %w", err)
-	}
-
-	// commit transaction
-	if err := tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, err
 	}
 
 	// create jwt token
-	token, err := app.CreateToken(user.ID.String())
+	token, err := s.tokens.CreateToken(user.ID.String(), user.TokenVersion)
 	if err != nil {
 		return nil, fmt.Errorf("error in user registration service: %w", err)
 	}
@@ -90,19 +111,35 @@ func (s *service) Register(ctx context.Context, param RegisterParams) (*AuthData
 	}, nil
 }
 
-func (s *service) Login(ctx context.Context, param LoginParams) (*AuthData, error) {
+func (s *service) Login(ctx context.Context, param LoginParams) (_ *AuthData, err error) {
+	defer func() { metrics.Logins.WithLabelValues(metrics.Result(err)).Inc() }()
+
 	user, err := s.repo.UserWhereEmail(ctx, param.Email)
-	if err != nil {
+	if errors.Is(err, db.ErrNotFound) {
 		return nil, app.ApiErrorFrom(fmt.Errorf("error retrieving user from db: %w", app.ErrAuthenticationFailed))
 	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving user from db: %w", err)
+	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(param.Password))
+	err = comparePassword(ctx, user.Password, param.Password)
 	if err != nil {
 		return nil, app.ApiErrorFrom(fmt.Errorf("error comparing user password with hash: %w", app.ErrAuthenticationFailed))
 	}
 
+	// only reported once the password is right so it
+	// doesn't reveal which emails have accounts
+	switch user.Status {
+	case StatusSuspended:
+		return nil, app.ApiErrorFrom(fmt.Errorf("user %s is suspended: %w", user.ID, app.ErrAccountSuspended))
+	case StatusDeactivated:
+		if err := s.reactivate(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
 	// create jwt token
-	token, err := app.CreateToken(user.ID.String())
+	token, err := s.tokens.CreateToken(user.ID.String(), user.TokenVersion)
 	if err != nil {
 		return nil, fmt.Errorf("error in user registration service: %w", err)
 	}
@@ -118,3 +155,95 @@ func (s *service) Login(ctx context.Context, param LoginParams) (*AuthData, erro
 		},
 	}, nil
 }
+
+// Authenticate checks the user a verified token was issued to can still
+// use it, tokens are revoked by moving the user's token version on
+func (s *service) Authenticate(ctx context.Context, userId uuid.UUID, tokenVersion int32) (*SessionData, error) {
+	user, err := s.repo.UserWhereId(ctx, userId)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, app.ApiErrorFrom(fmt.Errorf("token user %s does not exist: %w", userId, app.ErrInvalidToken))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving user from db: %w", err)
+	}
+
+	// checked before the version, which leaving the active status moves
+	// on, so the user is told why their token stopped working
+	switch user.Status {
+	case StatusSuspended:
+		return nil, app.ApiErrorFrom(fmt.Errorf("user %s is suspended: %w", userId, app.ErrAccountSuspended))
+	case StatusDeactivated:
+		return nil, app.ApiErrorFrom(fmt.Errorf("user %s is deactivated: %w", userId, app.ErrAccountDeactivated))
+	}
+
+	if user.TokenVersion != tokenVersion {
+		return nil, app.ApiErrorFrom(fmt.Errorf("token version %d of user %s was revoked: %w", tokenVersion, userId, app.ErrInvalidToken))
+	}
+
+	return &SessionData{UserId: user.ID, Superadmin: user.IsSuperadmin}, nil
+}
+
+// DeactivateAccount closes the user's own account, logging in
+// within DeactivationGracePeriod reopens it
+func (s *service) DeactivateAccount(ctx context.Context, userId uuid.UUID, param DeactivateParam) (*DeactivationData, error) {
+	user, err := s.repo.UserWhereId(ctx, userId)
+	if err != nil {
+		return nil, accountError(err)
+	}
+
+	// asked for again so a stolen token can't close the account
+	if err := comparePassword(ctx, user.Password, param.Password); err != nil {
+		return nil, app.ApiErrorFrom(fmt.Errorf("error comparing user password with hash: %w", app.ErrAuthenticationFailed))
+	}
+
+	if err := s.inTx(ctx, func(q db.RepoQuerier) error {
+		if _, err := q.UserSetStatus(ctx, db.UserSetStatusParams{
+			ID:           userId,
+			Status:       StatusDeactivated,
+			StatusReason: pgtype.Text{String: param.Reason, Valid: param.Reason != ""},
+		}); err != nil {
+			return fmt.Errorf("error deactivating user: %w", err)
+		}
+		return record(ctx, q, userId, "user.deactivate", userId, nil)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &DeactivationData{ReactivateBefore: time.Now().Add(DeactivationGracePeriod)}, nil
+}
+
+// reactivate reopens the account of a user who deactivated it,
+// unless the grace period is over
+func (s *service) reactivate(ctx context.Context, user db.User) error {
+	if time.Since(user.StatusChangedAt.Time) > DeactivationGracePeriod {
+		return app.ApiErrorFrom(fmt.Errorf("user %s deactivated their account at %s: %w", user.ID, user.StatusChangedAt.Time, app.ErrAccountDeactivated))
+	}
+
+	return s.inTx(ctx, func(q db.RepoQuerier) error {
+		if _, err := q.UserSetStatus(ctx, db.UserSetStatusParams{ID: user.ID, Status: StatusActive}); err != nil {
+			return fmt.Errorf("error reactivating user: %w", err)
+		}
+		return record(ctx, q, user.ID, "user.reactivate", user.ID, nil)
+	})
+}
+
+func hashPassword(ctx context.Context, password string) ([]byte, error) {
+	_, span := tracing.Tracer.Start(ctx, "bcrypt.hash")
+	defer span.End()
+
+	start := time.Now()
+	defer func() { metrics.BcryptDuration.WithLabelValues("hash").Observe(time.Since(start).Seconds()) }()
+
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+func comparePassword(ctx context.Context, hash string, password string) error {
+	// a mismatch isn't recorded as a span error, it is an expected outcome
+	_, span := tracing.Tracer.Start(ctx, "bcrypt.compare")
+	defer span.End()
+
+	start := time.Now()
+	defer func() { metrics.BcryptDuration.WithLabelValues("compare").Observe(time.Since(start).Seconds()) }()
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
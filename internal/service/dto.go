@@ -1,5 +1,12 @@
 package service
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
 type UserData struct {
 	Id        string `json:"userId"`
 	FirstName string `json:"firstName"`
@@ -13,6 +20,67 @@ type AuthData struct {
 	User  UserData `json:"user"`
 }
 
+// SessionData is who a request is made by
+type SessionData struct {
+	UserId     uuid.UUID
+	Superadmin bool
+}
+
+// AccountData is a user as administrators see them
+type AccountData struct {
+	UserData
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"statusReason,omitempty"`
+	StatusChangedAt *time.Time `json:"statusChangedAt,omitempty"`
+	Superadmin      bool       `json:"isSuperadmin"`
+}
+
+// DeactivationData says until when a deactivated account can be
+// reopened by logging in
+type DeactivationData struct {
+	ReactivateBefore time.Time `json:"reactivateBefore"`
+}
+
+type AccountsData struct {
+	Accounts []AccountData `json:"accounts"`
+}
+
+// UserExportData is everything kept about a user except their password
+type UserExportData struct {
+	AccountData
+	Organisations []MembershipData `json:"organisations"`
+}
+
+type MembershipData struct {
+	OrgData
+	Role string `json:"role"`
+}
+
+// MemberAccountData is a member of an organisation as administrators see them
+type MemberAccountData struct {
+	AccountData
+	Role string `json:"role"`
+}
+
+type MemberAccountsData struct {
+	Members []MemberAccountData `json:"members"`
+}
+
+// AuditEntryData is something a superadmin or the admin command did.
+// ActorId is empty for the admin command
+type AuditEntryData struct {
+	Id        int64           `json:"id"`
+	ActorId   string          `json:"actorId,omitempty"`
+	Action    string          `json:"action"`
+	TargetId  string          `json:"targetId,omitempty"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type AuditLogData struct {
+	Entries []AuditEntryData `json:"entries"`
+}
+
 type OrgData struct {
 	Id          string `json:"orgId"`
 	Name        string `json:"name"`
@@ -22,3 +90,46 @@ type OrgData struct {
 type OrgsData struct {
 	Orgs []OrgData `json:"organisations"`
 }
+
+type MemberData struct {
+	UserId string `json:"userId"`
+	Role   string `json:"role,omitempty"`
+}
+
+type WebhookData struct {
+	Id        string    `json:"webhookId"`
+	Url       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type WebhooksData struct {
+	Webhooks []WebhookData `json:"webhooks"`
+}
+
+type DeliveryData struct {
+	Id             string     `json:"deliveryId"`
+	EventId        string     `json:"eventId"`
+	EventType      string     `json:"eventType"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	LastStatusCode int32      `json:"lastStatusCode,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+}
+
+type DeliveriesData struct {
+	Deliveries []DeliveryData `json:"deliveries"`
+}
+
+type OrgEventData struct {
+	Id        int64           `json:"id"`
+	Type      string          `json:"type"`
+	OrgId     string          `json:"orgId"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// maximum number of events returned by one call, streams
+// keep asking until they get less than this
+const OrgEventPageSize = 100
+
+func (s *service) GetLatestOrganisationEventId(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) (int64, error) {
+	if err := s.requireOrgMember(ctx, userId, orgId); err != nil {
+		return 0, err
+	}
+
+	id, err := s.repo.OrgEventLatestId(ctx, orgId)
+	if err != nil {
+		return 0, fmt.Errorf("error finding latest organisation event: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetOrganisationEvents returns the organisation's events recorded after afterId.
+// membership is checked on every call so a stream stops once its user is
+// removed, streams authenticate the user again for their status and token
+func (s *service) GetOrganisationEvents(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, afterId int64) ([]OrgEventData, error) {
+	if err := s.requireOrgMember(ctx, userId, orgId); err != nil {
+		return nil, err
+	}
+
+	events, err := s.repo.OrgEventsAfter(ctx, db.OrgEventsAfterParams{
+		OrgID: orgId,
+		ID:    afterId,
+		Limit: OrgEventPageSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error finding organisation events: %w", err)
+	}
+
+	resp := make([]OrgEventData, 0, len(events))
+	for _, event := range events {
+		resp = append(resp, OrgEventData{
+			Id:        event.ID,
+			Type:      event.Type,
+			OrgId:     event.OrgID.String(),
+			CreatedAt: event.CreatedAt.Time,
+			Data:      event.Data,
+		})
+	}
+
+	return resp, nil
+}
@@ -2,18 +2,28 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/michaelcosj/hng-task-two/internal/app"
 	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/job"
+	"github.com/michaelcosj/hng-task-two/internal/webhook"
+)
+
+// roles a user can have in an organisation
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
 )
 
 func (s *service) GetUserOrganisations(ctx context.Context, userId uuid.UUID) (*OrgsData, error) {
 	orgs, err := s.repo.OrgAllWhereUser(ctx, userId)
 	if err != nil {
-		return nil, app.ApiErrorFrom(fmt.Errorf("error finding user orgs: %v", app.ErrClientError))
+		return nil, fmt.Errorf("error finding user orgs: %w", err)
 	}
 
 	resp := &OrgsData{}
@@ -34,8 +44,11 @@ func (s *service) GetUserOrganisationById(ctx context.Context, userId uuid.UUID,
 		OrgID:  orgId,
 	})
 
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, app.ApiErrorFrom(fmt.Errorf("error retrieving user organisation from db: %w", app.ErrOrgNotFound))
+	}
 	if err != nil {
-		return nil, app.ApiErrorFrom(fmt.Errorf("error retrieving user organisation from db: %v", app.ErrOrgNotFound))
+		return nil, fmt.Errorf("error retrieving user organisation from db: %w", err)
 	}
 
 	return &OrgData{
@@ -46,55 +59,227 @@ func (s *service) GetUserOrganisationById(ctx context.Context, userId uuid.UUID,
 }
 
 func (s *service) CreateOrganisation(ctx context.Context, userId uuid.UUID, param CreateOrgParam) (*OrgData, error) {
+	return s.createOrganisation(ctx, userId, param, nil)
+}
+
+// createOrganisation creates the organisation with the user as its
+// admin. audit, when given, records it in the same transaction
+func (s *service) createOrganisation(ctx context.Context, userId uuid.UUID, param CreateOrgParam, audit func(q db.Querier, org db.Organisation) error) (*OrgData, error) {
 	// create organisation in a transaction
 	// for the same reason as in register service
+	var org db.Organisation
+	err := s.inRetryTx(ctx, func(qTx db.RepoQuerier) (err error) {
+		org, err = qTx.OrgInsert(ctx, db.OrgInsertParams{
+			Name:        param.Name,
+			Description: pgtype.Text{String: param.Description, Valid: len(param.Description) != 0},
+		})
+		if err != nil {
+			return fmt.Errorf("error creating organisation: %w", err)
+		}
+
+		err = qTx.UserAddOrg(ctx, db.UserAddOrgParams{
+			UserID: userId,
+			OrgID:  org.ID,
+			Role:   RoleAdmin,
+		})
+		if errors.Is(err, db.ErrForeignKey) {
+			return app.ApiErrorFrom(fmt.Errorf("error adding user to organisation: %w", app.ErrUserNotFound))
+		}
+		if err != nil {
+			return fmt.Errorf("error adding user to organisation: %w", err)
+		}
+
+		if audit != nil {
+			return audit(qTx, org)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrgData{
+		Id:          org.ID.String(),
+		Name:        org.Name,
+		Description: org.Description.String,
+	}, nil
+}
+
+func (s *service) AddUserToOrganisation(ctx context.Context, orgId uuid.UUID, userId uuid.UUID) error {
+	// the membership and its event are saved together
+	// so the event is never lost or sent for a failed write
+	return s.inRetryTx(ctx, func(q db.RepoQuerier) error {
+		return addMember(ctx, q, orgId, userId, RoleMember)
+	})
+}
+
+// addMember adds the user to the organisation with role and publishes
+// the event with q, the querier of the caller's transaction
+func addMember(ctx context.Context, q db.Querier, orgId uuid.UUID, userId uuid.UUID, role string) error {
+	// check if user exists
+	if _, err := q.UserWhereId(ctx, userId); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return app.ErrUserNotFound
+		}
+		return fmt.Errorf("error retrieving user from db: %w", err)
+	}
+
+	err := q.UserAddOrg(ctx, db.UserAddOrgParams{
+		UserID: userId,
+		OrgID:  orgId,
+		Role:   role,
+	})
+
+	switch {
+	case errors.Is(err, db.ErrConflict):
+		return app.ErrUserAlreadyMember
+	case errors.Is(err, db.ErrForeignKey):
+		// the user was checked above, so it is the organisation that is missing
+		return app.ErrOrgNotFound
+	case err != nil:
+		return fmt.Errorf("error adding user to organisation: %w", err)
+	}
+
+	return publishEvent(ctx, q, orgId, webhook.EventMemberAdded, MemberData{
+		UserId: userId.String(),
+		Role:   role,
+	})
+}
+
+func (s *service) UpdateOrganisation(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, param UpdateOrgParam) (*OrgData, error) {
+	if err := s.requireOrgAdmin(ctx, userId, orgId); err != nil {
+		return nil, err
+	}
+
 	tx, err := s.repo.GetDB().Begin(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("cannot create database transaction: %v", err)
+		return nil, fmt.Errorf("cannot create database transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
 	qTx := s.repo.WithTx(tx)
 
-	org, err := s.repo.OrgInsert(ctx, db.OrgInsertParams{
+	org, err := qTx.OrgUpdate(ctx, db.OrgUpdateParams{
+		ID:          orgId,
 		Name:        param.Name,
 		Description: pgtype.Text{String: param.Description, Valid: len(param.Description) != 0},
 	})
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, app.ApiErrorFrom(fmt.Errorf("error updating organisation: %w", app.ErrOrgNotFound))
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error creating organisation: %v", err)
+		return nil, fmt.Errorf("error updating organisation: %w", err)
 	}
 
-	if err = qTx.UserAddOrg(ctx, db.UserAddOrgParams{
-		UserID: userId,
-		OrgID:  org.ID,
-	}); err != nil {
-		return nil, app.ApiErrorFrom(fmt.Errorf("error adding user to organisation: %v", app.ErrUserNotFound))
+	data := &OrgData{
+		Id:          org.ID.String(),
+		Name:        org.Name,
+		Description: org.Description.String,
+	}
+
+	if err := publishEvent(ctx, qTx, orgId, webhook.EventOrgUpdated, data); err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return &OrgData{
-		Id:          org.ID.String(),
-		Name:        org.Name,
-		Description: org.Description.String,
-	}, nil
+	return data, nil
 }
 
-func (s *service) AddUserToOrganisation(ctx context.Context, orgId uuid.UUID, userId uuid.UUID) error {
-	// check if user exists
-	if _, err := s.repo.UserWhereId(ctx, userId); err != nil {
+func (s *service) RemoveUserFromOrganisation(ctx context.Context, authUserId uuid.UUID, orgId uuid.UUID, userId uuid.UUID) error {
+	// members can leave an organisation themselves,
+	// removing anyone else needs an admin
+	if authUserId != userId {
+		if err := s.requireOrgAdmin(ctx, authUserId, orgId); err != nil {
+			return err
+		}
+	}
+
+	return s.inRetryTx(ctx, func(q db.RepoQuerier) error {
+		return removeMember(ctx, q, orgId, userId)
+	})
+}
+
+// removeMember removes the user from the organisation and publishes
+// the event with q, the querier of the caller's transaction
+func removeMember(ctx context.Context, q db.Querier, orgId uuid.UUID, userId uuid.UUID) error {
+	removed, err := q.UserRemoveOrg(ctx, db.UserRemoveOrgParams{
+		UserID: userId,
+		OrgID:  orgId,
+	})
+	if err != nil {
+		return fmt.Errorf("error removing user from organisation: %w", err)
+	}
+
+	if removed == 0 {
 		return app.ErrUserNotFound
 	}
 
-	err := s.repo.UserAddOrg(ctx, db.UserAddOrgParams{
+	return publishEvent(ctx, q, orgId, webhook.EventMemberRemoved, MemberData{
+		UserId: userId.String(),
+	})
+}
+
+// requireOrgAdmin returns an error if the user is not an admin of the organisation.
+// organisations the user does not belong to are reported as not found
+func (s *service) requireOrgAdmin(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) error {
+	role, err := s.orgMemberRole(ctx, userId, orgId)
+	if err != nil {
+		return err
+	}
+
+	if role != RoleAdmin {
+		return app.ApiErrorFrom(fmt.Errorf("user %s is not an admin of %s: %w", userId, orgId, app.ErrForbidden))
+	}
+
+	return nil
+}
+
+// requireOrgMember returns an error if the user does not belong to the organisation
+func (s *service) requireOrgMember(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) error {
+	_, err := s.orgMemberRole(ctx, userId, orgId)
+	return err
+}
+
+// orgMemberRole returns the user's role in the organisation,
+// organisations the user does not belong to are reported as not found
+func (s *service) orgMemberRole(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) (string, error) {
+	role, err := s.repo.OrgMemberRole(ctx, db.OrgMemberRoleParams{
 		UserID: userId,
 		OrgID:  orgId,
 	})
+	if errors.Is(err, db.ErrNotFound) {
+		return "", app.ApiErrorFrom(fmt.Errorf("error retrieving user organisation role: %w", app.ErrOrgNotFound))
+	}
+	if err != nil {
+		return "", fmt.Errorf("error retrieving user organisation role: %w", err)
+	}
 
+	return role, nil
+}
+
+// publishEvent records an organisation event for event streams and
+// enqueues it for webhook delivery. q should be the querier of the
+// transaction making the change so the event is only published
+// if the change is committed
+func publishEvent(ctx context.Context, q db.Querier, orgId uuid.UUID, eventType string, data any) error {
+	encoded, err := json.Marshal(data)
 	if err != nil {
-		return app.ErrOrgNotFound
+		return fmt.Errorf("error encoding %s event: %w", eventType, err)
+	}
+
+	if _, err := q.OrgEventInsert(ctx, db.OrgEventInsertParams{
+		OrgID: orgId,
+		Type:  eventType,
+		Data:  encoded,
+	}); err != nil {
+		return fmt.Errorf("error recording %s event: %w", eventType, err)
+	}
+
+	if err := job.Enqueue(ctx, q, webhook.JobPublish, webhook.NewEvent(orgId, eventType, data)); err != nil {
+		return fmt.Errorf("error publishing %s event: %w", eventType, err)
 	}
 
 	return nil
@@ -2,8 +2,14 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/michaelcosj/hng-task-two/internal/app"
 	"github.com/michaelcosj/hng-task-two/internal/db"
 	database "github.com/michaelcosj/hng-task-two/internal/db"
 )
@@ -21,26 +27,141 @@ type LoginParams struct {
 	Password string
 }
 
+// DeactivateParam confirms a user closing their account with their
+// password, Reason is optional
+type DeactivateParam struct {
+	Password string
+	Reason   string
+}
+
 type CreateOrgParam struct {
 	Name        string
 	Description string
 }
 
+type UpdateOrgParam struct {
+	Name        string
+	Description string
+}
+
+// SearchParam pages through the users or organisations
+// matching Query, an empty query matches everything
+type SearchParam struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// AuditLogParam pages back through the audit log, from the entry
+// before Before or the newest. TargetId only keeps entries about it
+type AuditLogParam struct {
+	Before   int64
+	TargetId uuid.UUID
+	Limit    int
+}
+
+type CreateWebhookParam struct {
+	Url    string
+	Secret string
+	Events []string
+}
+
 type service struct {
-	repo database.RepoQuerier
+	repo   database.RepoQuerier
+	tokens *app.TokenIssuer
 }
 
 type Service interface {
 	Register(ctx context.Context, param RegisterParams) (*AuthData, error)
 	Login(ctx context.Context, param LoginParams) (*AuthData, error)
+	Authenticate(ctx context.Context, userId uuid.UUID, tokenVersion int32) (*SessionData, error)
+	DeactivateAccount(ctx context.Context, userId uuid.UUID, param DeactivateParam) (*DeactivationData, error)
 	GetUser(ctx context.Context, authUserId uuid.UUID, userId uuid.UUID) (*UserData, error)
 	GetUserOrganisations(ctx context.Context, userId uuid.UUID) (*OrgsData, error)
 	GetUserOrganisationById(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) (*OrgData, error)
 	CreateOrganisation(ctx context.Context, userId uuid.UUID, param CreateOrgParam) (*OrgData, error)
 	AddUserToOrganisation(ctx context.Context, orgId uuid.UUID, userId uuid.UUID) error
+	UpdateOrganisation(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, param UpdateOrgParam) (*OrgData, error)
+	RemoveUserFromOrganisation(ctx context.Context, authUserId uuid.UUID, orgId uuid.UUID, userId uuid.UUID) error
+	CreateWebhook(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, param CreateWebhookParam) (*WebhookData, error)
+	GetWebhooks(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) (*WebhooksData, error)
+	DeleteWebhook(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, webhookId uuid.UUID) error
+	GetWebhookDeliveries(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, webhookId uuid.UUID) (*DeliveriesData, error)
+	RedeliverWebhook(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, webhookId uuid.UUID, deliveryId uuid.UUID) (*DeliveryData, error)
+	GetLatestOrganisationEventId(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) (int64, error)
+	GetOrganisationEvents(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, afterId int64) ([]OrgEventData, error)
+
+	// administration, used by the admin command and the admin api. actorId
+	// is the superadmin acting, uuid.Nil for the admin command, and every
+	// call except looking up an account is recorded in the audit log. the
+	// calls above, made by users for themselves, aren't recorded
+	GetAccount(ctx context.Context, userId uuid.UUID) (*AccountData, error)
+	GetAccountByEmail(ctx context.Context, email string) (*AccountData, error)
+	SearchAccounts(ctx context.Context, actorId uuid.UUID, param SearchParam) (*AccountsData, error)
+	SearchOrganisations(ctx context.Context, actorId uuid.UUID, param SearchParam) (*OrgsData, error)
+	GetOrganisationMembers(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID) (*MemberAccountsData, error)
+	ResetPassword(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, password string) error
+	SuspendAccount(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, reason string) error
+	ReactivateAccount(ctx context.Context, actorId uuid.UUID, userId uuid.UUID) error
+	SetSuperadmin(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, superadmin bool) error
+	LogoutUser(ctx context.Context, actorId uuid.UUID, userId uuid.UUID) error
+	SetMemberRole(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID, userId uuid.UUID, role string) error
+	CreateAccount(ctx context.Context, actorId uuid.UUID, param RegisterParams) (*AuthData, error)
+	CreateOrganisationFor(ctx context.Context, actorId uuid.UUID, ownerId uuid.UUID, param CreateOrgParam) (*OrgData, error)
+	AddMember(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID, userId uuid.UUID, role string) error
+	RemoveMember(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID, userId uuid.UUID) error
+	ExportUser(ctx context.Context, actorId uuid.UUID, userId uuid.UUID) (*UserExportData, error)
+	GetAuditLog(ctx context.Context, param AuditLogParam) (*AuditLogData, error)
+}
+
+func New(repo db.RepoQuerier, tokens *app.TokenIssuer) Service {
+	return &service{repo: repo, tokens: tokens}
+}
+
+// inTx runs fn in a transaction, committed if fn succeeds
+func (s *service) inTx(ctx context.Context, fn func(q db.RepoQuerier) error) error {
+	return s.inTxWith(ctx, pgx.TxOptions{}, fn)
 }
 
-func New(repo db.RepoQuerier) Service {
+func (s *service) inTxWith(ctx context.Context, opts pgx.TxOptions, fn func(q db.RepoQuerier) error) error {
+	tx, err := s.repo.GetDB().BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("cannot create database transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(s.repo.WithTx(tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// attempts at a transaction that keeps failing to serialize
+const maxTxAttempts = 3
+
+// inRetryTx runs fn in a serializable transaction, running it again
+// when it conflicts with a concurrent one. it is for writes that read
+// what they change, which could otherwise act on rows another
+// transaction is changing. fn can run more than once so it must only
+// change the database through the querier it is given
+func (s *service) inRetryTx(ctx context.Context, fn func(q db.RepoQuerier) error) error {
+	for attempt := 1; ; attempt++ {
+		err := s.inTxWith(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, fn)
+		// commit errors come from the driver unconverted
+		if attempt == maxTxAttempts || !errors.Is(db.ConvertError(err), db.ErrSerialization) {
+			return err
+		}
 
-	return &service{repo}
+		// jittered so the transactions that conflicted don't run in step again
+		backoff := time.Duration(attempt)*10*time.Millisecond + rand.N(10*time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
 }
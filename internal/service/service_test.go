@@ -3,44 +3,34 @@ package service
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
-	"github.com/joho/godotenv"
 	"github.com/michaelcosj/hng-task-two/internal/app"
-	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/fixture"
+	"github.com/michaelcosj/hng-task-two/internal/pgtest"
 )
 
-func init() {
-	err := godotenv.Load("../../.env")
-	if err != nil {
-		panic(err)
-	}
-}
+var testTokens = app.NewTokenIssuer("a secret only the tests use", 24*time.Hour)
 
-func setupService(ctx context.Context) (*pgx.Conn, Service) {
-	conn, err := pgx.Connect(ctx, os.Getenv("MOCK_PG_URI"))
-	if err != nil {
-		log.Fatalf("Error initialising database: %v", err)
-	}
-
-	testQueries := db.New(conn)
-	testRepo := db.NewRepoQuerier(testQueries, conn)
-	testService := New(testRepo)
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m))
+}
 
-	return conn, testService
+// setupService returns a service whose changes are rolled back when
+// t ends, and fixtures for the data t needs
+func setupService(t *testing.T) (Service, *fixture.Fixtures) {
+	testRepo := pgtest.Repo(t)
+	return New(testRepo, testTokens), fixture.New(t, testRepo)
 }
 
 // Unit test 1
 func TestRegisterService(t *testing.T) {
-	ctx := context.Background()
-
-	conn, testService := setupService(ctx)
-	defer conn.Close(ctx)
+	testService, f := setupService(t)
+	email := f.Email()
 
 	// test that user is created successfully
 	// with correct details and a valid token
@@ -52,18 +42,18 @@ func TestRegisterService(t *testing.T) {
 	}{
 		name: "Test User Registered Successfully and Token Details Is Valid",
 		input: RegisterParams{
-			Email:     "johnDoe@email.com",
+			Email:     email,
 			FirstName: "John",
 			LastName:  "Doe",
 			Password:  "a password",
-			Phone:     "01000000000",
+			Phone:     "+2341000000000",
 		},
 		want: AuthData{
 			User: UserData{
-				Email:     "johnDoe@email.com",
+				Email:     email,
 				FirstName: "John",
 				LastName:  "Doe",
-				Phone:     "01000000000",
+				Phone:     "+2341000000000",
 			},
 		},
 	}
@@ -74,7 +64,7 @@ func TestRegisterService(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		token, err := app.VerifyToken(data.Token)
+		token, err := testTokens.VerifyToken(data.Token)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -118,19 +108,18 @@ func TestRegisterService(t *testing.T) {
 
 func TestGetOrganisation(t *testing.T) {
 	ctx := context.Background()
-	conn, testService := setupService(ctx)
-	defer conn.Close(ctx)
+	testService, f := setupService(t)
 
 	// seed seedData
 	firstUserData := RegisterParams{
-		Email:     "emailone@email.com",
+		Email:     f.Email(),
 		FirstName: "user",
 		LastName:  "one",
 		Password:  "password",
 	}
 
 	secondUserData := RegisterParams{
-		Email:     "emailtwo@email.com",
+		Email:     f.Email(),
 		FirstName: "user",
 		LastName:  "two",
 		Password:  "password",
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedService wraps every service method in a span so a slow request
+// shows which call, and the queries and hashing under it, took the time
+type tracedService struct {
+	next Service
+}
+
+// WithTracing traces the methods of svc
+func WithTracing(svc Service) Service {
+	return &tracedService{next: svc}
+}
+
+func startSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer.Start(ctx, "service."+method, trace.WithAttributes(attrs...))
+}
+
+func userAttr(userId uuid.UUID) attribute.KeyValue {
+	return attribute.String("user.id", userId.String())
+}
+
+func targetUserAttr(userId uuid.UUID) attribute.KeyValue {
+	return attribute.String("target_user.id", userId.String())
+}
+
+func orgAttr(orgId uuid.UUID) attribute.KeyValue {
+	return attribute.String("org.id", orgId.String())
+}
+
+func (t *tracedService) Register(ctx context.Context, param RegisterParams) (data *AuthData, err error) {
+	ctx, span := startSpan(ctx, "Register")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Register(ctx, param)
+}
+
+func (t *tracedService) Login(ctx context.Context, param LoginParams) (data *AuthData, err error) {
+	ctx, span := startSpan(ctx, "Login")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Login(ctx, param)
+}
+
+func (t *tracedService) Authenticate(ctx context.Context, userId uuid.UUID, tokenVersion int32) (data *SessionData, err error) {
+	ctx, span := startSpan(ctx, "Authenticate", userAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.Authenticate(ctx, userId, tokenVersion)
+}
+
+func (t *tracedService) DeactivateAccount(ctx context.Context, userId uuid.UUID, param DeactivateParam) (data *DeactivationData, err error) {
+	ctx, span := startSpan(ctx, "DeactivateAccount", userAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.DeactivateAccount(ctx, userId, param)
+}
+
+func (t *tracedService) GetUser(ctx context.Context, authUserId uuid.UUID, userId uuid.UUID) (data *UserData, err error) {
+	ctx, span := startSpan(ctx, "GetUser", userAttr(authUserId), targetUserAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetUser(ctx, authUserId, userId)
+}
+
+func (t *tracedService) GetUserOrganisations(ctx context.Context, userId uuid.UUID) (data *OrgsData, err error) {
+	ctx, span := startSpan(ctx, "GetUserOrganisations", userAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetUserOrganisations(ctx, userId)
+}
+
+func (t *tracedService) GetUserOrganisationById(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) (data *OrgData, err error) {
+	ctx, span := startSpan(ctx, "GetUserOrganisationById", userAttr(userId), orgAttr(orgId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetUserOrganisationById(ctx, userId, orgId)
+}
+
+func (t *tracedService) CreateOrganisation(ctx context.Context, userId uuid.UUID, param CreateOrgParam) (data *OrgData, err error) {
+	ctx, span := startSpan(ctx, "CreateOrganisation", userAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.CreateOrganisation(ctx, userId, param)
+}
+
+func (t *tracedService) AddUserToOrganisation(ctx context.Context, orgId uuid.UUID, userId uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "AddUserToOrganisation", orgAttr(orgId), targetUserAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.AddUserToOrganisation(ctx, orgId, userId)
+}
+
+func (t *tracedService) UpdateOrganisation(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, param UpdateOrgParam) (data *OrgData, err error) {
+	ctx, span := startSpan(ctx, "UpdateOrganisation", userAttr(userId), orgAttr(orgId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.UpdateOrganisation(ctx, userId, orgId, param)
+}
+
+func (t *tracedService) RemoveUserFromOrganisation(ctx context.Context, authUserId uuid.UUID, orgId uuid.UUID, userId uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "RemoveUserFromOrganisation", userAttr(authUserId), orgAttr(orgId), targetUserAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.RemoveUserFromOrganisation(ctx, authUserId, orgId, userId)
+}
+
+func (t *tracedService) CreateWebhook(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, param CreateWebhookParam) (data *WebhookData, err error) {
+	ctx, span := startSpan(ctx, "CreateWebhook", userAttr(userId), orgAttr(orgId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.CreateWebhook(ctx, userId, orgId, param)
+}
+
+func (t *tracedService) GetWebhooks(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) (data *WebhooksData, err error) {
+	ctx, span := startSpan(ctx, "GetWebhooks", userAttr(userId), orgAttr(orgId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetWebhooks(ctx, userId, orgId)
+}
+
+func (t *tracedService) DeleteWebhook(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, webhookId uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "DeleteWebhook", userAttr(userId), orgAttr(orgId), attribute.String("webhook.id", webhookId.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.DeleteWebhook(ctx, userId, orgId, webhookId)
+}
+
+func (t *tracedService) GetWebhookDeliveries(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, webhookId uuid.UUID) (data *DeliveriesData, err error) {
+	ctx, span := startSpan(ctx, "GetWebhookDeliveries", userAttr(userId), orgAttr(orgId), attribute.String("webhook.id", webhookId.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetWebhookDeliveries(ctx, userId, orgId, webhookId)
+}
+
+func (t *tracedService) RedeliverWebhook(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, webhookId uuid.UUID, deliveryId uuid.UUID) (data *DeliveryData, err error) {
+	ctx, span := startSpan(ctx, "RedeliverWebhook", userAttr(userId), orgAttr(orgId), attribute.String("webhook.id", webhookId.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.RedeliverWebhook(ctx, userId, orgId, webhookId, deliveryId)
+}
+
+func (t *tracedService) GetLatestOrganisationEventId(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) (id int64, err error) {
+	ctx, span := startSpan(ctx, "GetLatestOrganisationEventId", userAttr(userId), orgAttr(orgId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetLatestOrganisationEventId(ctx, userId, orgId)
+}
+
+func (t *tracedService) GetOrganisationEvents(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, afterId int64) (events []OrgEventData, err error) {
+	ctx, span := startSpan(ctx, "GetOrganisationEvents", userAttr(userId), orgAttr(orgId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetOrganisationEvents(ctx, userId, orgId, afterId)
+}
+
+func (t *tracedService) GetAccount(ctx context.Context, userId uuid.UUID) (data *AccountData, err error) {
+	ctx, span := startSpan(ctx, "GetAccount", userAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetAccount(ctx, userId)
+}
+
+func (t *tracedService) GetAccountByEmail(ctx context.Context, email string) (data *AccountData, err error) {
+	ctx, span := startSpan(ctx, "GetAccountByEmail")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetAccountByEmail(ctx, email)
+}
+
+func (t *tracedService) SearchAccounts(ctx context.Context, actorId uuid.UUID, param SearchParam) (data *AccountsData, err error) {
+	ctx, span := startSpan(ctx, "SearchAccounts", userAttr(actorId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.SearchAccounts(ctx, actorId, param)
+}
+
+func (t *tracedService) SearchOrganisations(ctx context.Context, actorId uuid.UUID, param SearchParam) (data *OrgsData, err error) {
+	ctx, span := startSpan(ctx, "SearchOrganisations", userAttr(actorId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.SearchOrganisations(ctx, actorId, param)
+}
+
+func (t *tracedService) GetOrganisationMembers(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID) (data *MemberAccountsData, err error) {
+	ctx, span := startSpan(ctx, "GetOrganisationMembers", userAttr(actorId), orgAttr(orgId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetOrganisationMembers(ctx, actorId, orgId)
+}
+
+func (t *tracedService) ResetPassword(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, password string) (err error) {
+	ctx, span := startSpan(ctx, "ResetPassword", userAttr(actorId), targetUserAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.ResetPassword(ctx, actorId, userId, password)
+}
+
+func (t *tracedService) SuspendAccount(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, reason string) (err error) {
+	ctx, span := startSpan(ctx, "SuspendAccount", userAttr(actorId), targetUserAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.SuspendAccount(ctx, actorId, userId, reason)
+}
+
+func (t *tracedService) ReactivateAccount(ctx context.Context, actorId uuid.UUID, userId uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "ReactivateAccount", userAttr(actorId), targetUserAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.ReactivateAccount(ctx, actorId, userId)
+}
+
+func (t *tracedService) SetSuperadmin(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, superadmin bool) (err error) {
+	ctx, span := startSpan(ctx, "SetSuperadmin", userAttr(actorId), targetUserAttr(userId), attribute.Bool("superadmin", superadmin))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.SetSuperadmin(ctx, actorId, userId, superadmin)
+}
+
+func (t *tracedService) LogoutUser(ctx context.Context, actorId uuid.UUID, userId uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "LogoutUser", userAttr(actorId), targetUserAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.LogoutUser(ctx, actorId, userId)
+}
+
+func (t *tracedService) SetMemberRole(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID, userId uuid.UUID, role string) (err error) {
+	ctx, span := startSpan(ctx, "SetMemberRole", userAttr(actorId), orgAttr(orgId), targetUserAttr(userId), attribute.String("role", role))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.SetMemberRole(ctx, actorId, orgId, userId, role)
+}
+
+func (t *tracedService) CreateAccount(ctx context.Context, actorId uuid.UUID, param RegisterParams) (data *AuthData, err error) {
+	ctx, span := startSpan(ctx, "CreateAccount", userAttr(actorId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.CreateAccount(ctx, actorId, param)
+}
+
+func (t *tracedService) CreateOrganisationFor(ctx context.Context, actorId uuid.UUID, ownerId uuid.UUID, param CreateOrgParam) (data *OrgData, err error) {
+	ctx, span := startSpan(ctx, "CreateOrganisationFor", userAttr(actorId), targetUserAttr(ownerId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.CreateOrganisationFor(ctx, actorId, ownerId, param)
+}
+
+func (t *tracedService) AddMember(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID, userId uuid.UUID, role string) (err error) {
+	ctx, span := startSpan(ctx, "AddMember", userAttr(actorId), orgAttr(orgId), targetUserAttr(userId), attribute.String("role", role))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.AddMember(ctx, actorId, orgId, userId, role)
+}
+
+func (t *tracedService) RemoveMember(ctx context.Context, actorId uuid.UUID, orgId uuid.UUID, userId uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "RemoveMember", userAttr(actorId), orgAttr(orgId), targetUserAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.RemoveMember(ctx, actorId, orgId, userId)
+}
+
+func (t *tracedService) ExportUser(ctx context.Context, actorId uuid.UUID, userId uuid.UUID) (data *UserExportData, err error) {
+	ctx, span := startSpan(ctx, "ExportUser", userAttr(actorId), targetUserAttr(userId))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.ExportUser(ctx, actorId, userId)
+}
+
+func (t *tracedService) GetAuditLog(ctx context.Context, param AuditLogParam) (data *AuditLogData, err error) {
+	ctx, span := startSpan(ctx, "GetAuditLog")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetAuditLog(ctx, param)
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/mock"
+)
+
+// conflictingRepo fails adding members in a transaction with a
+// serialization failure until conflicts runs out
+type conflictingRepo struct {
+	db.RepoQuerier
+	conflicts *int
+	attempts  *int
+}
+
+func (r conflictingRepo) WithTx(tx pgx.Tx) db.RepoQuerier {
+	return conflictingRepo{RepoQuerier: r.RepoQuerier.WithTx(tx), conflicts: r.conflicts, attempts: r.attempts}
+}
+
+func (r conflictingRepo) UserAddOrg(ctx context.Context, arg db.UserAddOrgParams) error {
+	*r.attempts++
+	if *r.conflicts > 0 {
+		*r.conflicts--
+		return db.ConvertError(&pgconn.PgError{Code: pgerrcode.SerializationFailure, Message: "could not serialize access"})
+	}
+	return r.RepoQuerier.UserAddOrg(ctx, arg)
+}
+
+// isolationRepo records the isolation level of the transactions begun on it
+type isolationRepo struct {
+	db.RepoQuerier
+	levels *[]pgx.TxIsoLevel
+}
+
+func (r isolationRepo) GetDB() db.Db {
+	return isolationDb{Db: r.RepoQuerier.GetDB(), levels: r.levels}
+}
+
+type isolationDb struct {
+	db.Db
+	levels *[]pgx.TxIsoLevel
+}
+
+func (d isolationDb) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	*d.levels = append(*d.levels, opts.IsoLevel)
+	return d.Db.BeginTx(ctx, opts)
+}
+
+func TestSerializationRetries(t *testing.T) {
+	ctx := context.Background()
+	var conflicts, attempts int
+	repo := conflictingRepo{RepoQuerier: mock.NewMockRepo(), conflicts: &conflicts, attempts: &attempts}
+	svc := New(repo, testTokens)
+
+	t.Run("Test register is retried", func(t *testing.T) {
+		conflicts, attempts = maxTxAttempts-1, 0
+		data, err := svc.Register(ctx, RegisterParams{Email: "retried@example.com", FirstName: "Re", LastName: "Tried", Password: "password"})
+		if err != nil {
+			t.Fatalf("expected register to succeed on the last attempt, got %v", err)
+		}
+		if attempts != maxTxAttempts {
+			t.Errorf("expected %d attempts, got %d", maxTxAttempts, attempts)
+		}
+
+		orgs, err := repo.OrgAllWhereUser(ctx, uuid.MustParse(data.User.Id))
+		if err != nil || len(orgs) != 1 {
+			t.Errorf("expected one organisation, got %d, %v", len(orgs), err)
+		}
+	})
+
+	t.Run("Test register gives up", func(t *testing.T) {
+		conflicts, attempts = maxTxAttempts, 0
+		_, err := svc.Register(ctx, RegisterParams{Email: "gave.up@example.com", FirstName: "Gave", LastName: "Up", Password: "password"})
+		if !errors.Is(err, db.ErrSerialization) {
+			t.Fatalf("expected a serialization error, got %v", err)
+		}
+		if attempts != maxTxAttempts {
+			t.Errorf("expected %d attempts, got %d", maxTxAttempts, attempts)
+		}
+		if _, err := repo.UserWhereEmail(ctx, "gave.up@example.com"); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("expected the user to be rolled back, got %v", err)
+		}
+	})
+
+	t.Run("Test create organisation is retried", func(t *testing.T) {
+		user, err := repo.UserWhereEmail(ctx, "retried@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conflicts, attempts = 1, 0
+		if _, err := svc.CreateOrganisation(ctx, user.ID, CreateOrgParam{Name: "Retried"}); err != nil {
+			t.Fatalf("expected create organisation to succeed, got %v", err)
+		}
+
+		orgs, err := repo.OrgAllWhereUser(ctx, user.ID)
+		if err != nil || len(orgs) != 2 {
+			t.Errorf("expected two organisations, got %d, %v", len(orgs), err)
+		}
+	})
+
+	t.Run("Test adding a member is retried", func(t *testing.T) {
+		owner, err := repo.UserWhereEmail(ctx, "retried@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		orgs, err := repo.OrgAllWhereUser(ctx, owner.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		member, err := svc.Register(ctx, RegisterParams{Email: "member@example.com", FirstName: "Mem", LastName: "Ber", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conflicts, attempts = 1, 0
+		if err := svc.AddMember(ctx, uuid.Nil, orgs[0].ID, uuid.MustParse(member.User.Id), RoleMember); err != nil {
+			t.Fatalf("expected adding the member to succeed, got %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("expected 2 attempts, got %d", attempts)
+		}
+	})
+}
+
+func TestRetriedTransactionsAreSerializable(t *testing.T) {
+	ctx := context.Background()
+	var levels []pgx.TxIsoLevel
+	svc := New(isolationRepo{RepoQuerier: mock.NewMockRepo(), levels: &levels}, testTokens)
+
+	if _, err := svc.Register(ctx, RegisterParams{Email: "serial@example.com", FirstName: "Se", LastName: "Rial", Password: "password"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(levels) != 1 || levels[0] != pgx.Serializable {
+		t.Fatalf("expected register to begin one serializable transaction, got %v", levels)
+	}
+}
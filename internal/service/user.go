@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -26,9 +27,12 @@ func (s *service) GetUser(ctx context.Context, authUserId uuid.UUID, userId uuid
 		})
 	}
 
-	if err != nil {
+	if errors.Is(err, db.ErrNotFound) {
 		return nil, app.ApiErrorFrom(fmt.Errorf("error getting user from db: %w", app.ErrUserNotFound))
 	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting user from db: %w", err)
+	}
 
 	return &UserData{
 		Id:        user.ID.String(),
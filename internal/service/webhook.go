@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// number of deliveries returned in a webhook's delivery history
+const deliveryHistoryLimit = 50
+
+func (s *service) CreateWebhook(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, param CreateWebhookParam) (*WebhookData, error) {
+	if err := s.requireOrgAdmin(ctx, userId, orgId); err != nil {
+		return nil, err
+	}
+
+	// generate a secret if the admin did not provide one,
+	// it is only ever returned in this response
+	secret := param.Secret
+	if len(secret) == 0 {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("error generating webhook secret: %w", err)
+		}
+		secret = "whsec_" + hex.EncodeToString(buf)
+	}
+
+	endpoint, err := s.repo.WebhookInsert(ctx, db.WebhookInsertParams{
+		OrgID:  orgId,
+		Url:    param.Url,
+		Secret: secret,
+		Events: param.Events,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook: %w", err)
+	}
+
+	data := webhookData(endpoint)
+	data.Secret = endpoint.Secret
+
+	return &data, nil
+}
+
+func (s *service) GetWebhooks(ctx context.Context, userId uuid.UUID, orgId uuid.UUID) (*WebhooksData, error) {
+	if err := s.requireOrgAdmin(ctx, userId, orgId); err != nil {
+		return nil, err
+	}
+
+	endpoints, err := s.repo.WebhookAllWhereOrg(ctx, orgId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding webhooks: %w", err)
+	}
+
+	resp := &WebhooksData{Webhooks: []WebhookData{}}
+	for _, endpoint := range endpoints {
+		resp.Webhooks = append(resp.Webhooks, webhookData(endpoint))
+	}
+
+	return resp, nil
+}
+
+func (s *service) DeleteWebhook(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, webhookId uuid.UUID) error {
+	if err := s.requireOrgAdmin(ctx, userId, orgId); err != nil {
+		return err
+	}
+
+	deleted, err := s.repo.WebhookDelete(ctx, db.WebhookDeleteParams{
+		ID:    webhookId,
+		OrgID: orgId,
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %w", err)
+	}
+
+	if deleted == 0 {
+		return app.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (s *service) GetWebhookDeliveries(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, webhookId uuid.UUID) (*DeliveriesData, error) {
+	if err := s.requireOrgAdmin(ctx, userId, orgId); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.WebhookWhereOrg(ctx, db.WebhookWhereOrgParams{
+		ID:    webhookId,
+		OrgID: orgId,
+	}); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, app.ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("error finding webhook: %w", err)
+	}
+
+	deliveries, err := s.repo.DeliveryAllWhereEndpoint(ctx, db.DeliveryAllWhereEndpointParams{
+		EndpointID: webhookId,
+		Limit:      deliveryHistoryLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error finding webhook deliveries: %w", err)
+	}
+
+	resp := &DeliveriesData{Deliveries: []DeliveryData{}}
+	for _, delivery := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, deliveryData(delivery))
+	}
+
+	return resp, nil
+}
+
+func (s *service) RedeliverWebhook(ctx context.Context, userId uuid.UUID, orgId uuid.UUID, webhookId uuid.UUID, deliveryId uuid.UUID) (*DeliveryData, error) {
+	if err := s.requireOrgAdmin(ctx, userId, orgId); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.WebhookWhereOrg(ctx, db.WebhookWhereOrgParams{
+		ID:    webhookId,
+		OrgID: orgId,
+	}); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, app.ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("error finding webhook: %w", err)
+	}
+
+	// redelivering queues a copy of the delivery so the
+	// history of the original attempts is kept
+	delivery, err := s.repo.DeliveryRedeliver(ctx, db.DeliveryRedeliverParams{
+		ID:         deliveryId,
+		EndpointID: webhookId,
+	})
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, app.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error redelivering webhook delivery: %w", err)
+	}
+
+	data := deliveryData(delivery)
+	return &data, nil
+}
+
+func webhookData(endpoint db.WebhookEndpoint) WebhookData {
+	return WebhookData{
+		Id:        endpoint.ID.String(),
+		Url:       endpoint.Url,
+		Events:    endpoint.Events,
+		Active:    endpoint.Active,
+		CreatedAt: endpoint.CreatedAt.Time,
+	}
+}
+
+func deliveryData(delivery db.WebhookDelivery) DeliveryData {
+	return DeliveryData{
+		Id:             delivery.ID.String(),
+		EventId:        delivery.EventID.String(),
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode.Int32,
+		LastError:      delivery.LastError.String,
+		NextAttemptAt:  timePtr(delivery.NextAttemptAt),
+		CreatedAt:      delivery.CreatedAt.Time,
+		DeliveredAt:    timePtr(delivery.DeliveredAt),
+	}
+}
+
+func timePtr(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,202 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	_ "modernc.org/sqlite"
+)
+
+var (
+	errNoSQL     = errors.New("sqlite: queries go through the repository")
+	errNestedTx  = errors.New("sqlite: nested transactions are not supported")
+	errTxAborted = &pgconn.PgError{Code: pgerrcode.InFailedSQLTransaction, Message: "sqlite: current transaction is aborted, commands ignored until end of transaction block"}
+)
+
+// DB is a sqlite database that stands in for the postgres pool. it
+// hands out transactions for the repository, which runs the queries
+type DB struct {
+	sql *sql.DB
+
+	mu        sync.Mutex
+	listeners map[*func(orgId uuid.UUID)]struct{}
+}
+
+var _ db.Db = (*DB)(nil)
+
+// Open opens the database file at path, creating it if needed, and
+// migrates it to the latest schema
+func Open(ctx context.Context, path string) (*DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	// transactions take the write lock when they begin so two
+	// of them can't deadlock upgrading their read locks
+	params.Set("_txlock", "immediate")
+
+	conn, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+
+	if err := migrate(ctx, conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error migrating %s: %w", path, err)
+	}
+
+	return &DB{sql: conn, listeners: make(map[*func(orgId uuid.UUID)]struct{})}, nil
+}
+
+func (d *DB) Close() error {
+	return d.sql.Close()
+}
+
+func (d *DB) Ping(ctx context.Context) error {
+	return d.sql.PingContext(ctx)
+}
+
+// Listen calls fn with the organisation of every org event committed
+// by this process, sqlite has nothing like LISTEN/NOTIFY
+func (d *DB) Listen(fn func(orgId uuid.UUID)) (stop func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := &fn
+	d.listeners[key] = struct{}{}
+
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.listeners, key)
+	}
+}
+
+func (d *DB) notify(orgIds ...uuid.UUID) {
+	d.mu.Lock()
+	listeners := make([]func(uuid.UUID), 0, len(d.listeners))
+	for fn := range d.listeners {
+		listeners = append(listeners, *fn)
+	}
+	d.mu.Unlock()
+
+	for _, orgId := range orgIds {
+		for _, fn := range listeners {
+			fn(orgId)
+		}
+	}
+}
+
+func (d *DB) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := d.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &sqliteTx{db: d, tx: tx}, nil
+}
+
+// BeginTx ignores opts, sqlite transactions are always serializable
+func (d *DB) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	return d.Begin(ctx)
+}
+
+func (d *DB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errNoSQL
+}
+
+func (d *DB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, errNoSQL
+}
+
+func (d *DB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return errRow{errNoSQL}
+}
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(dest ...any) error { return r.err }
+
+// sqliteTx adapts a sql transaction to pgx.Tx. sqlite only undoes the
+// failing statement when a constraint is broken, the transaction is
+// aborted instead so it behaves like it does in postgres
+type sqliteTx struct {
+	pgx.Tx
+
+	db *DB
+	tx *sql.Tx
+
+	mu      sync.Mutex
+	notify  []uuid.UUID
+	aborted bool
+}
+
+func (tx *sqliteTx) usable() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.aborted {
+		return errTxAborted
+	}
+	return nil
+}
+
+// fail aborts the transaction on errors postgres aborts it for
+func (tx *sqliteTx) fail(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		tx.mu.Lock()
+		tx.aborted = true
+		tx.mu.Unlock()
+	}
+	return err
+}
+
+func (tx *sqliteTx) Commit(ctx context.Context) error {
+	tx.mu.Lock()
+	aborted := tx.aborted
+	notify := tx.notify
+	tx.mu.Unlock()
+
+	if aborted {
+		if err := tx.tx.Rollback(); err != nil {
+			return convertError(err)
+		}
+		return pgx.ErrTxCommitRollback
+	}
+
+	if err := tx.tx.Commit(); err != nil {
+		return convertError(err)
+	}
+
+	tx.db.notify(notify...)
+	return nil
+}
+
+func (tx *sqliteTx) Rollback(ctx context.Context) error {
+	return convertError(tx.tx.Rollback())
+}
+
+func (tx *sqliteTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return nil, errNestedTx
+}
+
+func (tx *sqliteTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errNoSQL
+}
+
+func (tx *sqliteTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, errNoSQL
+}
+
+func (tx *sqliteTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return errRow{errNoSQL}
+}
@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// names postgres gives the unique constraints, sqlite
+// only reports the columns that were duplicated
+var uniqueConstraints = map[string]string{
+	"users.email": "users_email_key",
+	"user_organisations.user_id, user_organisations.org_id": "user_organisations_pkey",
+	"rate_limits.key": "rate_limits_pkey",
+}
+
+// convertError turns sqlite errors into the errors the postgres
+// repository returns so callers can't tell the two apart
+func convertError(err error) error {
+	return db.ConvertError(driverError(err))
+}
+
+// driverError turns a sqlite error into the error pgx returns for it
+func driverError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+
+	if errors.Is(err, sql.ErrTxDone) {
+		return pgx.ErrTxClosed
+	}
+
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	// messages look like "constraint failed: UNIQUE constraint failed: users.email (2067)"
+	const failed = "failed: "
+	detail := sqliteErr.Error()
+	if i := strings.LastIndex(detail, failed); i >= 0 {
+		detail = detail[i+len(failed):]
+	}
+	detail, _, _ = strings.Cut(detail, " (")
+
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return &pgconn.PgError{Code: pgerrcode.UniqueViolation, ConstraintName: uniqueConstraints[detail], Message: sqliteErr.Error()}
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return &pgconn.PgError{Code: pgerrcode.ForeignKeyViolation, Message: sqliteErr.Error()}
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		return &pgconn.PgError{Code: pgerrcode.NotNullViolation, Message: sqliteErr.Error()}
+	case sqlite3.SQLITE_CONSTRAINT_CHECK:
+		// the checks named <table>_<column>_length stand in for varchar lengths
+		if strings.HasSuffix(detail, "_length") {
+			return &pgconn.PgError{Code: pgerrcode.StringDataRightTruncationDataException, Message: sqliteErr.Error()}
+		}
+		return &pgconn.PgError{Code: pgerrcode.CheckViolation, ConstraintName: detail, Message: sqliteErr.Error()}
+	}
+
+	return err
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/michaelcosj/hng-task-two/database"
+)
+
+// migrate applies the migrations newer than the database, each in
+// its own transaction. the version is kept in a table named like
+// the one tern uses
+func migrate(ctx context.Context, conn *sql.DB) error {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&current); err != nil {
+		return err
+	}
+
+	all, err := database.ReadMigrations(database.SQLiteMigrations, "sqlite")
+	if err != nil {
+		return err
+	}
+
+	for _, m := range all {
+		if m.Version <= current {
+			continue
+		}
+
+		if err := apply(ctx, conn, m); err != nil {
+			return fmt.Errorf("error applying %s: %w", m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, conn *sql.DB, m database.Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(m.Up) != "" {
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_version`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_version (version) VALUES (?)`, m.Version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
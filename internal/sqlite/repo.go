@@ -0,0 +1,773 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// Repo is a db.RepoQuerier backed by sqlite. it returns the same
+// results and errors as the postgres repository
+type Repo struct {
+	db *DB
+	// nil outside a transaction
+	tx *sqliteTx
+}
+
+var _ db.RepoQuerier = (*Repo)(nil)
+
+func NewRepo(db *DB) *Repo {
+	return &Repo{db: db}
+}
+
+// WithTx returns a repo that works inside tx, which must have been
+// begun on the repo's database
+func (r *Repo) WithTx(tx pgx.Tx) db.RepoQuerier {
+	sqliteTx, ok := tx.(*sqliteTx)
+	if !ok || sqliteTx.db != r.db {
+		panic("sqlite: transaction was not begun on the repo's database")
+	}
+	return &Repo{db: r.db, tx: sqliteTx}
+}
+
+func (r *Repo) GetDB() db.Db {
+	return r.db
+}
+
+// DB returns the database, to listen for org events
+func (r *Repo) DB() *DB {
+	return r.db
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (r *Repo) conn() (conn, error) {
+	if r.tx == nil {
+		return r.db.sql, nil
+	}
+	if err := r.tx.usable(); err != nil {
+		return nil, err
+	}
+	return r.tx.tx, nil
+}
+
+func (r *Repo) fail(err error) error {
+	err = convertError(err)
+	if r.tx != nil {
+		return r.tx.fail(err)
+	}
+	return err
+}
+
+// one scans the single row query returns, db.ErrNotFound if there is none
+func (r *Repo) one(ctx context.Context, query string, args []any, scan func(row scanner) error) error {
+	found := false
+	err := r.many(ctx, query, args, func(row scanner) error {
+		if found {
+			return nil
+		}
+		found = true
+		return scan(row)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return convertError(pgx.ErrNoRows)
+	}
+	return nil
+}
+
+// many scans each row query returns. rows are read through to the end
+// since sqlite only reports some constraint errors after the last row
+func (r *Repo) many(ctx context.Context, query string, args []any, scan func(row scanner) error) error {
+	c, err := r.conn()
+	if err != nil {
+		return err
+	}
+
+	rows, err := c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return r.fail(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return r.fail(err)
+		}
+	}
+
+	return r.fail(rows.Err())
+}
+
+func (r *Repo) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	c, err := r.conn()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := c.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, r.fail(err)
+	}
+
+	affected, err := result.RowsAffected()
+	return affected, r.fail(err)
+}
+
+// notify tells listeners about an org event once it is committed
+func (r *Repo) notify(orgId uuid.UUID) {
+	if r.tx == nil {
+		r.db.notify(orgId)
+		return
+	}
+
+	r.tx.mu.Lock()
+	defer r.tx.mu.Unlock()
+	r.tx.notify = append(r.tx.notify, orgId)
+}
+
+// ------ Values ------ //
+
+// times are kept as unix microseconds, the precision postgres has
+func micros(t pgtype.Timestamptz) any {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time.UnixMicro()
+}
+
+func nowMicros() int64 {
+	return time.Now().UnixMicro()
+}
+
+type timestamp struct{ dst *pgtype.Timestamptz }
+
+func (t timestamp) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*t.dst = pgtype.Timestamptz{}
+	case int64:
+		*t.dst = pgtype.Timestamptz{Time: time.UnixMicro(v), Valid: true}
+	default:
+		return fmt.Errorf("sqlite: cannot scan %T into a timestamp", src)
+	}
+	return nil
+}
+
+// stringList keeps a text[] column as a json array
+type stringList struct{ dst *[]string }
+
+func (l stringList) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("sqlite: cannot scan %T into a list", src)
+	}
+	return json.Unmarshal(raw, l.dst)
+}
+
+func listValue(list []string) (driver.Value, error) {
+	if list == nil {
+		list = []string{}
+	}
+	value, err := json.Marshal(list)
+	return string(value), err
+}
+
+// ------ Rows ------ //
+
+const (
+	userColumns         = "id, email, first_name, last_name, password, phone, is_superadmin, token_version, status, status_reason, status_changed_at"
+	orgColumns          = "id, name, description"
+	webhookColumns      = "id, org_id, url, secret, events, active, created_at"
+	deliveryColumns     = "id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at"
+	jobColumns          = "id, kind, payload, status, attempts, max_attempts, run_at, locked_until, last_error, created_at, finished_at"
+	orgEventColumns     = "id, org_id, type, data, created_at"
+	auditColumns        = "id, actor_id, action, target_id, data, created_at"
+	prefixedOrgColumns  = "org.id, org.name, org.description"
+	prefixedUserColumns = "u.id, u.email, u.first_name, u.last_name, u.password, u.phone, u.is_superadmin, u.token_version, u.status, u.status_reason, u.status_changed_at"
+)
+
+func scanUser(row scanner, u *db.User) error {
+	return row.Scan(
+		&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.Password, &u.Phone,
+		&u.IsSuperadmin, &u.TokenVersion, &u.Status, &u.StatusReason, timestamp{&u.StatusChangedAt},
+	)
+}
+
+func scanOrg(row scanner, o *db.Organisation) error {
+	return row.Scan(&o.ID, &o.Name, &o.Description)
+}
+
+func scanWebhook(row scanner, h *db.WebhookEndpoint) error {
+	return row.Scan(&h.ID, &h.OrgID, &h.Url, &h.Secret, stringList{&h.Events}, &h.Active, timestamp{&h.CreatedAt})
+}
+
+func deliveryFields(d *db.WebhookDelivery) []any {
+	return []any{
+		&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		timestamp{&d.NextAttemptAt}, &d.LastStatusCode, &d.LastError, timestamp{&d.CreatedAt}, timestamp{&d.DeliveredAt},
+	}
+}
+
+func scanDelivery(row scanner, d *db.WebhookDelivery) error {
+	return row.Scan(deliveryFields(d)...)
+}
+
+func scanJob(row scanner, j *db.Job) error {
+	return row.Scan(
+		&j.ID, &j.Kind, &j.Payload, &j.Status, &j.Attempts, &j.MaxAttempts, timestamp{&j.RunAt},
+		timestamp{&j.LockedUntil}, &j.LastError, timestamp{&j.CreatedAt}, timestamp{&j.FinishedAt},
+	)
+}
+
+func scanOrgEvent(row scanner, e *db.OrgEvent) error {
+	return row.Scan(&e.ID, &e.OrgID, &e.Type, &e.Data, timestamp{&e.CreatedAt})
+}
+
+func scanAudit(row scanner, a *db.AuditLog) error {
+	return row.Scan(&a.ID, &a.ActorID, &a.Action, &a.TargetID, &a.Data, timestamp{&a.CreatedAt})
+}
+
+// ------ Users ------ //
+
+func (r *Repo) UserInsert(ctx context.Context, arg db.UserInsertParams) (user db.User, err error) {
+	err = r.one(ctx, `
+		INSERT INTO users (id, email, first_name, last_name, password, phone)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+userColumns,
+		[]any{uuid.New(), arg.Email, arg.FirstName, arg.LastName, arg.Password, arg.Phone},
+		func(row scanner) error { return scanUser(row, &user) })
+	return user, err
+}
+
+func (r *Repo) UserWhereEmail(ctx context.Context, email string) (user db.User, err error) {
+	err = r.one(ctx, `SELECT `+userColumns+` FROM users WHERE email = ? LIMIT 1`,
+		[]any{email},
+		func(row scanner) error { return scanUser(row, &user) })
+	return user, err
+}
+
+func (r *Repo) UserWhereId(ctx context.Context, id uuid.UUID) (user db.User, err error) {
+	err = r.one(ctx, `SELECT `+userColumns+` FROM users WHERE id = ? LIMIT 1`,
+		[]any{id},
+		func(row scanner) error { return scanUser(row, &user) })
+	return user, err
+}
+
+func (r *Repo) FindUserInOrgs(ctx context.Context, arg db.FindUserInOrgsParams) (user db.User, err error) {
+	err = r.one(ctx, `
+		SELECT `+prefixedUserColumns+` FROM users auth_user
+		JOIN user_organisations u_org ON u_org.user_id = auth_user.id
+		JOIN organisations org ON u_org.org_id = org.id
+		JOIN user_organisations org_users ON org_users.org_id = org.id
+		JOIN users u ON u.id = ?1 AND u.id = org_users.user_id
+		WHERE auth_user.id = ?2`,
+		[]any{arg.FindUser, arg.AuthUser},
+		func(row scanner) error { return scanUser(row, &user) })
+	return user, err
+}
+
+// UserSearch matches case insensitively like ILIKE, sqlite's LIKE
+// already ignores the case of ascii letters
+func (r *Repo) UserSearch(ctx context.Context, arg db.UserSearchParams) (users []db.User, err error) {
+	err = r.many(ctx, `
+		SELECT `+userColumns+` FROM users
+		WHERE ?1 = ''
+			OR email LIKE '%' || ?1 || '%'
+			OR first_name || ' ' || last_name LIKE '%' || ?1 || '%'
+		ORDER BY email
+		LIMIT ?2 OFFSET ?3`,
+		[]any{arg.Query, arg.Limit, arg.Offset},
+		func(row scanner) error {
+			var user db.User
+			if err := scanUser(row, &user); err != nil {
+				return err
+			}
+			users = append(users, user)
+			return nil
+		})
+	return users, err
+}
+
+func (r *Repo) UserUpdatePassword(ctx context.Context, arg db.UserUpdatePasswordParams) (int64, error) {
+	return r.exec(ctx, `UPDATE users SET password = ?, token_version = token_version + 1 WHERE id = ?`, arg.Password, arg.ID)
+}
+
+func (r *Repo) UserSetStatus(ctx context.Context, arg db.UserSetStatusParams) (int64, error) {
+	return r.exec(ctx, `
+		UPDATE users SET status = ?1, status_reason = ?2, status_changed_at = ?3,
+			token_version = CASE WHEN ?1 = 'active' THEN token_version ELSE token_version + 1 END
+		WHERE id = ?4`,
+		arg.Status, arg.StatusReason, nowMicros(), arg.ID)
+}
+
+func (r *Repo) UserSetSuperadmin(ctx context.Context, arg db.UserSetSuperadminParams) (int64, error) {
+	return r.exec(ctx, `UPDATE users SET is_superadmin = ? WHERE id = ?`, arg.IsSuperadmin, arg.ID)
+}
+
+func (r *Repo) UserRevokeTokens(ctx context.Context, id uuid.UUID) (int64, error) {
+	return r.exec(ctx, `UPDATE users SET token_version = token_version + 1 WHERE id = ?`, id)
+}
+
+// ------ Organisations ------ //
+
+func (r *Repo) OrgInsert(ctx context.Context, arg db.OrgInsertParams) (org db.Organisation, err error) {
+	err = r.one(ctx, `INSERT INTO organisations (id, name, description) VALUES (?, ?, ?) RETURNING `+orgColumns,
+		[]any{uuid.New(), arg.Name, arg.Description},
+		func(row scanner) error { return scanOrg(row, &org) })
+	return org, err
+}
+
+func (r *Repo) OrgUpdate(ctx context.Context, arg db.OrgUpdateParams) (org db.Organisation, err error) {
+	err = r.one(ctx, `UPDATE organisations SET name = ?, description = ? WHERE id = ? RETURNING `+orgColumns,
+		[]any{arg.Name, arg.Description, arg.ID},
+		func(row scanner) error { return scanOrg(row, &org) })
+	return org, err
+}
+
+func (r *Repo) OrganisationWhereId(ctx context.Context, id uuid.UUID) (org db.Organisation, err error) {
+	err = r.one(ctx, `SELECT `+orgColumns+` FROM organisations WHERE id = ? LIMIT 1`,
+		[]any{id},
+		func(row scanner) error { return scanOrg(row, &org) })
+	return org, err
+}
+
+func (r *Repo) OrgWhereUser(ctx context.Context, arg db.OrgWhereUserParams) (org db.Organisation, err error) {
+	err = r.one(ctx, `
+		SELECT `+prefixedOrgColumns+` FROM user_organisations uo
+		JOIN organisations org ON uo.org_id = org.id
+		WHERE uo.user_id = ? AND uo.org_id = ? LIMIT 1`,
+		[]any{arg.UserID, arg.OrgID},
+		func(row scanner) error { return scanOrg(row, &org) })
+	return org, err
+}
+
+func (r *Repo) OrgAllWhereUser(ctx context.Context, userID uuid.UUID) (orgs []db.Organisation, err error) {
+	err = r.many(ctx, `
+		SELECT `+prefixedOrgColumns+` FROM user_organisations uo
+		JOIN organisations org ON uo.org_id = org.id
+		WHERE uo.user_id = ?`,
+		[]any{userID},
+		func(row scanner) error {
+			var org db.Organisation
+			if err := scanOrg(row, &org); err != nil {
+				return err
+			}
+			orgs = append(orgs, org)
+			return nil
+		})
+	return orgs, err
+}
+
+func (r *Repo) OrgSearch(ctx context.Context, arg db.OrgSearchParams) (orgs []db.Organisation, err error) {
+	err = r.many(ctx, `
+		SELECT `+orgColumns+` FROM organisations
+		WHERE ?1 = '' OR name LIKE '%' || ?1 || '%'
+		ORDER BY name, id
+		LIMIT ?2 OFFSET ?3`,
+		[]any{arg.Query, arg.Limit, arg.Offset},
+		func(row scanner) error {
+			var org db.Organisation
+			if err := scanOrg(row, &org); err != nil {
+				return err
+			}
+			orgs = append(orgs, org)
+			return nil
+		})
+	return orgs, err
+}
+
+// ------ Members ------ //
+
+func (r *Repo) UserAddOrg(ctx context.Context, arg db.UserAddOrgParams) error {
+	_, err := r.exec(ctx, `INSERT INTO user_organisations (user_id, org_id, role) VALUES (?, ?, ?)`,
+		arg.UserID, arg.OrgID, arg.Role)
+	return err
+}
+
+func (r *Repo) UserRemoveOrg(ctx context.Context, arg db.UserRemoveOrgParams) (int64, error) {
+	return r.exec(ctx, `DELETE FROM user_organisations WHERE user_id = ? AND org_id = ?`,
+		arg.UserID, arg.OrgID)
+}
+
+func (r *Repo) UserSetOrgRole(ctx context.Context, arg db.UserSetOrgRoleParams) (int64, error) {
+	return r.exec(ctx, `UPDATE user_organisations SET role = ? WHERE user_id = ? AND org_id = ?`,
+		arg.Role, arg.UserID, arg.OrgID)
+}
+
+func (r *Repo) OrgMembershipsWhereUser(ctx context.Context, userID uuid.UUID) (memberships []db.OrgMembershipsWhereUserRow, err error) {
+	err = r.many(ctx, `
+		SELECT `+prefixedOrgColumns+`, uo.role FROM user_organisations uo
+		JOIN organisations org ON uo.org_id = org.id
+		WHERE uo.user_id = ?
+		ORDER BY org.name`,
+		[]any{userID},
+		func(row scanner) error {
+			var m db.OrgMembershipsWhereUserRow
+			if err := row.Scan(&m.ID, &m.Name, &m.Description, &m.Role); err != nil {
+				return err
+			}
+			memberships = append(memberships, m)
+			return nil
+		})
+	return memberships, err
+}
+
+func (r *Repo) OrgMembersWhereOrg(ctx context.Context, orgID uuid.UUID) (members []db.OrgMembersWhereOrgRow, err error) {
+	err = r.many(ctx, `
+		SELECT `+prefixedUserColumns+`, uo.role FROM user_organisations uo
+		JOIN users u ON uo.user_id = u.id
+		WHERE uo.org_id = ?
+		ORDER BY u.email`,
+		[]any{orgID},
+		func(row scanner) error {
+			var m db.OrgMembersWhereOrgRow
+			if err := row.Scan(
+				&m.ID, &m.Email, &m.FirstName, &m.LastName, &m.Password, &m.Phone,
+				&m.IsSuperadmin, &m.TokenVersion, &m.Status, &m.StatusReason, timestamp{&m.StatusChangedAt}, &m.Role,
+			); err != nil {
+				return err
+			}
+			members = append(members, m)
+			return nil
+		})
+	return members, err
+}
+
+func (r *Repo) OrgMemberRole(ctx context.Context, arg db.OrgMemberRoleParams) (role string, err error) {
+	err = r.one(ctx, `SELECT role FROM user_organisations WHERE user_id = ? AND org_id = ? LIMIT 1`,
+		[]any{arg.UserID, arg.OrgID},
+		func(row scanner) error { return row.Scan(&role) })
+	return role, err
+}
+
+// ------ Webhooks ------ //
+
+func (r *Repo) WebhookInsert(ctx context.Context, arg db.WebhookInsertParams) (hook db.WebhookEndpoint, err error) {
+	events, err := listValue(arg.Events)
+	if err != nil {
+		return hook, err
+	}
+
+	err = r.one(ctx, `
+		INSERT INTO webhook_endpoints (id, org_id, url, secret, events, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+webhookColumns,
+		[]any{uuid.New(), arg.OrgID, arg.Url, arg.Secret, events, nowMicros()},
+		func(row scanner) error { return scanWebhook(row, &hook) })
+	return hook, err
+}
+
+func (r *Repo) webhooks(ctx context.Context, query string, args ...any) (hooks []db.WebhookEndpoint, err error) {
+	err = r.many(ctx, query, args, func(row scanner) error {
+		var hook db.WebhookEndpoint
+		if err := scanWebhook(row, &hook); err != nil {
+			return err
+		}
+		hooks = append(hooks, hook)
+		return nil
+	})
+	return hooks, err
+}
+
+func (r *Repo) WebhookAllWhereOrg(ctx context.Context, orgID uuid.UUID) ([]db.WebhookEndpoint, error) {
+	return r.webhooks(ctx, `SELECT `+webhookColumns+` FROM webhook_endpoints WHERE org_id = ? ORDER BY created_at, rowid`, orgID)
+}
+
+func (r *Repo) WebhookWhereOrg(ctx context.Context, arg db.WebhookWhereOrgParams) (hook db.WebhookEndpoint, err error) {
+	err = r.one(ctx, `SELECT `+webhookColumns+` FROM webhook_endpoints WHERE id = ? AND org_id = ? LIMIT 1`,
+		[]any{arg.ID, arg.OrgID},
+		func(row scanner) error { return scanWebhook(row, &hook) })
+	return hook, err
+}
+
+func (r *Repo) WebhookDelete(ctx context.Context, arg db.WebhookDeleteParams) (int64, error) {
+	return r.exec(ctx, `DELETE FROM webhook_endpoints WHERE id = ? AND org_id = ?`, arg.ID, arg.OrgID)
+}
+
+func (r *Repo) WebhookAllForEvent(ctx context.Context, arg db.WebhookAllForEventParams) ([]db.WebhookEndpoint, error) {
+	return r.webhooks(ctx, `
+		SELECT `+webhookColumns+` FROM webhook_endpoints
+		WHERE org_id = ? AND active AND EXISTS (SELECT 1 FROM json_each(events) WHERE value = ?)`,
+		arg.OrgID, arg.EventType)
+}
+
+// ------ Deliveries ------ //
+
+func (r *Repo) DeliveryInsert(ctx context.Context, arg db.DeliveryInsertParams) (delivery db.WebhookDelivery, err error) {
+	now := nowMicros()
+	err = r.one(ctx, `
+		INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING `+deliveryColumns,
+		[]any{uuid.New(), arg.EndpointID, arg.EventID, arg.EventType, arg.Payload, now, now},
+		func(row scanner) error { return scanDelivery(row, &delivery) })
+	return delivery, err
+}
+
+func (r *Repo) DeliveryRedeliver(ctx context.Context, arg db.DeliveryRedeliverParams) (delivery db.WebhookDelivery, err error) {
+	now := nowMicros()
+	err = r.one(ctx, `
+		INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, next_attempt_at, created_at)
+		SELECT ?, endpoint_id, event_id, event_type, payload, ?, ? FROM webhook_deliveries
+		WHERE webhook_deliveries.id = ? AND webhook_deliveries.endpoint_id = ?
+		RETURNING `+deliveryColumns,
+		[]any{uuid.New(), now, now, arg.ID, arg.EndpointID},
+		func(row scanner) error { return scanDelivery(row, &delivery) })
+	return delivery, err
+}
+
+// DeliveryClaimDue claims like the postgres query does. sqlite has a
+// single writer so the claim needs no row locks
+func (r *Repo) DeliveryClaimDue(ctx context.Context, arg db.DeliveryClaimDueParams) (rows []db.DeliveryClaimDueRow, err error) {
+	err = r.many(ctx, `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?
+		)
+		RETURNING `+deliveryColumns+`,
+			(SELECT url FROM webhook_endpoints e WHERE e.id = endpoint_id),
+			(SELECT secret FROM webhook_endpoints e WHERE e.id = endpoint_id)`,
+		[]any{micros(arg.LeaseUntil), nowMicros(), arg.BatchSize},
+		func(row scanner) error {
+			var d db.WebhookDelivery
+			var url, secret string
+			if err := row.Scan(append(deliveryFields(&d), &url, &secret)...); err != nil {
+				return err
+			}
+
+			rows = append(rows, db.DeliveryClaimDueRow{
+				ID:             d.ID,
+				EndpointID:     d.EndpointID,
+				EventID:        d.EventID,
+				EventType:      d.EventType,
+				Payload:        d.Payload,
+				Status:         d.Status,
+				Attempts:       d.Attempts,
+				NextAttemptAt:  d.NextAttemptAt,
+				LastStatusCode: d.LastStatusCode,
+				LastError:      d.LastError,
+				CreatedAt:      d.CreatedAt,
+				DeliveredAt:    d.DeliveredAt,
+				Url:            url,
+				Secret:         secret,
+			})
+			return nil
+		})
+	return rows, err
+}
+
+func (r *Repo) DeliveryUpdate(ctx context.Context, arg db.DeliveryUpdateParams) error {
+	_, err := r.exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, delivered_at = ?
+		WHERE id = ?`,
+		arg.Status, micros(arg.NextAttemptAt), arg.LastStatusCode, arg.LastError, micros(arg.DeliveredAt), arg.ID)
+	return err
+}
+
+func (r *Repo) DeliveryAllWhereEndpoint(ctx context.Context, arg db.DeliveryAllWhereEndpointParams) (deliveries []db.WebhookDelivery, err error) {
+	err = r.many(ctx, `
+		SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE endpoint_id = ?
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT ?`,
+		[]any{arg.EndpointID, arg.Limit},
+		func(row scanner) error {
+			var delivery db.WebhookDelivery
+			if err := scanDelivery(row, &delivery); err != nil {
+				return err
+			}
+			deliveries = append(deliveries, delivery)
+			return nil
+		})
+	return deliveries, err
+}
+
+// ------ Jobs ------ //
+
+func (r *Repo) JobInsert(ctx context.Context, arg db.JobInsertParams) (job db.Job, err error) {
+	now := nowMicros()
+	err = r.one(ctx, `
+		INSERT INTO jobs (id, kind, payload, max_attempts, run_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+jobColumns,
+		[]any{uuid.New(), arg.Kind, arg.Payload, arg.MaxAttempts, now, now},
+		func(row scanner) error { return scanJob(row, &job) })
+	return job, err
+}
+
+func (r *Repo) JobClaim(ctx context.Context, arg db.JobClaimParams) (jobs []db.Job, err error) {
+	now := nowMicros()
+	err = r.many(ctx, `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_until = ?
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE (status = 'pending' AND run_at <= ?)
+				OR (status = 'running' AND locked_until < ?)
+			ORDER BY run_at
+			LIMIT ?
+		)
+		RETURNING `+jobColumns,
+		[]any{micros(arg.LockedUntil), now, now, arg.BatchSize},
+		func(row scanner) error {
+			var job db.Job
+			if err := scanJob(row, &job); err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	return jobs, err
+}
+
+func (r *Repo) JobComplete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.exec(ctx, `
+		UPDATE jobs
+		SET status = 'completed', locked_until = NULL, last_error = NULL, finished_at = ?
+		WHERE id = ?`,
+		nowMicros(), id)
+	return err
+}
+
+func (r *Repo) JobRetry(ctx context.Context, arg db.JobRetryParams) error {
+	_, err := r.exec(ctx, `
+		UPDATE jobs
+		SET status = 'pending', locked_until = NULL, run_at = ?, last_error = ?
+		WHERE id = ?`,
+		micros(arg.RunAt), arg.LastError, arg.ID)
+	return err
+}
+
+func (r *Repo) JobKill(ctx context.Context, arg db.JobKillParams) error {
+	_, err := r.exec(ctx, `
+		UPDATE jobs
+		SET status = 'dead', locked_until = NULL, last_error = ?, finished_at = ?
+		WHERE id = ?`,
+		arg.LastError, nowMicros(), arg.ID)
+	return err
+}
+
+func (r *Repo) JobPurgeCompleted(ctx context.Context, finishedAt pgtype.Timestamptz) (int64, error) {
+	return r.exec(ctx, `DELETE FROM jobs WHERE status = 'completed' AND finished_at < ?`, micros(finishedAt))
+}
+
+// ------ Events ------ //
+
+func (r *Repo) OrgEventInsert(ctx context.Context, arg db.OrgEventInsertParams) (event db.OrgEvent, err error) {
+	err = r.one(ctx, `INSERT INTO org_events (org_id, type, data, created_at) VALUES (?, ?, ?, ?) RETURNING `+orgEventColumns,
+		[]any{arg.OrgID, arg.Type, arg.Data, nowMicros()},
+		func(row scanner) error { return scanOrgEvent(row, &event) })
+	if err != nil {
+		return event, err
+	}
+
+	r.notify(event.OrgID)
+	return event, nil
+}
+
+func (r *Repo) OrgEventsAfter(ctx context.Context, arg db.OrgEventsAfterParams) (events []db.OrgEvent, err error) {
+	err = r.many(ctx, `SELECT `+orgEventColumns+` FROM org_events WHERE org_id = ? AND id > ? ORDER BY id LIMIT ?`,
+		[]any{arg.OrgID, arg.ID, arg.Limit},
+		func(row scanner) error {
+			var event db.OrgEvent
+			if err := scanOrgEvent(row, &event); err != nil {
+				return err
+			}
+			events = append(events, event)
+			return nil
+		})
+	return events, err
+}
+
+func (r *Repo) OrgEventLatestId(ctx context.Context, orgID uuid.UUID) (latest int64, err error) {
+	err = r.one(ctx, `SELECT COALESCE(MAX(id), 0) FROM org_events WHERE org_id = ?`,
+		[]any{orgID},
+		func(row scanner) error { return row.Scan(&latest) })
+	return latest, err
+}
+
+func (r *Repo) OrgEventPurge(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error) {
+	return r.exec(ctx, `DELETE FROM org_events WHERE created_at < ?`, micros(createdAt))
+}
+
+// ------ Audit Log ------ //
+
+func (r *Repo) AuditInsert(ctx context.Context, arg db.AuditInsertParams) (entry db.AuditLog, err error) {
+	err = r.one(ctx, `INSERT INTO audit_log (actor_id, action, target_id, data, created_at) VALUES (?, ?, ?, ?, ?) RETURNING `+auditColumns,
+		[]any{arg.ActorID, arg.Action, arg.TargetID, arg.Data, nowMicros()},
+		func(row scanner) error { return scanAudit(row, &entry) })
+	return entry, err
+}
+
+func (r *Repo) AuditList(ctx context.Context, arg db.AuditListParams) (entries []db.AuditLog, err error) {
+	err = r.many(ctx, `
+		SELECT `+auditColumns+` FROM audit_log
+		WHERE (?1 = 0 OR id < ?1)
+			AND (?2 IS NULL OR target_id = ?2)
+		ORDER BY id DESC
+		LIMIT ?3`,
+		[]any{arg.Before, arg.TargetID, arg.Limit},
+		func(row scanner) error {
+			var entry db.AuditLog
+			if err := scanAudit(row, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	return entries, err
+}
+
+// ------ Rate Limits ------ //
+
+// RateLimitTake refills and takes from the bucket in one statement
+// like the postgres query does
+func (r *Repo) RateLimitTake(ctx context.Context, arg db.RateLimitTakeParams) (row db.RateLimitTakeRow, err error) {
+	err = r.one(ctx, `
+		INSERT INTO rate_limits (key, tokens, allowed, updated_at)
+		VALUES (?1, ?2 - 1, 1, ?4)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE
+				WHEN min(?2, tokens + (?4 - updated_at) / 1e6 * ?3) >= 1
+				THEN min(?2, tokens + (?4 - updated_at) / 1e6 * ?3) - 1
+				ELSE min(?2, tokens + (?4 - updated_at) / 1e6 * ?3)
+			END,
+			allowed = min(?2, tokens + (?4 - updated_at) / 1e6 * ?3) >= 1,
+			updated_at = ?4
+		RETURNING tokens, allowed`,
+		[]any{arg.Key, arg.Capacity, arg.RefillRate, nowMicros()},
+		func(s scanner) error { return s.Scan(&row.Tokens, &row.Allowed) })
+	return row, err
+}
+
+func (r *Repo) RateLimitPurge(ctx context.Context, updatedAt pgtype.Timestamptz) (int64, error) {
+	return r.exec(ctx, `DELETE FROM rate_limits WHERE updated_at < ?`, micros(updatedAt))
+}
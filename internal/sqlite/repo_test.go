@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/michaelcosj/hng-task-two/database"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/repotest"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	conn, err := Open(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) db.RepoQuerier {
+		return NewRepo(openTestDB(t))
+	})
+}
+
+func TestMigrateIsRepeatable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	for range 2 {
+		conn, err := Open(context.Background(), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var version int
+		if err := conn.sql.QueryRow(`SELECT version FROM schema_version`).Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+
+		all, _ := database.ReadMigrations(database.SQLiteMigrations, "sqlite")
+		if latest := all[len(all)-1].Version; version != latest {
+			t.Fatalf("expected version %d, got %d", latest, version)
+		}
+	}
+}
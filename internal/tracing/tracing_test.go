@@ -0,0 +1,20 @@
+package tracing
+
+import "testing"
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{sql: "-- name: UserWhereEmail :one\nSELECT id FROM users WHERE email = $1", want: "UserWhereEmail"},
+		{sql: "  select version from schema_version", want: "SELECT"},
+		{sql: "", want: "query"},
+	}
+
+	for _, test := range tests {
+		if got := queryName(test.sql); got != test.want {
+			t.Errorf("queryName(%q): want %q, got %q", test.sql, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package validate
+
+// lengths of the columns accounts and organisations are stored in.
+// the api and the admin command both check them with the rules below
+// so a value either of them accepts can be stored
+const (
+	MaxNameLength  = 255
+	MaxEmailLength = 255
+	// bcrypt only uses the first 72 bytes of a password
+	MaxPasswordBytes = 72
+)
+
+// Name requires the name of a person or organisation to fit its column
+func (r *StringRule) Name() *StringRule {
+	return r.MaxLength(MaxNameLength)
+}
+
+// AccountEmail requires an email address that fits its column
+func (r *StringRule) AccountEmail() *StringRule {
+	return r.Email().MaxLength(MaxEmailLength)
+}
+
+// Password requires a password bcrypt hashes in full
+func (r *StringRule) Password() *StringRule {
+	return r.MaxBytes(MaxPasswordBytes)
+}
@@ -0,0 +1,219 @@
+// Package validate checks request bodies with rules declared next to
+// the types they check
+//
+//	func (req *CreateOrgRequest) Validate(v *validate.Validator) {
+//		v.String("name", req.Name).Required().MaxLength(255)
+//	}
+//
+// problems are reported in the order the rules are declared, so
+// responses are the same every time
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+)
+
+// Validatable is a type that declares the rules it should satisfy
+type Validatable interface {
+	Validate(v *Validator)
+}
+
+// Validator collects the problems found by rules. nested objects and
+// array items share the problems of the validator they came from
+type Validator struct {
+	prefix   string
+	problems *[]app.FieldError
+}
+
+func New() *Validator {
+	return &Validator{problems: new([]app.FieldError)}
+}
+
+// Check runs the rules of value and returns a validation error
+// listing every problem, or nil when there are none
+func Check(value Validatable) error {
+	v := New()
+	value.Validate(v)
+	return v.Err()
+}
+
+func (v *Validator) Err() error {
+	if len(*v.problems) == 0 {
+		return nil
+	}
+	return app.NewValidationError(*v.problems)
+}
+
+func (v *Validator) Problems() []app.FieldError {
+	return *v.problems
+}
+
+// Fail reports a problem for rules that don't fit the builders
+func (v *Validator) Fail(field string, message string) {
+	*v.problems = append(*v.problems, app.FieldError{Field: v.path(field), Message: message})
+}
+
+func (v *Validator) path(field string) string {
+	switch {
+	case v.prefix == "":
+		return field
+	case field == "":
+		return v.prefix
+	default:
+		return v.prefix + "." + field
+	}
+}
+
+func (v *Validator) nested(path string) *Validator {
+	return &Validator{prefix: path, problems: v.problems}
+}
+
+// Object validates a nested object with its fields reported under
+// field, as in address.city. nil objects are skipped, optional objects
+// are pointers
+func Object[T interface {
+	comparable
+	Validatable
+}](v *Validator, field string, value T) {
+	var zero T
+	if value == zero {
+		return
+	}
+	value.Validate(v.nested(v.path(field)))
+}
+
+// Each runs fn for every item with the item's problems reported
+// under its index, as in events[0]
+func Each[T any](v *Validator, field string, items []T, fn func(v *Validator, item T)) {
+	for i, item := range items {
+		fn(v.nested(v.path(field)+"["+strconv.Itoa(i)+"]"), item)
+	}
+}
+
+// rule holds the state shared by the rule builders. only the first
+// failing rule of a field is reported
+type rule struct {
+	v      *Validator
+	field  string
+	failed bool
+}
+
+func (r *rule) check(ok bool, format string, args ...any) {
+	if r.failed || ok {
+		return
+	}
+	r.failed = true
+	r.v.Fail(r.field, fmt.Sprintf(format, args...))
+}
+
+type StringRule struct {
+	rule
+	value string
+}
+
+func (v *Validator) String(field string, value string) *StringRule {
+	return &StringRule{rule: rule{v: v, field: field}, value: value}
+}
+
+func (r *StringRule) Required() *StringRule {
+	r.check(strings.TrimSpace(r.value) != "", "is required")
+	return r
+}
+
+// the remaining rules only apply to values that were provided,
+// optional fields can be left empty
+func (r *StringRule) present() bool {
+	return r.value != ""
+}
+
+func (r *StringRule) MinLength(min int) *StringRule {
+	if r.present() {
+		r.check(utf8.RuneCountInString(r.value) >= min, "must be at least %d characters", min)
+	}
+	return r
+}
+
+func (r *StringRule) MaxLength(max int) *StringRule {
+	r.check(utf8.RuneCountInString(r.value) <= max, "must be at most %d characters", max)
+	return r
+}
+
+// MaxBytes limits the utf-8 encoded length for values that are limited
+// in bytes rather than characters, like passwords hashed with bcrypt
+func (r *StringRule) MaxBytes(max int) *StringRule {
+	r.check(len(r.value) <= max, "must be at most %d bytes", max)
+	return r
+}
+
+func (r *StringRule) Email() *StringRule {
+	if r.present() {
+		address, err := mail.ParseAddress(r.value)
+		// ParseAddress accepts "Name <a@b.c>", only the bare address is an email
+		r.check(err == nil && address.Address == r.value, "must be a valid email address")
+	}
+	return r
+}
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// Phone requires an E.164 number such as +2348012345678
+func (r *StringRule) Phone() *StringRule {
+	if r.present() {
+		r.check(e164.MatchString(r.value), "must be a phone number in E.164 format, e.g. +2348012345678")
+	}
+	return r
+}
+
+func (r *StringRule) UUID() *StringRule {
+	if r.present() {
+		_, err := uuid.Parse(r.value)
+		r.check(err == nil, "must be a valid uuid")
+	}
+	return r
+}
+
+// Url requires an absolute url with one of schemes
+func (r *StringRule) Url(schemes ...string) *StringRule {
+	if r.present() {
+		u, err := url.Parse(r.value)
+		r.check(err == nil && slices.Contains(schemes, u.Scheme) && u.Host != "", "must be a valid %s url", strings.Join(schemes, " or "))
+	}
+	return r
+}
+
+func (r *StringRule) OneOf(values ...string) *StringRule {
+	if r.present() {
+		r.check(slices.Contains(values, r.value), "must be one of %s", strings.Join(values, ", "))
+	}
+	return r
+}
+
+type SliceRule struct {
+	rule
+	length int
+}
+
+// Slice checks the length of an array, use Each to check its items
+func Slice[T any](v *Validator, field string, items []T) *SliceRule {
+	return &SliceRule{rule: rule{v: v, field: field}, length: len(items)}
+}
+
+func (r *SliceRule) Required() *SliceRule {
+	r.check(r.length > 0, "must have at least one item")
+	return r
+}
+
+func (r *SliceRule) MaxItems(max int) *SliceRule {
+	r.check(r.length <= max, "must have at most %d items", max)
+	return r
+}
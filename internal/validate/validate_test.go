@@ -0,0 +1,115 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/michaelcosj/hng-task-two/internal/app"
+)
+
+type address struct {
+	City    string
+	Country string
+}
+
+func (a *address) Validate(v *Validator) {
+	v.String("city", a.City).Required()
+	v.String("country", a.Country).Required().OneOf("NG", "GH")
+}
+
+type contact struct {
+	Name     string
+	Email    string
+	Phone    string
+	Secret   string
+	Id       string
+	Address  *address
+	Previous []*address
+	Tags     []string
+}
+
+func (c *contact) Validate(v *Validator) {
+	v.String("name", c.Name).Required().MaxLength(5)
+	v.String("email", c.Email).Required().Email()
+	v.String("phone", c.Phone).Phone()
+	v.String("secret", c.Secret).MaxBytes(8)
+	v.String("id", c.Id).UUID()
+	Object(v, "address", c.Address)
+	Each(v, "previous", c.Previous, func(v *Validator, a *address) {
+		Object(v, "", a)
+	})
+	Slice(v, "tags", c.Tags).MaxItems(2)
+}
+
+func TestCheck(t *testing.T) {
+	valid := contact{Name: "Ada", Email: "ada@mail.com"}
+
+	tests := []struct {
+		name  string
+		input contact
+		want  []app.FieldError
+	}{
+		{
+			name:  "Test valid with optional fields empty",
+			input: valid,
+		},
+		{
+			name:  "Test problems keep declaration order",
+			input: contact{Name: "Adaeze", Email: "Ada <ada@mail.com>", Phone: "08012345678", Id: "123"},
+			want: []app.FieldError{
+				{Field: "name", Message: "must be at most 5 characters"},
+				{Field: "email", Message: "must be a valid email address"},
+				{Field: "phone", Message: "must be a phone number in E.164 format, e.g. +2348012345678"},
+				{Field: "id", Message: "must be a valid uuid"},
+			},
+		},
+		{
+			name:  "Test only the first failing rule of a field",
+			input: contact{Email: "ada@mail.com"},
+			want:  []app.FieldError{{Field: "name", Message: "is required"}},
+		},
+		{
+			name:  "Test max bytes counts encoded bytes",
+			input: contact{Name: "Ada", Email: "ada@mail.com", Secret: "ééééé"},
+			want:  []app.FieldError{{Field: "secret", Message: "must be at most 8 bytes"}},
+		},
+		{
+			name: "Test nested objects and arrays",
+			input: contact{
+				Name:     "Ada",
+				Email:    "ada@mail.com",
+				Phone:    "+2348012345678",
+				Address:  &address{Country: "NG"},
+				Previous: []*address{{City: "Accra", Country: "GH"}, {City: "Lome", Country: "TG"}},
+				Tags:     []string{"a", "b", "c"},
+			},
+			want: []app.FieldError{
+				{Field: "address.city", Message: "is required"},
+				{Field: "previous[1].country", Message: "must be one of NG, GH"},
+				{Field: "tags", Message: "must have at most 2 items"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Check(&test.input)
+			if test.want == nil {
+				if err != nil {
+					t.Fatalf("expected no problems got %v", err)
+				}
+				return
+			}
+
+			var apiError app.ApiError
+			if !errors.As(err, &apiError) || !errors.Is(err, app.ErrValidation) {
+				t.Fatalf("expected a validation error got %v", err)
+			}
+
+			if !reflect.DeepEqual(apiError.Errors, test.want) {
+				t.Errorf("want %v, got %v", test.want, apiError.Errors)
+			}
+		})
+	}
+}
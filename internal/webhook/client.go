@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrAddressNotPublic is returned for endpoints that resolve to the
+// server's own or a private network
+var ErrAddressNotPublic = errors.New("webhook endpoint address is not public")
+
+const dialTimeout = 5 * time.Second
+
+// ranges that aren't caught by the netip methods publicAddress uses
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// carrier grade nat, also used by tailscale
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// nat64 can reach ipv4 addresses the checks above refuse
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// NewClient returns the client webhooks are sent with. anyone who can
+// manage an organisation can register an endpoint, so the client only
+// connects to public addresses or the server would post to, and report
+// on, its own network. addresses are checked as they are connected to,
+// after the name is resolved, which covers redirects and names that
+// resolve to a public address when checked and a private one later
+func NewClient() *http.Client {
+	dialer := &net.Dialer{Timeout: dialTimeout, Control: refuseNonPublic}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// the client would connect to a proxy rather than the endpoint
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: requestTimeout, Transport: transport}
+}
+
+func refuseNonPublic(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrAddressNotPublic, address)
+	}
+	if !publicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrAddressNotPublic, addrPort.Addr())
+	}
+	return nil
+}
+
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/michaelcosj/hng-task-two/internal/backoff"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// delivery statuses stored in webhook_deliveries
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	MaxAttempts = 8
+
+	pollInterval = 5 * time.Second
+	batchSize    = 20
+	// a claimed delivery is retried after the lease if the
+	// dispatcher dies before recording the attempt, so it must
+	// be longer than an attempt can take
+	leaseDuration  = time.Minute
+	requestTimeout = 10 * time.Second
+	// sending and recording an attempt, which carries on through
+	// a shutdown so the attempt isn't lost or sent twice
+	attemptTimeout = 30 * time.Second
+
+	backoffBase = 30 * time.Second
+	backoffMax  = 6 * time.Hour
+)
+
+// Message is a single signed request to a webhook endpoint
+type Message struct {
+	DeliveryId uuid.UUID
+	EventType  string
+	Url        string
+	Secret     string
+	Payload    []byte
+}
+
+// Send posts the message to its endpoint and returns the response
+// status code. any non 2xx response is treated as a failed attempt
+func Send(ctx context.Context, client *http.Client, msg Message) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.Url, bytes.NewReader(msg.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("error creating webhook request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "hng-task-two-webhooks")
+	req.Header.Set(HeaderId, msg.DeliveryId.String())
+	req.Header.Set(HeaderEvent, msg.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(msg.Secret, timestamp, msg.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// drain a bit of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Dispatcher sends queued deliveries and reschedules failed ones.
+// several dispatchers can run against the same database since
+// deliveries are claimed with SKIP LOCKED
+type Dispatcher struct {
+	repo   db.Querier
+	client *http.Client
+}
+
+// NewDispatcher sends deliveries with client, or NewClient when it is
+// nil. other clients are for tests sending to a local server
+func NewDispatcher(repo db.Querier, client *http.Client) *Dispatcher {
+	if client == nil {
+		client = NewClient()
+	}
+
+	return &Dispatcher{repo: repo, client: client}
+}
+
+// Run dispatches due deliveries until the context is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.DispatchDue(ctx); err != nil {
+			slog.Error("error dispatching webhooks", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue claims one batch of due deliveries and attempts them.
+// cancelling ctx stops new batches being claimed, a claimed batch is
+// still sent and recorded
+func (d *Dispatcher) DispatchDue(ctx context.Context) error {
+	deliveries, err := d.repo.DeliveryClaimDue(ctx, db.DeliveryClaimDueParams{
+		LeaseUntil: pgtype.Timestamptz{Time: time.Now().Add(leaseDuration), Valid: true},
+		BatchSize:  batchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+
+	// otherwise a shutdown would abort the sends and fail to record
+	// them, leaving the deliveries leased with an attempt counted
+	ctx = context.WithoutCancel(ctx)
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery db.DeliveryClaimDueRow) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, attemptTimeout)
+			defer cancel()
+			if err := d.attempt(ctx, delivery); err != nil {
+				slog.Error("error recording webhook delivery", "delivery_id", delivery.ID, "error", err)
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery db.DeliveryClaimDueRow) error {
+	statusCode, err := Send(ctx, d.client, Message{
+		DeliveryId: delivery.ID,
+		EventType:  delivery.EventType,
+		Url:        delivery.Url,
+		Secret:     delivery.Secret,
+		Payload:    delivery.Payload,
+	})
+
+	now := time.Now()
+	update := db.DeliveryUpdateParams{
+		ID:             delivery.ID,
+		Status:         StatusSucceeded,
+		NextAttemptAt:  pgtype.Timestamptz{Time: now, Valid: true},
+		LastStatusCode: pgtype.Int4{Int32: int32(statusCode), Valid: statusCode != 0},
+		DeliveredAt:    pgtype.Timestamptz{Time: now, Valid: true},
+	}
+
+	if err != nil {
+		// attempts was already incremented when the delivery was claimed
+		update.Status = StatusPending
+		update.LastError = pgtype.Text{String: err.Error(), Valid: true}
+		update.DeliveredAt = pgtype.Timestamptz{}
+		update.NextAttemptAt = pgtype.Timestamptz{Time: now.Add(backoff.Exponential(delivery.Attempts, backoffBase, backoffMax)), Valid: true}
+
+		if delivery.Attempts >= MaxAttempts {
+			update.Status = StatusFailed
+		}
+	}
+
+	return d.repo.DeliveryUpdate(ctx, update)
+}
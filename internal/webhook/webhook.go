@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/job"
+)
+
+// events organisations can subscribe their endpoints to
+const (
+	EventMemberAdded   = "member.added"
+	EventMemberRemoved = "member.removed"
+	EventOrgUpdated    = "org.updated"
+)
+
+var EventTypes = []string{
+	EventMemberAdded,
+	EventMemberRemoved,
+	EventOrgUpdated,
+}
+
+const (
+	HeaderId        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+func IsValidEvent(eventType string) bool {
+	return slices.Contains(EventTypes, eventType)
+}
+
+// Event is the json body every endpoint receives
+type Event struct {
+	Id        string    `json:"id"`
+	Type      string    `json:"type"`
+	OrgId     string    `json:"orgId"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// JobPublish is the kind of job that fans an event
+// out into deliveries, its payload is an Event
+const JobPublish = "webhook.publish"
+
+func NewEvent(orgId uuid.UUID, eventType string, data any) Event {
+	return Event{
+		Id:        uuid.NewString(),
+		Type:      eventType,
+		OrgId:     orgId.String(),
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+// Publish queues a delivery of the event for every active endpoint
+// of the organisation that subscribed to the event type, the
+// deliveries are sent later by the Dispatcher
+func Publish(ctx context.Context, q db.Querier, event Event) error {
+	orgId, err := uuid.Parse(event.OrgId)
+	if err != nil {
+		return fmt.Errorf("invalid event organisation id: %w", err)
+	}
+
+	eventId, err := uuid.Parse(event.Id)
+	if err != nil {
+		return fmt.Errorf("invalid event id: %w", err)
+	}
+
+	endpoints, err := q.WebhookAllForEvent(ctx, db.WebhookAllForEventParams{
+		OrgID:     orgId,
+		EventType: event.Type,
+	})
+	if err != nil {
+		return fmt.Errorf("error finding webhook endpoints: %w", err)
+	}
+
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding webhook event: %w", err)
+	}
+
+	for _, endpoint := range endpoints {
+		if _, err := q.DeliveryInsert(ctx, db.DeliveryInsertParams{
+			EndpointID: endpoint.ID,
+			EventID:    eventId,
+			EventType:  event.Type,
+			Payload:    payload,
+		}); err != nil {
+			return fmt.Errorf("error queueing webhook delivery: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// PublishHandler runs JobPublish jobs. the deliveries for an event are
+// queued in one transaction so a retried job never queues duplicates
+func PublishHandler(repo db.RepoQuerier) job.Handler {
+	return func(ctx context.Context, payload []byte) error {
+		var event Event
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return fmt.Errorf("error decoding webhook event: %w", err)
+		}
+
+		tx, err := repo.GetDB().Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot create database transaction: %w", err)
+		}
+		defer tx.Rollback(ctx)
+
+		if err := Publish(ctx, repo.WithTx(tx), event); err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	}
+}
+
+// Sign returns the value of the signature header for a payload.
+// the timestamp is part of the signed content so receivers
+// can reject replayed requests
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header value the way a receiver should
+func Verify(secret string, timestamp int64, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, payload)), []byte(signature))
+}
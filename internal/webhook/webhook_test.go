@@ -0,0 +1,223 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/db"
+)
+
+// fakeQuerier only implements the queries the dispatcher uses
+type fakeQuerier struct {
+	db.Querier
+	claimed []db.DeliveryClaimDueRow
+	updates []db.DeliveryUpdateParams
+}
+
+func (q *fakeQuerier) DeliveryClaimDue(ctx context.Context, arg db.DeliveryClaimDueParams) ([]db.DeliveryClaimDueRow, error) {
+	claimed := q.claimed
+	q.claimed = nil
+	return claimed, nil
+}
+
+func (q *fakeQuerier) DeliveryUpdate(ctx context.Context, arg db.DeliveryUpdateParams) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	q.updates = append(q.updates, arg)
+	return nil
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	secret := "a secret"
+	payload := []byte(`{"type":"member.added"}`)
+	deliveryId := uuid.New()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("invalid timestamp header: %v", err)
+		}
+
+		if !Verify(secret, timestamp, body, r.Header.Get(HeaderSignature)) {
+			t.Errorf("signature %s does not match payload", r.Header.Get(HeaderSignature))
+		}
+
+		if r.Header.Get(HeaderId) != deliveryId.String() {
+			t.Errorf("delivery id header: want %s, got %s", deliveryId, r.Header.Get(HeaderId))
+		}
+
+		if r.Header.Get(HeaderEvent) != EventMemberAdded {
+			t.Errorf("event header: want %s, got %s", EventMemberAdded, r.Header.Get(HeaderEvent))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	statusCode, err := Send(context.Background(), receiver.Client(), Message{
+		DeliveryId: deliveryId,
+		EventType:  EventMemberAdded,
+		Url:        receiver.URL,
+		Secret:     secret,
+		Payload:    payload,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusNoContent {
+		t.Errorf("status code: want %d, got %d", http.StatusNoContent, statusCode)
+	}
+}
+
+func TestVerifyRejectsTamperedPayload(t *testing.T) {
+	signature := Sign("secret", 1720000000, []byte(`{"a":1}`))
+
+	if Verify("secret", 1720000000, []byte(`{"a":2}`), signature) {
+		t.Error("tampered payload should not verify")
+	}
+
+	if Verify("secret", 1720000001, []byte(`{"a":1}`), signature) {
+		t.Error("changed timestamp should not verify")
+	}
+
+	if Verify("other secret", 1720000000, []byte(`{"a":1}`), signature) {
+		t.Error("wrong secret should not verify")
+	}
+}
+
+func TestDispatcherRecordsAttempts(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	succeeding := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer succeeding.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		attempts   int32
+		wantStatus string
+	}{
+		{"Test failed attempt is retried", failing.URL, 1, StatusPending},
+		{"Test last failed attempt gives up", failing.URL, MaxAttempts, StatusFailed},
+		{"Test successful attempt", succeeding.URL, 1, StatusSucceeded},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := &fakeQuerier{claimed: []db.DeliveryClaimDueRow{{
+				ID:        uuid.New(),
+				EventType: EventMemberAdded,
+				Payload:   []byte(`{}`),
+				Attempts:  test.attempts,
+				Url:       test.url,
+				Secret:    "secret",
+			}}}
+
+			// the test servers are on loopback, which NewClient refuses
+			if err := NewDispatcher(repo, http.DefaultClient).DispatchDue(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(repo.updates) != 1 {
+				t.Fatalf("expected 1 recorded attempt got %d", len(repo.updates))
+			}
+
+			update := repo.updates[0]
+			if update.Status != test.wantStatus {
+				t.Errorf("status: want %s, got %s", test.wantStatus, update.Status)
+			}
+
+			if test.wantStatus == StatusPending && !update.NextAttemptAt.Time.After(time.Now()) {
+				t.Errorf("retry should be scheduled in the future, got %v", update.NextAttemptAt.Time)
+			}
+
+			if test.wantStatus != StatusSucceeded && !update.LastError.Valid {
+				t.Error("failed attempt should record an error")
+			}
+		})
+	}
+}
+
+func TestDispatcherFinishesClaimedDeliveriesOnShutdown(t *testing.T) {
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	// the dispatcher is told to stop while the endpoint is answering
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		shutdown()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer endpoint.Close()
+
+	repo := &fakeQuerier{claimed: []db.DeliveryClaimDueRow{{
+		ID:        uuid.New(),
+		EventType: EventMemberAdded,
+		Payload:   []byte(`{}`),
+		Attempts:  1,
+		Url:       endpoint.URL,
+		Secret:    "secret",
+	}}}
+
+	if err := NewDispatcher(repo, http.DefaultClient).DispatchDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.updates) != 1 || repo.updates[0].Status != StatusSucceeded {
+		t.Fatalf("expected the delivery to be recorded as succeeded, got %+v", repo.updates)
+	}
+}
+
+func TestClientRefusesNonPublicAddresses(t *testing.T) {
+	received := false
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer local.Close()
+
+	_, err := Send(context.Background(), NewClient(), Message{DeliveryId: uuid.New(), Url: local.URL, Payload: []byte(`{}`)})
+	if !errors.Is(err, ErrAddressNotPublic) {
+		t.Errorf("expected a loopback endpoint to be refused, got %v", err)
+	}
+	if received {
+		t.Error("expected the loopback endpoint to get no request")
+	}
+
+	tests := map[string]bool{
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"fdaa::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+		"64:ff9b::a00:1":   false,
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+	}
+	for addr, want := range tests {
+		if got := publicAddress(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("publicAddress(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -2,26 +2,23 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/joho/godotenv"
-	"github.com/michaelcosj/hng-task-two/internal/db"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/fixture"
+	"github.com/michaelcosj/hng-task-two/internal/pgtest"
 	"github.com/michaelcosj/hng-task-two/internal/server"
 	"github.com/michaelcosj/hng-task-two/internal/service"
 )
 
-func init() {
-	err := godotenv.Load("../.env")
-	if err != nil {
-		panic(err)
-	}
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m))
 }
 
 var (
@@ -49,19 +46,19 @@ type authResp struct {
 	} `json:"data"`
 }
 
-func TestRegisterCases(t *testing.T) {
-	ctx := context.Background()
-	conn, err := pgxpool.New(ctx, os.Getenv("MOCK_PG_URI"))
-	if err != nil {
-		t.Errorf("Error initialising database: %v", err)
-	}
-	defer conn.Close()
+// setupHandler returns the routes over the package's database, with
+// changes rolled back when t ends, and fixtures for the data t needs
+func setupHandler(t *testing.T) (http.Handler, *fixture.Fixtures) {
+	repo := pgtest.Repo(t)
+	tokens := app.NewTokenIssuer("a secret only the tests use", 24*time.Hour)
+	svc := service.New(repo, tokens)
 
-	querier := db.New(conn)
-	repo := db.NewRepoQuerier(querier, conn)
-	svc := service.New(repo)
+	return server.RegisterRoutes(svc, tokens, nil, server.RateLimits{}), fixture.New(t, repo)
+}
 
-	handler := server.RegisterRoutes(svc)
+func TestRegisterCases(t *testing.T) {
+	handler, f := setupHandler(t)
+	registered := f.Email()
 
 	tests := []struct {
 		name        string
@@ -76,7 +73,7 @@ func TestRegisterCases(t *testing.T) {
 			name:        "Test first name missing",
 			FirstName:   "",
 			LastName:    "Doe",
-			Email:       "1@mail.com",
+			Email:       f.Email(),
 			Password:    "password",
 			shouldError: true,
 		},
@@ -84,7 +81,7 @@ func TestRegisterCases(t *testing.T) {
 			name:        "Test last name missing",
 			FirstName:   "John",
 			LastName:    "",
-			Email:       "2@mail.com",
+			Email:       f.Email(),
 			Password:    "password",
 			shouldError: true,
 		},
@@ -100,21 +97,21 @@ func TestRegisterCases(t *testing.T) {
 			name:        "Test password missing",
 			FirstName:   "John",
 			LastName:    "Doe",
-			Email:       "3@mail.com",
+			Email:       f.Email(),
 			Password:    "",
 			shouldError: true,
 		}, {
 			name:        "Test success",
 			FirstName:   "John",
 			LastName:    "Doe",
-			Email:       "4@mail.com",
+			Email:       registered,
 			Password:    "password",
 			shouldError: false,
 		}, {
 			name:        "Test duplicate email",
 			FirstName:   "John",
 			LastName:    "Doe",
-			Email:       "4@mail.com",
+			Email:       registered,
 			Password:    "password",
 			shouldError: true,
 		},
@@ -124,6 +121,7 @@ func TestRegisterCases(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			jsonUser, _ := json.Marshal(test)
 			req := httptest.NewRequest("POST", registerRoute, bytes.NewBuffer(jsonUser))
+			req.Header.Set("Content-Type", "application/json")
 			resp := httptest.NewRecorder()
 
 			handler.ServeHTTP(resp, req)
@@ -164,18 +162,8 @@ func TestRegisterCases(t *testing.T) {
 }
 
 func TestLoginCases(t *testing.T) {
-	ctx := context.Background()
-	conn, err := pgxpool.New(ctx, os.Getenv("MOCK_PG_URI"))
-	if err != nil {
-		t.Errorf("Error initialising database: %v", err)
-	}
-	defer conn.Close()
-
-	querier := db.New(conn)
-	repo := db.NewRepoQuerier(querier, conn)
-	svc := service.New(repo)
-
-	handler := server.RegisterRoutes(svc)
+	handler, f := setupHandler(t)
+	user := f.User()
 
 	tests := []struct {
 		name        string
@@ -185,12 +173,12 @@ func TestLoginCases(t *testing.T) {
 	}{
 		{
 			name:        "Test successful login",
-			Email:       "4@mail.com",
-			Password:    "password",
+			Email:       user.Email,
+			Password:    fixture.Password,
 			shouldError: false,
 		}, {
 			name:        "Test failed login",
-			Email:       "incorrect@mail.com",
+			Email:       f.Email(),
 			Password:    "password",
 			shouldError: true,
 		},
@@ -200,6 +188,7 @@ func TestLoginCases(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			jsonUser, _ := json.Marshal(test)
 			req := httptest.NewRequest("POST", loginRoute, bytes.NewBuffer(jsonUser))
+			req.Header.Set("Content-Type", "application/json")
 			resp := httptest.NewRecorder()
 
 			handler.ServeHTTP(resp, req)
@@ -232,18 +221,7 @@ func TestLoginCases(t *testing.T) {
 }
 
 func TestOrgCases(t *testing.T) {
-	ctx := context.Background()
-	conn, err := pgxpool.New(ctx, os.Getenv("MOCK_PG_URI"))
-	if err != nil {
-		t.Errorf("Error initialising database: %v", err)
-	}
-	defer conn.Close()
-
-	querier := db.New(conn)
-	repo := db.NewRepoQuerier(querier, conn)
-	svc := service.New(repo)
-
-	handler := server.RegisterRoutes(svc)
+	handler, f := setupHandler(t)
 
 	t.Run("Test Default Organisation Exists With Correct Name", func(t *testing.T) {
 		// register new user to get their token
@@ -256,11 +234,12 @@ func TestOrgCases(t *testing.T) {
 		}{
 			FirstName: "John",
 			LastName:  "Doe",
-			Email:     "420@mail.com",
+			Email:     f.Email(),
 			Password:  "password",
 		})
 
 		regReq := httptest.NewRequest("POST", registerRoute, bytes.NewBuffer(jsonUser))
+		regReq.Header.Set("Content-Type", "application/json")
 		regResp := httptest.NewRecorder()
 		handler.ServeHTTP(regResp, regReq)
 
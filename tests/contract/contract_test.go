@@ -0,0 +1,779 @@
+// Package contract drives the full api against the in-memory repository
+// and checks every request and response against the openapi spec
+package contract
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/michaelcosj/hng-task-two/internal/app"
+	"github.com/michaelcosj/hng-task-two/internal/mock"
+	"github.com/michaelcosj/hng-task-two/internal/openapi"
+	"github.com/michaelcosj/hng-task-two/internal/ratelimit"
+	"github.com/michaelcosj/hng-task-two/internal/server"
+	"github.com/michaelcosj/hng-task-two/internal/service"
+)
+
+const testSecret = "contract-test-secret-at-least-32-bytes"
+
+// harness sends requests to the api and keeps the values scenarios
+// capture from responses for the scenarios after them
+type harness struct {
+	handler http.Handler
+	service service.Service
+	spec    *openapi.Document
+	vars    map[string]string
+}
+
+func newHarness(limits server.RateLimits) *harness {
+	tokens := app.NewTokenIssuer(testSecret, time.Hour)
+	svc := service.New(mock.NewMockRepo(), tokens)
+
+	return &harness{
+		handler: server.RegisterRoutes(svc, tokens, nil, limits),
+		service: svc,
+		spec:    server.Spec(),
+		vars:    make(map[string]string),
+	}
+}
+
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// expand fills in {name} with captured values
+func (h *harness) expand(t *testing.T, s string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(match string) string {
+		name := match[1 : len(match)-1]
+		value, ok := h.vars[name]
+		if !ok {
+			t.Fatalf("%s has not been captured", name)
+		}
+		return value
+	})
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// do sends the request and fails the test if the response breaks the spec
+func (h *harness) do(t *testing.T, method string, path string, token string, contentType string, body string, validRequest bool) response {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if validRequest {
+		if err := h.spec.ValidateRequest(req, []byte(body)); err != nil {
+			t.Fatalf("request breaks the spec: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.handler.ServeHTTP(rec, req)
+
+	resp := response{rec.Code, rec.Header(), rec.Body.Bytes()}
+	if err := h.spec.ValidateResponse(req, resp.status, resp.header, resp.body); err != nil {
+		t.Errorf("%d response breaks the spec: %v\n%s", resp.status, err, resp.body)
+	}
+	return resp
+}
+
+// lookup reads a dotted path such as data.organisations.0.orgId
+func lookup(body []byte, path string) (string, bool) {
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", false
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]any:
+			value = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i >= len(node) {
+				return "", false
+			}
+			value = node[i]
+		default:
+			return "", false
+		}
+	}
+
+	s, ok := value.(string)
+	return s, ok
+}
+
+type scenario struct {
+	name   string
+	method string
+	path   string
+	// the user whose token is sent, captured as {user}Token
+	as   string
+	body string
+	// application/json when empty
+	contentType string
+	// the request breaks the spec on purpose
+	invalid bool
+
+	wantStatus int
+	// the problem code of error responses
+	wantCode string
+	// the response values to keep, by name
+	capture map[string]string
+}
+
+func (h *harness) run(t *testing.T, scenarios []scenario) {
+	for _, sc := range scenarios {
+		ok := t.Run(sc.name, func(t *testing.T) {
+			var token string
+			if sc.as != "" {
+				token = h.vars[sc.as+"Token"]
+			}
+
+			contentType := sc.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+
+			resp := h.do(t, sc.method, h.expand(t, sc.path), token, contentType, h.expand(t, sc.body), !sc.invalid)
+
+			if resp.status != sc.wantStatus {
+				t.Fatalf("expected %d got %d: %s", sc.wantStatus, resp.status, resp.body)
+			}
+
+			if sc.wantCode != "" {
+				if code, _ := lookup(resp.body, "code"); code != sc.wantCode {
+					t.Errorf("expected code %s got %s", sc.wantCode, code)
+				}
+			}
+
+			for name, path := range sc.capture {
+				value, ok := lookup(resp.body, path)
+				if !ok {
+					t.Fatalf("response has no %s to capture as %s", path, name)
+				}
+				h.vars[name] = value
+			}
+		})
+
+		// later scenarios depend on the ones before them
+		if !ok {
+			return
+		}
+	}
+}
+
+func register(user string, email string) scenario {
+	return scenario{
+		name:       "Test register " + user,
+		method:     "POST",
+		path:       "/auth/register",
+		body:       `{"firstName": "` + user + `", "lastName": "Doe", "email": "` + email + `", "password": "password", "phone": "+2348012345678"}`,
+		wantStatus: http.StatusCreated,
+		capture:    map[string]string{user + "Token": "data.accessToken", user + "Id": "data.user.userId"},
+	}
+}
+
+func TestAuthContract(t *testing.T) {
+	h := newHarness(server.RateLimits{})
+
+	h.run(t, []scenario{
+		register("alice", "alice@mail.com"),
+		{
+			name:       "Test register under /api/auth",
+			method:     "POST",
+			path:       "/api/auth/register",
+			body:       `{"firstName": "Bob", "lastName": "Doe", "email": "bob@mail.com", "password": "password"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Test register duplicate email",
+			method:     "POST",
+			path:       "/auth/register",
+			body:       `{"firstName": "Alice", "lastName": "Again", "email": "alice@mail.com", "password": "password"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "user.already_exists",
+		},
+		{
+			name:       "Test register missing fields",
+			method:     "POST",
+			path:       "/auth/register",
+			body:       `{"email": "carol@mail.com"}`,
+			invalid:    true,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "request.validation_failed",
+		},
+		{
+			name:       "Test register invalid phone",
+			method:     "POST",
+			path:       "/auth/register",
+			body:       `{"firstName": "Carol", "lastName": "Doe", "email": "carol@mail.com", "password": "password", "phone": "0801"}`,
+			invalid:    true,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "request.validation_failed",
+		},
+		{
+			name:       "Test register password over 72 bytes",
+			method:     "POST",
+			path:       "/auth/register",
+			body:       `{"firstName": "Carol", "lastName": "Doe", "email": "carol@mail.com", "password": "` + strings.Repeat("é", 40) + `"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "request.validation_failed",
+		},
+		{
+			name:        "Test register wrong content type",
+			method:      "POST",
+			path:        "/auth/register",
+			body:        `firstName=Carol`,
+			contentType: "application/x-www-form-urlencoded",
+			invalid:     true,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    "request.unsupported_media_type",
+		},
+		{
+			name:       "Test register malformed json",
+			method:     "POST",
+			path:       "/auth/register",
+			body:       `{"firstName": "Carol",`,
+			invalid:    true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "request.invalid_json",
+		},
+		{
+			name:       "Test register unknown field",
+			method:     "POST",
+			path:       "/auth/register",
+			body:       `{"firstName": "Carol", "lastName": "Doe", "email": "carol@mail.com", "password": "password", "admin": true}`,
+			invalid:    true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "request.invalid_json",
+		},
+		{
+			name:       "Test login",
+			method:     "POST",
+			path:       "/auth/login",
+			body:       `{"email": "alice@mail.com", "password": "password"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test login under /api/auth",
+			method:     "POST",
+			path:       "/api/auth/login",
+			body:       `{"email": "bob@mail.com", "password": "password"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test login wrong password",
+			method:     "POST",
+			path:       "/auth/login",
+			body:       `{"email": "alice@mail.com", "password": "wrong"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "auth.invalid_credentials",
+		},
+		{
+			name:       "Test login unknown email",
+			method:     "POST",
+			path:       "/auth/login",
+			body:       `{"email": "nobody@mail.com", "password": "password"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "auth.invalid_credentials",
+		},
+		{
+			name:       "Test login missing password",
+			method:     "POST",
+			path:       "/auth/login",
+			body:       `{"email": "alice@mail.com"}`,
+			invalid:    true,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "request.validation_failed",
+		},
+	})
+}
+
+func TestOrganisationContract(t *testing.T) {
+	h := newHarness(server.RateLimits{})
+	h.vars["badToken"] = "not.a.token"
+	h.vars["unknownId"] = "0190a5e4-0b6a-7d48-8a53-2f6b1c3d4e5f"
+
+	h.run(t, []scenario{
+		register("alice", "alice@mail.com"),
+		register("bob", "bob@mail.com"),
+		{
+			name:       "Test get self",
+			method:     "GET",
+			path:       "/api/users/{aliceId}",
+			as:         "alice",
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Test get user without a token",
+			method:     "GET",
+			path:       "/api/users/{aliceId}",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "auth.unauthenticated",
+		},
+		{
+			name:       "Test get user with an invalid token",
+			method:     "GET",
+			path:       "/api/users/{aliceId}",
+			as:         "bad",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "auth.invalid_token",
+		},
+		{
+			name:       "Test get user outside the user's organisations",
+			method:     "GET",
+			path:       "/api/users/{bobId}",
+			as:         "alice",
+			wantStatus: http.StatusNotFound,
+			wantCode:   "user.not_found",
+		},
+		{
+			name:       "Test get user with an invalid id",
+			method:     "GET",
+			path:       "/api/users/123",
+			as:         "alice",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "request.invalid",
+		},
+		{
+			name:       "Test list organisations has the default organisation",
+			method:     "GET",
+			path:       "/api/organisations",
+			as:         "alice",
+			wantStatus: http.StatusOK,
+			capture:    map[string]string{"defaultOrg": "data.organisations.0.orgId"},
+		},
+		{
+			name:       "Test create organisation",
+			method:     "POST",
+			path:       "/api/organisations",
+			as:         "alice",
+			body:       `{"name": "Acme", "description": "Rockets"}`,
+			wantStatus: http.StatusCreated,
+			capture:    map[string]string{"org": "data.orgId"},
+		},
+		{
+			name:       "Test create organisation without a name",
+			method:     "POST",
+			path:       "/api/organisations",
+			as:         "alice",
+			body:       `{"description": "Rockets"}`,
+			invalid:    true,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "request.validation_failed",
+		},
+		{
+			name:       "Test get organisation",
+			method:     "GET",
+			path:       "/api/organisations/{org}",
+			as:         "alice",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test get organisation the user is not in",
+			method:     "GET",
+			path:       "/api/organisations/{org}",
+			as:         "bob",
+			wantStatus: http.StatusNotFound,
+			wantCode:   "org.not_found",
+		},
+		{
+			name:       "Test get organisation with an invalid id",
+			method:     "GET",
+			path:       "/api/organisations/acme",
+			as:         "alice",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "request.invalid",
+		},
+		{
+			name:       "Test update organisation",
+			method:     "PUT",
+			path:       "/api/organisations/{org}",
+			as:         "alice",
+			body:       `{"name": "Acme Inc", "description": "More rockets"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test update organisation the user is not in",
+			method:     "PUT",
+			path:       "/api/organisations/{org}",
+			as:         "bob",
+			body:       `{"name": "Bob's now"}`,
+			wantStatus: http.StatusNotFound,
+			wantCode:   "org.not_found",
+		},
+		{
+			name:       "Test add user to organisation",
+			method:     "POST",
+			path:       "/api/organisations/{org}/users",
+			as:         "alice",
+			body:       `{"userId": "{bobId}"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test add existing member to organisation",
+			method:     "POST",
+			path:       "/api/organisations/{org}/users",
+			as:         "alice",
+			body:       `{"userId": "{bobId}"}`,
+			wantStatus: http.StatusConflict,
+			wantCode:   "org.member_exists",
+		},
+		{
+			name:       "Test add unknown user to organisation",
+			method:     "POST",
+			path:       "/api/organisations/{org}/users",
+			as:         "alice",
+			body:       `{"userId": "{unknownId}"}`,
+			wantStatus: http.StatusNotFound,
+			wantCode:   "user.not_found",
+		},
+		{
+			name:       "Test add user to unknown organisation",
+			method:     "POST",
+			path:       "/api/organisations/{unknownId}/users",
+			as:         "alice",
+			body:       `{"userId": "{bobId}"}`,
+			wantStatus: http.StatusNotFound,
+			wantCode:   "org.not_found",
+		},
+		{
+			name:       "Test add user with an invalid id",
+			method:     "POST",
+			path:       "/api/organisations/{org}/users",
+			as:         "alice",
+			body:       `{"userId": "bob"}`,
+			invalid:    true,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "request.validation_failed",
+		},
+		{
+			name:       "Test members can get the organisation",
+			method:     "GET",
+			path:       "/api/organisations/{org}",
+			as:         "bob",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test members can get each other",
+			method:     "GET",
+			path:       "/api/users/{bobId}",
+			as:         "alice",
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Test members can't update the organisation",
+			method:     "PUT",
+			path:       "/api/organisations/{org}",
+			as:         "bob",
+			body:       `{"name": "Bob's now"}`,
+			wantStatus: http.StatusForbidden,
+			wantCode:   "auth.forbidden",
+		},
+		{
+			name:       "Test members can't remove others",
+			method:     "DELETE",
+			path:       "/api/organisations/{org}/users/{aliceId}",
+			as:         "bob",
+			wantStatus: http.StatusForbidden,
+			wantCode:   "auth.forbidden",
+		},
+		{
+			name:       "Test admin removes a member",
+			method:     "DELETE",
+			path:       "/api/organisations/{org}/users/{bobId}",
+			as:         "alice",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test removing a user who is not a member",
+			method:     "DELETE",
+			path:       "/api/organisations/{org}/users/{bobId}",
+			as:         "alice",
+			wantStatus: http.StatusNotFound,
+			wantCode:   "user.not_found",
+		},
+	})
+}
+
+func TestAdminContract(t *testing.T) {
+	h := newHarness(server.RateLimits{})
+	h.run(t, []scenario{
+		register("admin", "admin@mail.com"),
+		register("bob", "bob@mail.com"),
+	})
+
+	// superadmins can only be made by the admin command
+	adminId := uuid.MustParse(h.vars["adminId"])
+	if err := h.service.SetSuperadmin(context.Background(), uuid.Nil, adminId, true); err != nil {
+		t.Fatal(err)
+	}
+
+	h.run(t, []scenario{
+		{
+			name:       "Test admin api as a user",
+			method:     "GET",
+			path:       "/admin/api/users",
+			as:         "bob",
+			wantStatus: http.StatusForbidden,
+			wantCode:   "auth.forbidden",
+		},
+		{
+			name:       "Test admin api without a token",
+			method:     "GET",
+			path:       "/admin/api/users",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "auth.unauthenticated",
+		},
+		{
+			name:       "Test search users",
+			method:     "GET",
+			path:       "/admin/api/users?search=bob&limit=10",
+			as:         "admin",
+			wantStatus: http.StatusOK,
+			capture:    map[string]string{"found": "data.accounts.0.userId"},
+		},
+		{
+			name:       "Test search users with an invalid limit",
+			method:     "GET",
+			path:       "/admin/api/users?limit=1000",
+			as:         "admin",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "request.validation_failed",
+		},
+		{
+			name:       "Test search organisations",
+			method:     "GET",
+			path:       "/admin/api/organisations?search=bob",
+			as:         "admin",
+			wantStatus: http.StatusOK,
+			capture:    map[string]string{"bobOrg": "data.organisations.0.orgId"},
+		},
+		{
+			name:       "Test list organisation members",
+			method:     "GET",
+			path:       "/admin/api/organisations/{bobOrg}/users",
+			as:         "admin",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test suspend user without a reason",
+			method:     "POST",
+			path:       "/admin/api/users/{bobId}/suspend",
+			as:         "admin",
+			body:       `{}`,
+			invalid:    true,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "request.validation_failed",
+		},
+		{
+			name:       "Test suspend user",
+			method:     "POST",
+			path:       "/admin/api/users/{bobId}/suspend",
+			as:         "admin",
+			body:       `{"reason": "spam"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test suspended user's token",
+			method:     "GET",
+			path:       "/api/organisations",
+			as:         "bob",
+			wantStatus: http.StatusForbidden,
+			wantCode:   "auth.account_suspended",
+		},
+		{
+			name:       "Test suspended user's login",
+			method:     "POST",
+			path:       "/auth/login",
+			body:       `{"email": "bob@mail.com", "password": "password"}`,
+			wantStatus: http.StatusForbidden,
+			wantCode:   "auth.account_suspended",
+		},
+		{
+			name:       "Test reactivate user",
+			method:     "POST",
+			path:       "/admin/api/users/{bobId}/reactivate",
+			as:         "admin",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test token from before the suspension",
+			method:     "GET",
+			path:       "/api/organisations",
+			as:         "bob",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "auth.invalid_token",
+		},
+		{
+			name:       "Test login after reactivation",
+			method:     "POST",
+			path:       "/auth/login",
+			body:       `{"email": "bob@mail.com", "password": "password"}`,
+			wantStatus: http.StatusOK,
+			capture:    map[string]string{"bobToken": "data.accessToken"},
+		},
+		{
+			name:       "Test logout user",
+			method:     "POST",
+			path:       "/admin/api/users/{bobId}/logout",
+			as:         "admin",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test logged out user's token",
+			method:     "GET",
+			path:       "/api/organisations",
+			as:         "bob",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "auth.invalid_token",
+		},
+		{
+			name:       "Test reactivate unknown user",
+			method:     "POST",
+			path:       "/admin/api/users/0190a5e4-0b6a-7d48-8a53-2f6b1c3d4e5f/reactivate",
+			as:         "admin",
+			wantStatus: http.StatusNotFound,
+			wantCode:   "user.not_found",
+		},
+		{
+			name:       "Test audit log",
+			method:     "GET",
+			path:       "/admin/api/audit?targetId={bobId}&limit=1",
+			as:         "admin",
+			wantStatus: http.StatusOK,
+			capture:    map[string]string{"lastAction": "data.entries.0.action"},
+		},
+	})
+
+	if h.vars["found"] != h.vars["bobId"] {
+		t.Errorf("expected the search to find bob, got %s", h.vars["found"])
+	}
+	if h.vars["lastAction"] != "user.logout" {
+		t.Errorf("expected the latest entry about bob to be user.logout, got %s", h.vars["lastAction"])
+	}
+}
+
+func TestDeactivationContract(t *testing.T) {
+	h := newHarness(server.RateLimits{})
+
+	h.run(t, []scenario{
+		register("alice", "alice@mail.com"),
+		{
+			name:       "Test deactivate with the wrong password",
+			method:     "POST",
+			path:       "/api/users/me/deactivate",
+			as:         "alice",
+			body:       `{"password": "wrong"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "auth.invalid_credentials",
+		},
+		{
+			name:       "Test deactivate without a password",
+			method:     "POST",
+			path:       "/api/users/me/deactivate",
+			as:         "alice",
+			body:       `{"reason": "moving on"}`,
+			invalid:    true,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "request.validation_failed",
+		},
+		{
+			name:       "Test deactivate",
+			method:     "POST",
+			path:       "/api/users/me/deactivate",
+			as:         "alice",
+			body:       `{"password": "password", "reason": "moving on"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test deactivated user's token",
+			method:     "GET",
+			path:       "/api/organisations",
+			as:         "alice",
+			wantStatus: http.StatusForbidden,
+			wantCode:   "auth.account_deactivated",
+		},
+		{
+			name:       "Test login within the grace period reactivates",
+			method:     "POST",
+			path:       "/auth/login",
+			body:       `{"email": "alice@mail.com", "password": "password"}`,
+			wantStatus: http.StatusOK,
+			capture:    map[string]string{"newToken": "data.accessToken"},
+		},
+		{
+			name:       "Test token from before the deactivation",
+			method:     "GET",
+			path:       "/api/organisations",
+			as:         "alice",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "auth.invalid_token",
+		},
+		{
+			name:       "Test token from the reactivating login",
+			method:     "GET",
+			path:       "/api/organisations",
+			as:         "new",
+			wantStatus: http.StatusOK,
+		},
+	})
+}
+
+func TestRateLimitContract(t *testing.T) {
+	limits := server.RateLimits{
+		Store:    ratelimit.NewMemoryStore(),
+		ClientIP: ratelimit.NewClientIP(nil),
+		Auth:     ratelimit.Limit{Requests: 1, Period: time.Minute, Burst: 1},
+		API:      ratelimit.Limit{Requests: 100, Period: time.Minute, Burst: 100},
+	}
+	h := newHarness(limits)
+
+	body := `{"email": "alice@mail.com", "password": "password"}`
+	if resp := h.do(t, "POST", "/auth/login", "", "application/json", body, true); resp.status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 got %d", resp.status)
+	}
+
+	resp := h.do(t, "POST", "/auth/login", "", "application/json", body, true)
+	if resp.status != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 got %d", resp.status)
+	}
+
+	if code, _ := lookup(resp.body, "code"); code != "request.rate_limited" {
+		t.Errorf("expected request.rate_limited got %s", code)
+	}
+}
+
+// the spec is only useful if responses that break it are caught
+func TestHarnessCatchesDrift(t *testing.T) {
+	spec := server.Spec()
+	req := httptest.NewRequest("GET", "/api/organisations", nil)
+	header := http.Header{"Content-Type": {"application/json"}}
+
+	body, _ := io.ReadAll(strings.NewReader(`{"status": "success", "message": "ok", "data": {"organisations": [{"orgId": "1", "name": 2}]}}`))
+	if err := spec.ValidateResponse(req, http.StatusOK, header, body); err == nil {
+		t.Error("expected a wrongly typed name to break the spec")
+	}
+
+	if err := spec.ValidateResponse(req, http.StatusTeapot, header, nil); err == nil {
+		t.Error("expected an undocumented status to break the spec")
+	}
+}